@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type ADRRes struct {
+	Currency   string     `json:"currency"`
+	ADR        float64    `json:"adr"`
+	SampleSize int        `json:"sample_size"`
+	Range      *DateRange `json:"range"`
+}
+
+// averageDailyRange is the mean absolute day-over-day percent change of
+// currency across rates, sorted by date ascending. Dates missing the currency
+// are skipped, so a move spans the gap to the previous quote.
+func averageDailyRange(rates []Rate, currency string) *ADRRes {
+	res := &ADRRes{Currency: currency}
+	moves := []float64{}
+	var prev float32
+	var first, last string
+	for i := range rates {
+		cur, ok := rates[i].RateMap()[currency]
+		if !ok {
+			continue
+		}
+		if first == "" {
+			first = rates[i].RateDate
+		} else {
+			moves = append(moves, math.Abs(percentChange(prev, cur)))
+		}
+		prev, last = cur, rates[i].RateDate
+	}
+	res.SampleSize = len(moves)
+	res.ADR = mean(moves)
+	if first != "" {
+		res.Range = &DateRange{Start: first, End: last}
+	}
+	return res
+}
+
+func getADR(c echo.Context) error {
+	currency := strings.ToUpper(c.QueryParam("currency"))
+	if !currencyRe.MatchString(currency) {
+		return c.JSON(http.StatusBadRequest, "invalid currency")
+	}
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	res := averageDailyRange(rates, currency)
+	if res.SampleSize < 1 {
+		return c.JSON(http.StatusNotFound, "fewer than two data points for "+currency)
+	}
+	return c.JSON(http.StatusOK, res)
+}
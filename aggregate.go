@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// aggregateKeys are the group keys an aggregate spec may use, mapped to the
+// expression computing them from an unwound rates document.
+var aggregateKeys = map[string]interface{}{
+	"currency": "$rates.currency",
+	"year":     bson.M{"$substr": []interface{}{"$rate_date", 0, 4}},
+	"month":    bson.M{"$substr": []interface{}{"$rate_date", 0, 7}},
+	"date":     "$rate_date",
+}
+
+// aggregateAccumulators are the accumulators an aggregate spec may select.
+var aggregateAccumulators = map[string]bson.M{
+	"min":    {"$min": "$rates.rate"},
+	"max":    {"$max": "$rates.rate"},
+	"avg":    {"$avg": "$rates.rate"},
+	"sum":    {"$sum": "$rates.rate"},
+	"stddev": {"$stdDevSamp": "$rates.rate"},
+	"count":  {"$sum": 1},
+	"first":  {"$first": "$rates.rate"},
+	"last":   {"$last": "$rates.rate"},
+}
+
+// AggregateSpec is the JSON accepted by POST /rates/aggregate. It is the
+// only way user input reaches a pipeline: every field is validated and
+// translated by pipeline, never passed through.
+type AggregateSpec struct {
+	Start        string   `json:"start"`
+	End          string   `json:"end"`
+	Currencies   []string `json:"currencies"`
+	GroupBy      []string `json:"group_by"`
+	Accumulators []string `json:"accumulators"`
+}
+
+func (s *AggregateSpec) Validate() error {
+	if _, err := newDateRangeMax(s.Start, s.End, 0); err != nil {
+		return err
+	}
+	for i, code := range s.Currencies {
+		s.Currencies[i] = strings.ToUpper(code)
+		if !currencyRe.MatchString(s.Currencies[i]) {
+			return fmt.Errorf("invalid currency code %q", code)
+		}
+	}
+	if len(s.GroupBy) == 0 {
+		return fmt.Errorf("group_by is required: currency, date, month or year")
+	}
+	keys := map[string]bool{}
+	for _, key := range s.GroupBy {
+		if _, ok := aggregateKeys[key]; !ok || keys[key] {
+			return fmt.Errorf("group_by: %q is not allowed, use currency, date, month or year once each", key)
+		}
+		keys[key] = true
+	}
+	if len(s.Accumulators) == 0 {
+		return fmt.Errorf("accumulators is required: avg, count, first, last, max, min, stddev or sum")
+	}
+	accs := map[string]bool{}
+	for _, acc := range s.Accumulators {
+		if _, ok := aggregateAccumulators[acc]; !ok || accs[acc] {
+			return fmt.Errorf("accumulators: %q is not allowed, use avg, count, first, last, max, min, stddev or sum once each", acc)
+		}
+		accs[acc] = true
+	}
+	return nil
+}
+
+// pipeline translates a validated spec. Documents are sorted by date before
+// grouping so first and last follow the calendar.
+func (s *AggregateSpec) pipeline() []bson.M {
+	filter := live(nil)
+	dates := bson.M{}
+	if s.Start != "" {
+		dates["$gte"] = s.Start
+	}
+	if s.End != "" {
+		dates["$lte"] = s.End
+	}
+	if len(dates) > 0 {
+		filter["rate_date"] = dates
+	}
+
+	pipeline := []bson.M{
+		{"$match": filter},
+		{"$sort": bson.M{"rate_date": 1}},
+		{"$unwind": "$rates"},
+	}
+	if len(s.Currencies) > 0 {
+		pipeline = append(pipeline, bson.M{"$match": bson.M{"rates.currency": bson.M{"$in": s.Currencies}}})
+	}
+	id := bson.D{}
+	for _, key := range s.GroupBy {
+		id = append(id, bson.DocElem{Name: key, Value: aggregateKeys[key]})
+	}
+	group := bson.M{"_id": id}
+	for _, acc := range s.Accumulators {
+		group[acc] = aggregateAccumulators[acc]
+	}
+	return append(pipeline,
+		bson.M{"$group": group},
+		bson.M{"$sort": bson.M{"_id": 1}},
+		bson.M{"$limit": config.MaxAggregationResults + 1},
+	)
+}
+
+// Aggregate runs spec and flattens each group into one row holding the
+// group keys and the accumulator values.
+func (p *DB) Aggregate(spec *AggregateSpec) ([]bson.M, error) {
+	span := p.startSpan("Aggregate")
+	defer span.End()
+
+	res := []bson.M{}
+	if err := db.C(config.Collection).Pipe(spec.pipeline()).All(&res); err != nil {
+		return nil, err
+	}
+	if len(res) > config.MaxAggregationResults {
+		return nil, errResultLimit
+	}
+	for _, row := range res {
+		if id, ok := row["_id"].(bson.M); ok {
+			for k, v := range id {
+				row[k] = v
+			}
+		}
+		delete(row, "_id")
+	}
+	return res, nil
+}
+
+type AggregateRes struct {
+	Spec    *AggregateSpec `json:"spec"`
+	Results []bson.M       `json:"results"`
+}
+
+func postAggregate(c echo.Context) error {
+	spec := &AggregateSpec{}
+	dec := json.NewDecoder(c.Request().Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(spec); err != nil {
+		return c.JSON(http.StatusBadRequest, "invalid spec: "+err.Error())
+	}
+	if err := spec.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	rows, err := storeFor(c).Aggregate(spec)
+	if err == errResultLimit {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, &AggregateRes{Spec: spec, Results: rows})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type AlertRule struct {
+	Currency     string  `json:"currency"`
+	PctThreshold float64 `json:"pct_threshold"`
+}
+
+type AlertResult struct {
+	Currency     string  `json:"currency"`
+	PctThreshold float64 `json:"pct_threshold"`
+	Previous     float32 `json:"previous"`
+	Latest       float32 `json:"latest"`
+	PctChange    float64 `json:"pct_change"`
+	Triggered    bool    `json:"triggered"`
+}
+
+type AlertCheckRes struct {
+	Date         string         `json:"date"`
+	PreviousDate string         `json:"previous_date"`
+	Triggered    int            `json:"triggered"`
+	Alerts       []*AlertResult `json:"alerts"`
+}
+
+func checkAlerts(latest, previous *Rate, rules []AlertRule) (*AlertCheckRes, error) {
+	cur := latest.RateMap()
+	prev := previous.RateMap()
+
+	res := &AlertCheckRes{
+		Date:         latest.RateDate,
+		PreviousDate: previous.RateDate,
+		Alerts:       []*AlertResult{},
+	}
+	for _, rule := range rules {
+		currency := strings.ToUpper(strings.TrimSpace(rule.Currency))
+		if rule.PctThreshold <= 0 {
+			return nil, fmt.Errorf("pct_threshold for %q must be positive", rule.Currency)
+		}
+		c, ok := cur[currency]
+		if !ok {
+			return nil, fmt.Errorf("unknown currency %q", rule.Currency)
+		}
+		pr, ok := prev[currency]
+		if !ok {
+			return nil, fmt.Errorf("currency %q has no rate on %s", currency, previous.RateDate)
+		}
+
+		change := percentChange(pr, c)
+		alert := &AlertResult{
+			Currency:     currency,
+			PctThreshold: rule.PctThreshold,
+			Previous:     pr,
+			Latest:       c,
+			PctChange:    change,
+			Triggered:    math.Abs(change) >= rule.PctThreshold,
+		}
+		if alert.Triggered {
+			res.Triggered++
+		}
+		res.Alerts = append(res.Alerts, alert)
+	}
+	return res, nil
+}
+
+func postAlertsCheck(c echo.Context) error {
+	var rules []AlertRule
+	if err := c.Bind(&rules); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if len(rules) == 0 {
+		return c.JSON(http.StatusBadRequest, "at least one alert rule is required")
+	}
+
+	recent, err := storeFor(c).FindRecent(2)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if len(recent) < 2 {
+		return c.JSON(http.StatusNotFound, "need at least two days of rates")
+	}
+
+	res, err := checkAlerts(&recent[0], &recent[1], rules)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, res)
+}
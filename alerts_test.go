@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func TestCheckAlerts(t *testing.T) {
+	previous := rateOf("2024-01-30", map[string]float32{"USD": 1.00, "GBP": 0.80})
+	latest := rateOf("2024-01-31", map[string]float32{"USD": 1.03, "GBP": 0.79, "JPY": 160})
+
+	tests := []struct {
+		name      string
+		rules     []AlertRule
+		triggered []bool
+		err       bool
+	}{
+		{"above threshold", []AlertRule{{"USD", 2}}, []bool{true}, false},
+		{"below threshold", []AlertRule{{"GBP", 2}}, []bool{false}, false},
+		{"falls count too", []AlertRule{{"gbp", 1.2}}, []bool{true}, false},
+		{"several rules", []AlertRule{{"USD", 5}, {"GBP", 1}}, []bool{false, true}, false},
+		{"zero threshold", []AlertRule{{"USD", 0}}, nil, true},
+		{"negative threshold", []AlertRule{{"USD", -1}}, nil, true},
+		{"unknown currency", []AlertRule{{"XXX", 1}}, nil, true},
+		{"missing the day before", []AlertRule{{"JPY", 1}}, nil, true},
+	}
+	for _, tt := range tests {
+		res, err := checkAlerts(latest, previous, tt.rules)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		fired := 0
+		for i, want := range tt.triggered {
+			if res.Alerts[i].Triggered != want {
+				t.Errorf("%s: alert %d triggered = %v, want %v", tt.name, i, res.Alerts[i].Triggered, want)
+			}
+			if want {
+				fired++
+			}
+		}
+		if res.Triggered != fired {
+			t.Errorf("%s: triggered = %d, want %d", tt.name, res.Triggered, fired)
+		}
+	}
+}
+
+func TestPostAlertsCheck(t *testing.T) {
+	store := testStore(t)
+	for _, seed := range []*Rate{
+		rateOf("2024-01-29", map[string]float32{"USD": 2.00}),
+		rateOf("2024-01-30", map[string]float32{"USD": 1.00}),
+		rateOf("2024-01-31", map[string]float32{"USD": 1.05}),
+	} {
+		if _, err := store.Save(seed); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := echo.New()
+	e.POST("/rates/alerts/check", postAlertsCheck)
+	req := httptest.NewRequest(http.MethodPost, "/rates/alerts/check", strings.NewReader(`[{"currency":"USD","pct_threshold":4}]`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var res AlertCheckRes
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	// Only the last two days count: +5%, not the -50% before them.
+	if res.Date != "2024-01-31" || res.PreviousDate != "2024-01-30" || res.Triggered != 1 {
+		t.Errorf("got %+v, want one alert for 2024-01-30 to 2024-01-31", res)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type Annotation struct {
+	Note      string    `bson:"note" json:"note"`
+	Author    string    `bson:"author" json:"author"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// AddAnnotation appends a to the live document of date, in the archive when
+// the date has been archived.
+func (p *DB) AddAnnotation(date string, a *Annotation) error {
+	span := p.startSpan("AddAnnotation")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	push := bson.M{"$push": bson.M{"annotations": a}}
+	err := db.C(config.Collection).Update(live(bson.M{"rate_date": date}), push)
+	if err == mgo.ErrNotFound && p.crossesArchive(date) {
+		err = db.C(ARCHIVE_COLLECTION).Update(bson.M{"rate_date": date}, push)
+	}
+	return err
+}
+
+type AnnotationReq struct {
+	Note   string `json:"note"`
+	Author string `json:"author"`
+}
+
+func putAnnotation(c echo.Context) error {
+	date := c.Param("date")
+	if _, err := time.Parse(DATE_LAYOUT, date); err != nil {
+		return c.JSON(http.StatusBadRequest, "date must be YYYY-MM-DD")
+	}
+	var req AnnotationReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	a := &Annotation{
+		Note:      strings.TrimSpace(req.Note),
+		Author:    strings.TrimSpace(req.Author),
+		CreatedAt: time.Now().UTC(),
+	}
+	if a.Note == "" || a.Author == "" {
+		return c.JSON(http.StatusBadRequest, "note and author are required")
+	}
+
+	err := storeFor(c).AddAnnotation(date, a)
+	if err == mgo.ErrNotFound {
+		return c.JSON(http.StatusNotFound, "no rates for "+date)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, a)
+}
+
+// getAdminDateRate returns the stored document of a date as is, with its
+// source, timestamps and annotations.
+func getAdminDateRate(c echo.Context) error {
+	rate, err := storeFor(c).FindByDate(c.Param("date"))
+	if err == mgo.ErrNotFound {
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, rate)
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const ARCHIVE_COLLECTION = "rates_archive"
+
+// ARCHIVE_BATCH is how many documents archive moves per query.
+const ARCHIVE_BATCH = 500
+
+// ArchiveState records how far the hot collection has been archived. Every
+// archived document is dated before Boundary; the hot collection can still
+// hold older dates written after a run, and those win over the archive.
+type ArchiveState struct {
+	ID       string    `bson:"_id" json:"-"`
+	Boundary string    `bson:"boundary" json:"boundary"`
+	LastRun  time.Time `bson:"last_run" json:"last_run"`
+	Moved    int       `bson:"moved" json:"moved"`
+}
+
+func (p *DB) GetArchiveState() (*ArchiveState, error) {
+	span := p.startSpan("GetArchiveState")
+	defer span.End()
+
+	var state ArchiveState
+	err := db.C(STATE_COLLECTION).FindId("archive").One(&state)
+	if err == mgo.ErrNotFound {
+		return &ArchiveState{ID: "archive"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (p *DB) SaveArchiveState(state *ArchiveState) error {
+	span := p.startSpan("SaveArchiveState")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	_, err := db.C(STATE_COLLECTION).UpsertId(state.ID, state)
+	return err
+}
+
+// boundaryCache keeps the archive boundary for the read paths, reloading it
+// every minute so a run on another instance is picked up.
+type boundaryCache struct {
+	mu       sync.Mutex
+	value    string
+	loadedAt time.Time
+}
+
+var archiveBoundary = &boundaryCache{}
+
+func (b *boundaryCache) Get(store *DB) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if time.Since(b.loadedAt) > time.Minute {
+		if state, err := store.GetArchiveState(); err != nil {
+			log.Println("archive, error on GetArchiveState", err)
+		} else {
+			b.value = state.Boundary
+		}
+		b.loadedAt = time.Now()
+	}
+	return b.value
+}
+
+func (b *boundaryCache) Set(value string) {
+	b.mu.Lock()
+	b.value = value
+	b.loadedAt = time.Now()
+	b.mu.Unlock()
+}
+
+// crossesArchive reports whether a window starting at start (empty for
+// open) can include archived dates.
+func (p *DB) crossesArchive(start string) bool {
+	boundary := archiveBoundary.Get(p)
+	return boundary != "" && (start == "" || start < boundary)
+}
+
+// findArchived runs a rates query against the archive collection.
+func (p *DB) findArchived(query bson.M) ([]Rate, error) {
+	span := p.startSpan("findArchived")
+	defer span.End()
+
+	rates := []Rate{}
+	err := db.C(ARCHIVE_COLLECTION).Find(query).Sort("rate_date").All(&rates)
+	return rates, err
+}
+
+// mergeArchived merges archived documents into hot ones sorted by date. A
+// date present in both is taken from hot since it was written later.
+func mergeArchived(hot, archived []Rate) []Rate {
+	inHot := map[string]bool{}
+	for i := range hot {
+		inHot[hot[i].RateDate] = true
+	}
+	res := make([]Rate, 0, len(hot)+len(archived))
+	for i := range archived {
+		if !inHot[archived[i].RateDate] {
+			res = append(res, archived[i])
+		}
+	}
+	res = append(res, hot...)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].RateDate < res[j].RateDate })
+	return res
+}
+
+// FindArchivable returns up to limit live hot documents dated before cutoff.
+func (p *DB) FindArchivable(cutoff string, limit int) ([]Rate, error) {
+	span := p.startSpan("FindArchivable")
+	defer span.End()
+
+	rates := []Rate{}
+	err := db.C(config.Collection).Find(live(bson.M{"rate_date": bson.M{"$lt": cutoff}})).
+		Sort("rate_date").Limit(limit).All(&rates)
+	return rates, err
+}
+
+// MoveToArchive copies rate into the archive, replacing an older archived
+// copy of the date, and then removes it from the hot collection. A crash
+// part way leaves the hot copy in place, which reads prefer and the next
+// run moves again.
+func (p *DB) MoveToArchive(rate *Rate) error {
+	span := p.startSpan("MoveToArchive")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	if _, err := db.C(ARCHIVE_COLLECTION).RemoveAll(bson.M{"rate_date": rate.RateDate}); err != nil {
+		return err
+	}
+	if err := db.C(ARCHIVE_COLLECTION).Insert(rate); err != nil {
+		return err
+	}
+	return db.C(config.Collection).RemoveId(rate.ID)
+}
+
+func archiveCutoff(now time.Time) string {
+	return now.UTC().AddDate(0, 0, -config.ArchiveAfterDays).Format(DATE_LAYOUT)
+}
+
+// archive moves live documents dated before cutoff to ARCHIVE_COLLECTION.
+// The boundary is saved before anything moves so readers never miss a
+// moved date.
+func archive(store *DB, cutoff string) (*ArchiveState, error) {
+	release, err := holdLock(store, "archive", config.LockTTL)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	state, err := store.GetArchiveState()
+	if err != nil {
+		return nil, err
+	}
+	if cutoff > state.Boundary {
+		state.Boundary = cutoff
+	}
+	state.LastRun = time.Now().UTC()
+	state.Moved = 0
+	if err := store.SaveArchiveState(state); err != nil {
+		return nil, err
+	}
+	archiveBoundary.Set(state.Boundary)
+
+	for {
+		rates, err := store.FindArchivable(cutoff, ARCHIVE_BATCH)
+		if err != nil {
+			return state, err
+		}
+		if len(rates) == 0 {
+			break
+		}
+		for i := range rates {
+			if err := store.MoveToArchive(&rates[i]); err != nil {
+				return state, err
+			}
+			state.Moved++
+		}
+	}
+	log.Println("archive: moved", state.Moved, "documents dated before", cutoff)
+	cache.Invalidate()
+	return state, store.SaveArchiveState(state)
+}
+
+type ArchiveStats struct {
+	*ArchiveState
+	Enabled  bool   `json:"enabled"`
+	Cutoff   string `json:"cutoff,omitempty"`
+	Hot      int    `json:"hot"`
+	Archived int    `json:"archived"`
+	Oldest   string `json:"oldest,omitempty"`
+	Newest   string `json:"newest,omitempty"`
+}
+
+func (p *DB) ArchiveStats() (*ArchiveStats, error) {
+	span := p.startSpan("ArchiveStats")
+	defer span.End()
+
+	state, err := p.GetArchiveState()
+	if err != nil {
+		return nil, err
+	}
+	res := &ArchiveStats{ArchiveState: state, Enabled: config.ArchiveAfterDays > 0}
+	if res.Enabled {
+		res.Cutoff = archiveCutoff(time.Now())
+	}
+	if res.Hot, err = db.C(config.Collection).Count(); err != nil {
+		return nil, err
+	}
+	if res.Archived, err = db.C(ARCHIVE_COLLECTION).Count(); err != nil {
+		return nil, err
+	}
+	var edge Rate
+	if err := db.C(ARCHIVE_COLLECTION).Find(nil).Sort("rate_date").One(&edge); err == nil {
+		res.Oldest = edge.RateDate
+	}
+	if err := db.C(ARCHIVE_COLLECTION).Find(nil).Sort("-rate_date").One(&edge); err == nil {
+		res.Newest = edge.RateDate
+	}
+	return res, nil
+}
+
+func getArchive(c echo.Context) error {
+	res, err := storeFor(c).ArchiveStats()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// postArchive runs the archive job now with the configured cutoff.
+func postArchive(c echo.Context) error {
+	if config.ArchiveAfterDays <= 0 {
+		return c.JSON(http.StatusBadRequest, "archiving is disabled, set ARCHIVE_AFTER_DAYS")
+	}
+	state, err := archive(storeFor(c), archiveCutoff(time.Now()))
+	if err == errLockHeld {
+		return c.JSON(http.StatusConflict, "an archive run is already in progress")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, state)
+}
+
+// analyzeRates is the AnalyzeRange pipeline over documents already loaded:
+// per currency min, max, average and sample stddev, sorted by currency.
+func analyzeRates(rates []Rate) []*AnalyzeRes {
+	values := map[string][]float64{}
+	for i := range rates {
+		for _, item := range rates[i].Rates {
+			values[item.Currency] = append(values[item.Currency], float64(item.Rate))
+		}
+	}
+	res := []*AnalyzeRes{}
+	for code, vs := range values {
+		a := &AnalyzeRes{Currency: code, Min: float32(vs[0]), Max: float32(vs[0]), Count: len(vs)}
+		sum := 0.0
+		for _, v := range vs {
+			sum += v
+			if float32(v) < a.Min {
+				a.Min = float32(v)
+			}
+			if float32(v) > a.Max {
+				a.Max = float32(v)
+			}
+		}
+		mean := sum / float64(len(vs))
+		a.Avg = float32(mean)
+		if len(vs) > 1 {
+			ss := 0.0
+			for _, v := range vs {
+				ss += (v - mean) * (v - mean)
+			}
+			a.Stddev = math.Sqrt(ss / float64(len(vs)-1))
+		}
+		res = append(res, a)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Currency < res[j].Currency })
+	return res
+}
+
+// findEdge returns the live document matching query that sorts first by
+// sort, "rate_date" or "-rate_date", looking in the archive as well when
+// withArchive is set. A date found in both is taken from hot.
+func (p *DB) findEdge(query bson.M, sort string, withArchive bool) (*Rate, error) {
+	hotQuery := bson.M{}
+	for k, v := range query {
+		hotQuery[k] = v
+	}
+	var hot, archived Rate
+	errHot := db.C(config.Collection).Find(live(hotQuery)).Sort(sort).One(&hot)
+	if errHot != nil && errHot != mgo.ErrNotFound {
+		return nil, errHot
+	}
+	if !withArchive {
+		if errHot != nil {
+			return nil, errHot
+		}
+		return &hot, nil
+	}
+	errArchived := db.C(ARCHIVE_COLLECTION).Find(query).Sort(sort).One(&archived)
+	switch {
+	case errArchived != nil && errArchived != mgo.ErrNotFound:
+		return nil, errArchived
+	case errArchived != nil:
+		if errHot != nil {
+			return nil, errHot
+		}
+		return &hot, nil
+	case errHot != nil:
+		return &archived, nil
+	}
+	// Ascending wants the earlier date, descending the later one.
+	if archived.RateDate != hot.RateDate && (archived.RateDate < hot.RateDate) == (sort == "rate_date") {
+		return &archived, nil
+	}
+	return &hot, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAnalyzeRates(t *testing.T) {
+	tests := []struct {
+		name  string
+		rates []Rate
+		want  []AnalyzeRes
+	}{
+		{"empty", nil, nil},
+		{
+			"single value has no stddev",
+			[]Rate{*rateOf("2024-01-02", map[string]float32{"USD": 1.1})},
+			[]AnalyzeRes{{Currency: "USD", Min: 1.1, Max: 1.1, Avg: 1.1, Count: 1}},
+		},
+		{
+			"sorted by currency",
+			[]Rate{
+				*rateOf("2024-01-02", map[string]float32{"USD": 1, "GBP": 0.5}),
+				*rateOf("2024-01-03", map[string]float32{"USD": 3}),
+			},
+			[]AnalyzeRes{
+				{Currency: "GBP", Min: 0.5, Max: 0.5, Avg: 0.5, Count: 1},
+				{Currency: "USD", Min: 1, Max: 3, Avg: 2, Stddev: math.Sqrt2, Count: 2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := analyzeRates(tt.rates)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d currencies, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				g, w := *got[i], tt.want[i]
+				if g.Currency != w.Currency || g.Min != w.Min || g.Max != w.Max || g.Avg != w.Avg ||
+					g.Count != w.Count || math.Abs(g.Stddev-w.Stddev) > 1e-9 {
+					t.Errorf("got %+v, want %+v", g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestArchivedReads(t *testing.T) {
+	store := testStore(t)
+	t.Cleanup(func() { archiveBoundary.Set("") })
+	for _, date := range []string{"2020-01-02", "2020-01-03", "2024-01-02"} {
+		if _, err := store.Save(rateOf(date, map[string]float32{"USD": 1.1})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	state, err := archive(store, "2021-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Moved != 2 {
+		t.Fatalf("moved %d documents, want 2", state.Moved)
+	}
+
+	nearest, err := store.FindNearest("2020-01-04")
+	if err != nil || nearest.RateDate != "2020-01-03" {
+		t.Errorf("FindNearest: %v, %v; want 2020-01-03", nearest, err)
+	}
+
+	analyzed, err := store.AnalyzeRange("", "")
+	if err != nil || len(analyzed) != 1 || analyzed[0].Count != 3 {
+		t.Errorf("AnalyzeRange: %v, %v; want 3 USD values", analyzed, err)
+	}
+
+	iter, err := store.IterRange("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rate Rate
+	n := 0
+	for iter.Next(&rate) {
+		n++
+		rate = Rate{}
+	}
+	if err := iter.Close(); err != nil || n != 3 || iter.Total != 3 {
+		t.Errorf("IterRange: yielded %d, Total %d, err %v; want 3", n, iter.Total, err)
+	}
+
+	archived, err := store.FindByDate("2020-01-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archived.Rates = rateOf("", map[string]float32{"USD": 1.2}).Rates
+	if err := store.Update(archived); err != nil {
+		t.Fatalf("Update on an archived date: %v", err)
+	}
+	got, err := store.FindByDate("2020-01-02")
+	if err != nil || got.RateMap()["USD"] != 1.2 {
+		t.Errorf("after Update: %v, %v; want USD 1.2", got, err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// getRatesAt serves the rates in effect at ?ts=, an RFC 3339 timestamp: the
+// latest intraday point at or before it, or for daily data the document of
+// ts's UTC day or the last one before it.
+func getRatesAt(c echo.Context) error {
+	ts, err := time.Parse(time.RFC3339, c.QueryParam("ts"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "ts must be an RFC 3339 timestamp, e.g. 2020-01-15T14:30:00Z")
+	}
+	rate, err := storeFor(c).FindAt(ts)
+	if err == mgo.ErrNotFound {
+		return c.JSON(http.StatusNotFound, "no rates at or before "+ts.UTC().Format(time.RFC3339))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	res := &DailyRate{
+		Base:  "EUR",
+		Date:  rate.RateDate,
+		Rates: rate.RateMap(),
+	}
+	if !rate.Timestamp.IsZero() {
+		res.Timestamp = &rate.Timestamp
+	}
+	setLastModified(c, rate.ModifiedAt())
+	return jsonFields(c, http.StatusOK, res)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+)
+
+// adminAuth guards operator-only routes with the ADMIN_API_KEY bearer key.
+// When no key is configured every request is rejected.
+func adminAuth() echo.MiddlewareFunc {
+	return middleware.KeyAuth(func(key string, c echo.Context) (bool, error) {
+		return validAdminKey(key), nil
+	})
+}
+
+func validAdminKey(key string) bool {
+	if config.AdminAPIKey == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(key), []byte(config.AdminAPIKey)) == 1
+}
+
+// isAdmin reports whether c carries the admin key, read the way adminAuth
+// reads it. Middleware that runs before the route's own auth uses it.
+func isAdmin(c echo.Context) bool {
+	const scheme = "Bearer "
+	auth := c.Request().Header.Get(echo.HeaderAuthorization)
+	if !strings.HasPrefix(auth, scheme) {
+		return false
+	}
+	return validAdminKey(auth[len(scheme):])
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	AverageCross = "cross"
+	AverageEUR   = "eur"
+)
+
+type AverageConvertRes struct {
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	Amount      float64 `json:"amount"`
+	Method      string  `json:"method"`
+	AverageRate float64 `json:"average_rate"`
+	// EURAverages holds the per-currency averages used by method=eur.
+	EURAverages map[string]float64 `json:"eur_averages,omitempty"`
+	Fixings     int                `json:"fixings"`
+	Range       *DateRange         `json:"range"`
+	Result      float64            `json:"result"`
+	Places      int                `json:"places"`
+	Rounding    string             `json:"rounding"`
+}
+
+// averageCrossRate averages the per-day from->to cross rate. Days missing
+// either currency are skipped.
+func averageCrossRate(rates []Rate, from, to string) (avg float64, fixings int, used *DateRange) {
+	sum := 0.0
+	for i := range rates {
+		cross, err := crossRate(rates[i].RateMap(), from, to)
+		if err != nil {
+			continue
+		}
+		if used == nil {
+			used = &DateRange{Start: rates[i].RateDate}
+		}
+		used.End = rates[i].RateDate
+		sum += cross
+		fixings++
+	}
+	if fixings == 0 {
+		return 0, 0, nil
+	}
+	return sum / float64(fixings), fixings, used
+}
+
+// averageFromAnalyze divides the period-average EUR rates of to and from as
+// computed by AnalyzeRange. Fixings is the smaller of the two counts.
+func averageFromAnalyze(stats []*AnalyzeRes, from, to string) (avg float64, fixings int, averages map[string]float64) {
+	averages = map[string]float64{}
+	counts := map[string]int{}
+	for _, code := range []string{from, to} {
+		if code == "EUR" {
+			averages[code] = 1
+		}
+	}
+	for _, s := range stats {
+		if s.Currency == from || s.Currency == to {
+			averages[s.Currency] = float64(s.Avg)
+			counts[s.Currency] = s.Count
+		}
+	}
+	if averages[from] == 0 || averages[to] == 0 {
+		return 0, 0, nil
+	}
+	fixings = counts[from]
+	if fixings == 0 || (counts[to] != 0 && counts[to] < fixings) {
+		fixings = counts[to]
+	}
+	return averages[to] / averages[from], fixings, averages
+}
+
+func getConvertAverage(c echo.Context) error {
+	amount, err := strconv.ParseFloat(c.QueryParam("amount"), 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "amount must be a number")
+	}
+	req := &ConvertReq{
+		From:     c.QueryParam("from"),
+		To:       c.QueryParam("to"),
+		Amount:   amount,
+		Rounding: c.QueryParam("rounding"),
+	}
+	if v := c.QueryParam("places"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, "places must be an integer")
+		}
+		req.Places = &n
+	}
+	if err := req.normalize(); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+	if r.Start == "" || r.End == "" {
+		return c.JSON(http.StatusBadRequest, "start and end are required")
+	}
+	method := c.QueryParam("method")
+	if method == "" {
+		method = AverageCross
+	}
+
+	var avg float64
+	var fixings int
+	var used *DateRange
+	var averages map[string]float64
+	switch method {
+	case AverageCross:
+		rates, err := storeFor(c).FindRange(r.Start, r.End)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		avg, fixings, used = averageCrossRate(rates, req.From, req.To)
+	case AverageEUR:
+		stats, err := storeFor(c).AnalyzeRange(r.Start, r.End)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		avg, fixings, averages = averageFromAnalyze(stats, req.From, req.To)
+		used = r
+	default:
+		return c.JSON(http.StatusBadRequest, "method must be cross or eur")
+	}
+	if fixings == 0 {
+		return c.JSON(http.StatusNotFound, "no fixings for "+req.From+"/"+req.To+" in range")
+	}
+
+	res := &AverageConvertRes{
+		From:        req.From,
+		To:          req.To,
+		Amount:      req.Amount,
+		Method:      method,
+		AverageRate: avg,
+		EURAverages: averages,
+		Fixings:     fixings,
+		Range:       used,
+		Result:      roundAmount(req.Amount*avg, req.places, req.Rounding),
+		Places:      req.places,
+		Rounding:    req.Rounding,
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type GapsRes struct {
+	Range   *DateRange `json:"range"`
+	Missing []string   `json:"missing"`
+}
+
+// findGaps lists the business days between start and end that have no
+// stored document.
+func findGaps(store *DB, start, end string) ([]string, error) {
+	rates, err := store.FindRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	stored := map[string]bool{}
+	for i := range rates {
+		stored[rates[i].RateDate] = true
+	}
+
+	from, err := time.Parse(DATE_LAYOUT, start)
+	if err != nil {
+		return nil, err
+	}
+	to, err := time.Parse(DATE_LAYOUT, end)
+	if err != nil {
+		return nil, err
+	}
+	missing := []string{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		date := d.Format(DATE_LAYOUT)
+		if isBusinessDay(date) && !stored[date] {
+			missing = append(missing, date)
+		}
+	}
+	return missing, nil
+}
+
+func getGaps(c echo.Context) error {
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+	if r.Start == "" || r.End == "" {
+		return c.JSON(http.StatusBadRequest, "start and end are required")
+	}
+
+	missing, err := findGaps(storeFor(c), r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, &GapsRes{Range: r, Missing: missing})
+}
+
+const TriggerBackfill = "backfill"
+
+// BACKFILL_BATCH is how many dates a backfill saves between updates of its
+// ingest run.
+const BACKFILL_BATCH = 100
+
+type BackfillRes struct {
+	Range       *DateRange `json:"range"`
+	Overwrite   bool       `json:"overwrite"`
+	Filled      []string   `json:"filled"`
+	Updated     []string   `json:"updated"`
+	Unavailable []string   `json:"unavailable"`
+	RunID       string     `json:"run_id"`
+}
+
+// backfillSource picks the smallest ECB file covering start: the 90-day file
+// for recent windows, the full history otherwise.
+func backfillSource(start string, now time.Time) string {
+	if start >= now.AddDate(0, 0, -85).Format(DATE_LAYOUT) {
+		return ECB_URL
+	}
+	return ECB_HIST_URL
+}
+
+// startBackfill takes the ingest locks and returns the run to record the
+// backfill in: the latest unfinished run for the same window and force flag
+// when a previous attempt crashed or failed, a new one otherwise. The
+// caller runs backfill and then release.
+func startBackfill(store *DB, start, end string, force bool) (run *IngestRun, release func(), err error) {
+	if !refreshMu.TryLock() {
+		return nil, nil, errRefreshRunning
+	}
+	unlock, err := holdLock(store, "ingest", config.LockTTL)
+	if err != nil {
+		refreshMu.Unlock()
+		return nil, nil, err
+	}
+	release = func() {
+		unlock()
+		refreshMu.Unlock()
+	}
+
+	run, err = store.FindUnfinishedBackfill(start, end, force)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	if run != nil {
+		run.Status = RunRunning
+		run.Error = ""
+		run.Resumed++
+		err = store.UpdateIngestRun(run)
+	} else {
+		run = &IngestRun{
+			ID:        bson.NewObjectId(),
+			Trigger:   TriggerBackfill,
+			Provider:  "ecb",
+			Status:    RunRunning,
+			StartedAt: time.Now().UTC(),
+			Inserted:  []string{},
+			Updated:   []string{},
+			Empty:     []string{},
+			Rejected:  []string{},
+			Coverage:  []*CoverageChange{},
+			Range:     &DateRange{Start: start, End: end},
+			Force:     force,
+		}
+		err = store.InsertIngestRun(run)
+	}
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return run, release, nil
+}
+
+// backfill saves the provider dates in run.Range that are missing from the
+// store, or every date in the range when run.Force is set, oldest first,
+// through the same post-processing and outlier checks as a refresh.
+// The run is written back every BACKFILL_BATCH dates with the last saved
+// date as its checkpoint, and dates up to the checkpoint are skipped, so a
+// resumed run continues where the previous attempt stopped. Requested gaps
+// the provider has no data for are reported as unavailable.
+func backfill(ctx context.Context, store *DB, run *IngestRun) (err error) {
+	defer func() {
+		run.FinishedAt = time.Now().UTC()
+		run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+		if err != nil {
+			run.Status = RunFailed
+			run.Error = err.Error()
+		} else {
+			run.Status = RunSuccess
+		}
+		if err := store.UpdateIngestRun(run); err != nil {
+			logCtx(ctx, "backfill, error on UpdateIngestRun", err)
+		}
+	}()
+
+	start, end := run.Range.Start, run.Range.End
+	var missing []string
+	wanted := map[string]bool{}
+	if !run.Force {
+		if missing, err = findGaps(store, start, end); err != nil {
+			return err
+		}
+		for _, d := range missing {
+			wanted[d] = true
+		}
+	}
+
+	url := backfillSource(start, time.Now().UTC())
+	logCtx(ctx, "backfill", start, "to", end, "from", url)
+	rates, err := fetchECBFull(ctx, url)
+	if err != nil {
+		return err
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].RateDate < rates[j].RateDate })
+
+	provided := map[string]bool{}
+	todo := []*Rate{}
+	for _, rate := range rates {
+		if rate.RateDate < start || rate.RateDate > end || len(rate.Rates) == 0 {
+			continue
+		}
+		provided[rate.RateDate] = true
+		if rate.RateDate <= run.Checkpoint || (!run.Force && !wanted[rate.RateDate]) {
+			continue
+		}
+		todo = append(todo, rate)
+	}
+	run.Unavailable = []string{}
+	for _, d := range missing {
+		if !provided[d] {
+			run.Unavailable = append(run.Unavailable, d)
+		}
+	}
+	run.Total = run.Processed + len(todo)
+	if err := store.UpdateIngestRun(run); err != nil {
+		logCtx(ctx, "backfill, error on UpdateIngestRun", err)
+	}
+
+	procs := buildPostProcessors(store, config.PostProcessors)
+	for i, rate := range todo {
+		if err := persistRate(ctx, store, run, procs, rate); err != nil {
+			return err
+		}
+		run.Checkpoint = rate.RateDate
+		run.Processed++
+
+		if (i+1)%BACKFILL_BATCH == 0 {
+			logCtx(ctx, "backfill,", run.Processed, "of", run.Total, "dates through", run.Checkpoint)
+			if err := store.UpdateIngestRun(run); err != nil {
+				logCtx(ctx, "backfill, error on UpdateIngestRun", err)
+			}
+		}
+	}
+	cache.Invalidate()
+	return nil
+}
+
+// FindUnfinishedBackfill returns the latest backfill run for the window that
+// failed or never finished, or nil when there is none. Callers hold the
+// ingest lock, so a run still marked running belongs to a crashed process.
+func (p *DB) FindUnfinishedBackfill(start, end string, force bool) (*IngestRun, error) {
+	span := p.startSpan("FindUnfinishedBackfill")
+	defer span.End()
+
+	var run IngestRun
+	err := db.C(INGEST_RUNS_COLLECTION).Find(bson.M{
+		"trigger":     TriggerBackfill,
+		"status":      bson.M{"$in": []string{RunRunning, RunFailed}},
+		"range.start": start,
+		"range.end":   end,
+		// force is omitted from the document when false.
+		"force": bson.M{"$exists": force},
+	}).Sort("-started_at").One(&run)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func parseBackfill(c echo.Context) (*DateRange, bool, error) {
+	r, err := newDateRangeMax(c.QueryParam("start"), c.QueryParam("end"), 0)
+	if err != nil {
+		return nil, false, err
+	}
+	if r.Start == "" || r.End == "" {
+		return nil, false, errors.New("start and end are required")
+	}
+	force, _ := strconv.ParseBool(c.QueryParam("force"))
+	if overwrite, _ := strconv.ParseBool(c.QueryParam("overwrite")); overwrite {
+		force = true
+	}
+	return r, force, nil
+}
+
+func backfillStatus(err error) int {
+	if err == errRefreshRunning || err == errLockHeld {
+		return http.StatusConflict
+	}
+	return http.StatusBadGateway
+}
+
+// postBackfill runs the backfill within the request and reports the dates
+// it wrote. ?overwrite=true is the older spelling of force.
+func postBackfill(c echo.Context) error {
+	r, force, err := parseBackfill(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	store := storeFor(c)
+	run, release, err := startBackfill(store, r.Start, r.End, force)
+	if err != nil {
+		return c.JSON(backfillStatus(err), err.Error())
+	}
+	defer release()
+	if err := backfill(c.Request().Context(), store, run); err != nil {
+		return c.JSON(http.StatusBadGateway, err.Error())
+	}
+	return c.JSON(http.StatusOK, &BackfillRes{
+		Range:       r,
+		Overwrite:   force,
+		Filled:      run.Inserted,
+		Updated:     run.Updated,
+		Unavailable: run.Unavailable,
+		RunID:       run.ID.Hex(),
+	})
+}
+
+// postAdminBackfill starts the backfill in the background and returns its
+// ingest run right away; poll GET /admin/ingestions/:id for progress.
+func postAdminBackfill(c echo.Context) error {
+	r, force, err := parseBackfill(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	run, release, err := startBackfill(storeFor(c), r.Start, r.End, force)
+	if err != nil {
+		return c.JSON(backfillStatus(err), err.Error())
+	}
+	accepted := *run
+	go func() {
+		defer release()
+		ctx, span := tracer.Start(context.Background(), "backfill")
+		defer span.End()
+		if err := backfill(ctx, p.WithContext(ctx), run); err != nil {
+			logCtx(ctx, "backfill, error", err)
+		}
+	}()
+	return c.JSON(http.StatusAccepted, &accepted)
+}
+
+// runBackfill is the backfill command. It logs progress every
+// BACKFILL_BATCH dates and can be re-run with the same flags after a crash.
+func runBackfill(w io.Writer, flags *Flags) int {
+	r, err := newDateRangeMax(flags.Start, flags.End, 0)
+	if err != nil || r.Start == "" || r.End == "" {
+		fmt.Fprintln(w, "backfill: -start and -end must be YYYY-MM-DD dates in order")
+		return 1
+	}
+	if err := p.Open(); err != nil {
+		fmt.Fprintln(w, "backfill:", err)
+		return 1
+	}
+
+	run, release, err := startBackfill(p, r.Start, r.End, flags.Force)
+	if err != nil {
+		fmt.Fprintln(w, "backfill:", err)
+		return 1
+	}
+	defer release()
+	if run.Resumed > 0 {
+		fmt.Fprintf(w, "resuming run %s after %s\n", run.ID.Hex(), run.Checkpoint)
+	}
+	if err := backfill(context.Background(), p, run); err != nil {
+		fmt.Fprintln(w, "backfill:", err)
+		return 1
+	}
+	fmt.Fprintf(w, "%d inserted, %d updated, %d unavailable (run %s)\n",
+		len(run.Inserted), len(run.Updated), len(run.Unavailable), run.ID.Hex())
+	return 0
+}
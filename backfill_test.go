@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+const backfillTestFile = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<Cube>
+		<Cube time="2024-01-31"><Cube currency="USD" rate="1.0837"/></Cube>
+		<Cube time="2024-01-30"><Cube currency="USD" rate="1.0846"/></Cube>
+		<Cube time="2024-01-29"><Cube currency="USD" rate="1.0823"/></Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+func TestBackfillFillsGap(t *testing.T) {
+	store := testStore(t)
+	testECB(t, backfillTestFile)
+
+	for _, seed := range []*Rate{
+		rateOf("2024-01-29", map[string]float32{"USD": 1.5}),
+		rateOf("2024-01-31", map[string]float32{"USD": 1.5}),
+	} {
+		seed.Source = "ecb"
+		if _, err := store.Save(seed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if gaps, err := findGaps(store, "2024-01-29", "2024-01-31"); err != nil || len(gaps) != 1 || gaps[0] != "2024-01-30" {
+		t.Fatalf("gaps before backfill = %v, %v", gaps, err)
+	}
+
+	run, release, err := startBackfill(store, "2024-01-29", "2024-01-31", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = backfill(context.Background(), store, run)
+	release()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(run.Inserted) != 1 || run.Inserted[0] != "2024-01-30" || len(run.Updated) != 0 {
+		t.Errorf("inserted %v, updated %v, want only 2024-01-30 inserted", run.Inserted, run.Updated)
+	}
+	if gaps, err := findGaps(store, "2024-01-29", "2024-01-31"); err != nil || len(gaps) != 0 {
+		t.Errorf("gaps after backfill = %v, %v", gaps, err)
+	}
+	kept, err := store.FindByDate("2024-01-29")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := kept.RateMap()["USD"]; got != 1.5 {
+		t.Errorf("existing 2024-01-29 USD = %v, want it left at 1.5", got)
+	}
+}
+
+func TestBackfillPostProcessors(t *testing.T) {
+	store := testStore(t)
+	testECB(t, backfillTestFile)
+	defer func(prev Config) { *config = prev }(*config)
+	config.PostProcessors = []string{"outliers", "precision"}
+	config.OutlierMaxPct = 0.15
+	config.RatePrecision = 2
+
+	seed := rateOf("2024-01-29", map[string]float32{"USD": 1.0823})
+	seed.Source = "ecb"
+	if _, err := store.Save(seed); err != nil {
+		t.Fatal(err)
+	}
+
+	run, release, err := startBackfill(store, "2024-01-29", "2024-01-31", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = backfill(context.Background(), store, run)
+	release()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2024-01-30 moved 0.21% and is rejected; 2024-01-31 moved 0.13%.
+	if len(run.Rejected) != 1 || run.Rejected[0] != "2024-01-30" {
+		t.Errorf("rejected %v, want [2024-01-30]", run.Rejected)
+	}
+	if len(run.Inserted) != 1 || run.Inserted[0] != "2024-01-31" {
+		t.Errorf("inserted %v, want [2024-01-31]", run.Inserted)
+	}
+	saved, err := store.FindByDate("2024-01-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := saved.RateMap()["USD"]; got != 1.08 {
+		t.Errorf("2024-01-31 USD = %v, want it rounded to 1.08", got)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+)
+
+const BASELINES_COLLECTION = "baselines"
+
+var baselineNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Baseline is a named copy of the latest rates, kept as a reference point
+// for later diffs.
+type Baseline struct {
+	Name      string             `bson:"_id" json:"name"`
+	RateDate  string             `bson:"rate_date" json:"date"`
+	Rates     map[string]float32 `bson:"rates" json:"rates"`
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+func (p *DB) InsertBaseline(b *Baseline) error {
+	span := p.startSpan("InsertBaseline")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	return db.C(BASELINES_COLLECTION).Insert(b)
+}
+
+func (p *DB) FindBaseline(name string) (*Baseline, error) {
+	span := p.startSpan("FindBaseline")
+	defer span.End()
+
+	var b Baseline
+	err := db.C(BASELINES_COLLECTION).FindId(name).One(&b)
+	return &b, err
+}
+
+// postBaseline saves the current latest rates under ?name=. Names are never
+// overwritten, so a budget baseline can't be moved by accident.
+func postBaseline(c echo.Context) error {
+	name := c.QueryParam("name")
+	if !baselineNameRe.MatchString(name) {
+		return c.JSON(http.StatusBadRequest, "name must be 1-64 letters, digits, '-' or '_'")
+	}
+
+	store := storeFor(c)
+	latest, err := store.GetLatest()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	b := &Baseline{
+		Name:      name,
+		RateDate:  latest.RateDate,
+		Rates:     latest.RateMap(),
+		CreatedAt: time.Now().UTC(),
+	}
+	err = store.InsertBaseline(b)
+	if mgo.IsDup(err) {
+		return c.JSON(http.StatusConflict, "baseline "+name+" already exists")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, b)
+}
+
+// BaselineChange carries ChangePct, or ChangeBps with ?unit=bps.
+type BaselineChange struct {
+	Baseline  float32  `json:"baseline"`
+	Latest    float32  `json:"latest"`
+	ChangePct *float64 `json:"change_pct,omitempty"`
+	ChangeBps *float64 `json:"change_bps,omitempty"`
+}
+
+type BaselineDiffRes struct {
+	Name         string                     `json:"name"`
+	BaselineDate string                     `json:"baseline_date"`
+	LatestDate   string                     `json:"latest_date"`
+	Changes      map[string]*BaselineChange `json:"changes"`
+	// Added and Removed list currencies quoted on only one side.
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+func diffBaseline(b *Baseline, latest *Rate, unit string) *BaselineDiffRes {
+	res := &BaselineDiffRes{
+		Name:         b.Name,
+		BaselineDate: b.RateDate,
+		LatestDate:   latest.RateDate,
+		Changes:      map[string]*BaselineChange{},
+		Added:        []string{},
+		Removed:      []string{},
+	}
+	current := latest.RateMap()
+	for code, cur := range current {
+		old, ok := b.Rates[code]
+		if !ok {
+			res.Added = append(res.Added, code)
+			continue
+		}
+		change := &BaselineChange{Baseline: old, Latest: cur}
+		v := inUnit(percentChange(old, cur), unit)
+		if unit == UnitBps {
+			change.ChangeBps = &v
+		} else {
+			change.ChangePct = &v
+		}
+		res.Changes[code] = change
+	}
+	for code := range b.Rates {
+		if _, ok := current[code]; !ok {
+			res.Removed = append(res.Removed, code)
+		}
+	}
+	sort.Strings(res.Added)
+	sort.Strings(res.Removed)
+	return res
+}
+
+func getBaselineDiff(c echo.Context) error {
+	unit, err := parseUnit(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	store := storeFor(c)
+	b, err := store.FindBaseline(c.Param("name"))
+	if err == mgo.ErrNotFound {
+		return c.JSON(http.StatusNotFound, "no baseline named "+c.Param("name"))
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	latest, err := store.GetLatest()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, diffBaseline(b, &latest, unit))
+}
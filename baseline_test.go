@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestDiffBaseline(t *testing.T) {
+	b := &Baseline{Name: "q1", RateDate: "2024-01-02", Rates: map[string]float32{"USD": 1.00, "GBP": 0.80}}
+	latest := rateOf("2024-01-31", map[string]float32{"USD": 1.01, "JPY": 160})
+
+	res := diffBaseline(b, latest, UnitBps)
+	usd := res.Changes["USD"]
+	if usd == nil || usd.ChangePct != nil || usd.ChangeBps == nil || *usd.ChangeBps != 100 {
+		t.Errorf("USD change in bps = %+v, want 100 bps for a 1%% rise", usd)
+	}
+	if len(res.Added) != 1 || res.Added[0] != "JPY" || len(res.Removed) != 1 || res.Removed[0] != "GBP" {
+		t.Errorf("added %v, removed %v; want [JPY] and [GBP]", res.Added, res.Removed)
+	}
+
+	usd = diffBaseline(b, latest, UnitPct).Changes["USD"]
+	if usd.ChangeBps != nil || usd.ChangePct == nil || *usd.ChangePct != 1 {
+		t.Errorf("USD change in pct = %+v, want 1%%", usd)
+	}
+}
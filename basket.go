@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// WEIGHT_TOLERANCE is how far basket weights may sum from 1.
+const WEIGHT_TOLERANCE = 0.001
+
+type BasketReq struct {
+	Weights Basket `json:"weights"`
+	Date    string `json:"date"`
+	Base    string `json:"base"`
+}
+
+func (r *BasketReq) normalize() error {
+	r.Base = strings.ToUpper(strings.TrimSpace(r.Base))
+	if r.Base == "" {
+		r.Base = "EUR"
+	}
+	if !currencyRe.MatchString(r.Base) {
+		return fmt.Errorf("invalid base currency %q", r.Base)
+	}
+	if len(r.Weights) == 0 {
+		return fmt.Errorf("weights are required")
+	}
+	weights := Basket{}
+	total := 0.0
+	for code, w := range r.Weights {
+		code = strings.ToUpper(code)
+		if !currencyRe.MatchString(code) {
+			return fmt.Errorf("invalid currency code %q", code)
+		}
+		if w <= 0 {
+			return fmt.Errorf("weight of %s must be positive", code)
+		}
+		weights[code] = w
+		total += w
+	}
+	if math.Abs(total-1) > WEIGHT_TOLERANCE {
+		return fmt.Errorf("weights sum to %g, expected 1", total)
+	}
+	r.Weights = weights
+	return nil
+}
+
+type BasketComponent struct {
+	Currency     string  `json:"currency"`
+	Weight       float64 `json:"weight"`
+	Rate         float64 `json:"rate"`
+	Contribution float64 `json:"contribution"`
+}
+
+type BasketValue struct {
+	Date       string             `json:"date"`
+	Value      float64            `json:"value"`
+	Components []*BasketComponent `json:"components,omitempty"`
+}
+
+type BasketValueRes struct {
+	Base    string         `json:"base"`
+	Weights Basket         `json:"weights"`
+	Value   *BasketValue   `json:"value,omitempty"`
+	Series  []*BasketValue `json:"series,omitempty"`
+	Skipped int            `json:"skipped,omitempty"`
+}
+
+// basketValue prices the basket in base units: each component contributes its
+// weight times the base-per-unit rate of its currency.
+func basketValue(rate *Rate, weights Basket, base string) (*BasketValue, error) {
+	rates := rate.RateMap()
+	res := &BasketValue{Date: rate.RateDate, Components: []*BasketComponent{}}
+	for code, w := range weights {
+		cross, err := crossRate(rates, code, base)
+		if err != nil {
+			return nil, err
+		}
+		res.Components = append(res.Components, &BasketComponent{
+			Currency:     code,
+			Weight:       w,
+			Rate:         cross,
+			Contribution: w * cross,
+		})
+		res.Value += w * cross
+	}
+	sort.Slice(res.Components, func(i, j int) bool {
+		return res.Components[i].Currency < res.Components[j].Currency
+	})
+	return res, nil
+}
+
+// postBasketValue values the basket on one date, or over ?start=&end= as a
+// series without the per-component breakdown.
+func postBasketValue(c echo.Context) error {
+	req := &BasketReq{}
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if err := req.normalize(); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+	store := storeFor(c)
+	res := &BasketValueRes{Base: req.Base, Weights: req.Weights}
+
+	if r.Start != "" || r.End != "" {
+		rates, err := store.FindRange(r.Start, r.End)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		res.Series = []*BasketValue{}
+		for i := range rates {
+			v, err := basketValue(&rates[i], req.Weights, req.Base)
+			if err != nil {
+				res.Skipped++
+				continue
+			}
+			v.Components = nil
+			res.Series = append(res.Series, v)
+		}
+		return c.JSON(http.StatusOK, res)
+	}
+
+	rate, err := findRateForDate(store, req.Date)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
+	res.Value, err = basketValue(rate, req.Weights, req.Base)
+	if uerr, ok := err.(*UnknownCurrencyError); ok {
+		return c.JSON(http.StatusUnprocessableEntity, unknownCurrencyEnvelope(uerr, rate))
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, res)
+}
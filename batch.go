@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// ItemResult is the per-item outcome of a batch request.
+type ItemResult struct {
+	Index  int         `json:"index"`
+	Status int         `json:"status"`
+	Error  string      `json:"error,omitempty"`
+	Result interface{} `json:"result,omitempty"`
+}
+
+type BatchRes struct {
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Items     []*ItemResult `json:"items"`
+	// Errors lists the dates whose stored document is corrupt.
+	Errors []*DateError `json:"errors,omitempty"`
+}
+
+type DateError struct {
+	Date  string `json:"date"`
+	Error string `json:"error"`
+}
+
+// newBatchRes tallies items and picks the response status: 200 when every
+// item succeeded, 400 when all failed and 207 Multi-Status for a mix.
+func newBatchRes(items []*ItemResult) (int, *BatchRes) {
+	res := &BatchRes{Items: items}
+	for _, item := range items {
+		if item.Status < 300 {
+			res.Succeeded++
+		} else {
+			res.Failed++
+		}
+	}
+	switch {
+	case res.Failed == 0:
+		return http.StatusOK, res
+	case res.Succeeded == 0:
+		return http.StatusBadRequest, res
+	}
+	return http.StatusMultiStatus, res
+}
+
+func itemError(i, status int, err error) *ItemResult {
+	return &ItemResult{Index: i, Status: status, Error: err.Error()}
+}
+
+// ratesByDate fetches each date's document once per request.
+type ratesByDate struct {
+	store *DB
+	rates map[string]*Rate
+}
+
+func newRatesByDate(store *DB) *ratesByDate {
+	return &ratesByDate{store: store, rates: map[string]*Rate{}}
+}
+
+func (r *ratesByDate) Get(date string) (*Rate, error) {
+	if rate, ok := r.rates[date]; ok {
+		return rate, nil
+	}
+	rate, err := findRateForDate(r.store, date)
+	if err != nil {
+		return nil, err
+	}
+	r.rates[date] = rate
+	return rate, nil
+}
+
+func postConvertBatch(c echo.Context) error {
+	var reqs []*ConvertReq
+	if err := c.Bind(&reqs); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if len(reqs) == 0 {
+		return c.JSON(http.StatusBadRequest, "at least one conversion is required")
+	}
+
+	byDate := newRatesByDate(storeFor(c))
+	items := []*ItemResult{}
+	for i, req := range reqs {
+		if err := req.normalize(); err != nil {
+			items = append(items, itemError(i, http.StatusBadRequest, err))
+			continue
+		}
+		rate, err := byDate.Get(req.Date)
+		if err != nil {
+			items = append(items, itemError(i, http.StatusNotFound, err))
+			continue
+		}
+		res, err := convert(rate, req)
+		if err != nil {
+			items = append(items, itemError(i, http.StatusUnprocessableEntity, err))
+			continue
+		}
+		items = append(items, &ItemResult{Index: i, Status: http.StatusOK, Result: res})
+	}
+
+	return c.JSON(newBatchRes(items))
+}
+
+func getDates(c echo.Context) error {
+	dates := parseList(c.QueryParam("dates"))
+	if len(dates) == 0 {
+		return c.JSON(http.StatusBadRequest, "dates is required")
+	}
+
+	store := storeFor(c)
+	items := []*ItemResult{}
+	var corrupt []*DateError
+	for i, date := range dates {
+		rate, err := findRateForDate(store, strings.TrimSpace(date))
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) {
+			// One bad document must not fail the other dates.
+			logCtx(c.Request().Context(), "dates, skipping", decodeErr)
+			corrupt = append(corrupt, &DateError{Date: decodeErr.Date, Error: decodeErr.Err.Error()})
+			items = append(items, itemError(i, http.StatusInternalServerError, err))
+			continue
+		}
+		if err != nil {
+			items = append(items, itemError(i, http.StatusNotFound, err))
+			continue
+		}
+		items = append(items, &ItemResult{Index: i, Status: http.StatusOK, Result: &DailyRate{
+			Base:  "EUR",
+			Date:  rate.RateDate,
+			Rates: rate.RateMap(),
+		}})
+	}
+
+	status, res := newBatchRes(items)
+	res.Errors = corrupt
+	return c.JSON(status, res)
+}
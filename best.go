@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+type DatedRate struct {
+	Date string  `json:"date"`
+	Rate float64 `json:"rate"`
+}
+
+type BestDayRes struct {
+	From      string     `json:"from"`
+	To        string     `json:"to"`
+	Best      *DatedRate `json:"best"`
+	Worst     *DatedRate `json:"worst"`
+	Spread    float64    `json:"spread"`
+	SpreadPct float64    `json:"spread_pct"`
+	Fixings   int        `json:"fixings"`
+}
+
+// bestDay finds the days with the highest and lowest from->to cross rate,
+// i.e. the most and least target currency per unit. rates must be sorted
+// oldest first so ties keep the earliest date.
+func bestDay(rates []Rate, from, to string) *BestDayRes {
+	res := &BestDayRes{From: from, To: to}
+	for i := range rates {
+		cross, err := crossRate(rates[i].RateMap(), from, to)
+		if err != nil {
+			continue
+		}
+		res.Fixings++
+		if res.Best == nil || cross > res.Best.Rate {
+			res.Best = &DatedRate{Date: rates[i].RateDate, Rate: cross}
+		}
+		if res.Worst == nil || cross < res.Worst.Rate {
+			res.Worst = &DatedRate{Date: rates[i].RateDate, Rate: cross}
+		}
+	}
+	if res.Fixings == 0 {
+		return res
+	}
+	res.Spread = res.Best.Rate - res.Worst.Rate
+	res.SpreadPct = res.Spread / res.Worst.Rate * 100
+	return res
+}
+
+func getConvertBest(c echo.Context) error {
+	req := &ConvertReq{From: c.QueryParam("from"), To: c.QueryParam("to")}
+	if err := req.normalize(); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+	if r.Start == "" || r.End == "" {
+		return c.JSON(http.StatusBadRequest, "start and end are required")
+	}
+
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	res := bestDay(rates, req.From, req.To)
+	if res.Fixings == 0 {
+		return c.JSON(http.StatusNotFound, "no fixings for "+req.From+"/"+req.To+" in range")
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type BetaRes struct {
+	Currency   string     `json:"currency"`
+	Benchmark  string     `json:"benchmark"`
+	Beta       float64    `json:"beta"`
+	SampleSize int        `json:"sample_size"`
+	Range      *DateRange `json:"range"`
+}
+
+func getBeta(c echo.Context) error {
+	currency := strings.ToUpper(c.QueryParam("currency"))
+	if !currencyRe.MatchString(currency) {
+		return c.JSON(http.StatusBadRequest, "invalid currency")
+	}
+	benchmark, err := parseBasket(c.QueryParam("benchmark"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "benchmark: "+err.Error())
+	}
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	aligned := alignReturns(rates, Basket{currency: 1}, benchmark)
+	if len(aligned.A) < MIN_OBSERVATIONS {
+		return c.JSON(http.StatusUnprocessableEntity, "not enough overlapping observations")
+	}
+	v := variance(aligned.B)
+	if v == 0 {
+		return c.JSON(http.StatusUnprocessableEntity, "benchmark returns have zero variance")
+	}
+
+	res := &BetaRes{
+		Currency:   currency,
+		Benchmark:  c.QueryParam("benchmark"),
+		Beta:       covariance(aligned.A, aligned.B) / v,
+		SampleSize: len(aligned.A),
+		Range: &DateRange{
+			Start: aligned.Dates[0],
+			End:   aligned.Dates[len(aligned.Dates)-1],
+		},
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+var errCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitBreaker fails calls fast after Threshold consecutive failures. Once
+// Cooldown has passed a single probe is let through; its outcome closes the
+// breaker again or restarts the cool-down.
+type CircuitBreaker struct {
+	Name      string
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+}
+
+type BreakerStatus struct {
+	State    string    `json:"state"`
+	Failures int       `json:"failures"`
+	OpenedAt time.Time `json:"openedAt,omitempty"`
+}
+
+func NewCircuitBreaker(name string, threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		Name:      name,
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+func (b *CircuitBreaker) allow(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.Cooldown {
+			return errCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		return nil
+	case BreakerHalfOpen:
+		// a probe is already in flight
+		return errCircuitOpen
+	}
+	return nil
+}
+
+func (b *CircuitBreaker) record(err error, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.state = BreakerClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.Threshold {
+		b.state = BreakerOpen
+		b.openedAt = now
+	}
+}
+
+// Call runs fn unless the breaker is open. Errors for which ignore returns
+// true are treated as success.
+func (b *CircuitBreaker) Call(fn func() error, ignore func(error) bool) error {
+	if err := b.allow(time.Now()); err != nil {
+		return err
+	}
+	err := fn()
+	if err != nil && ignore != nil && ignore(err) {
+		b.record(nil, time.Now())
+		return err
+	}
+	b.record(err, time.Now())
+	return err
+}
+
+func (b *CircuitBreaker) Status() *BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := &BreakerStatus{State: b.state, Failures: b.failures}
+	if b.state != BreakerClosed {
+		s.OpenedAt = b.openedAt
+	}
+	return s
+}
@@ -0,0 +1,37 @@
+package main
+
+import "sync"
+
+// Broadcaster fans rate updates out to subscribed push clients. Slow
+// subscribers miss updates rather than blocking ingestion.
+type Broadcaster struct {
+	mu   sync.Mutex
+	subs map[chan *DailyRate]struct{}
+}
+
+var broadcaster = &Broadcaster{subs: map[chan *DailyRate]struct{}{}}
+
+func (b *Broadcaster) Subscribe() chan *DailyRate {
+	ch := make(chan *DailyRate, 4)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *Broadcaster) Unsubscribe(ch chan *DailyRate) {
+	b.mu.Lock()
+	delete(b.subs, ch)
+	b.mu.Unlock()
+}
+
+func (b *Broadcaster) Publish(rate *DailyRate) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- rate:
+		default:
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+type Cache struct {
+	mu       sync.RWMutex
+	latest   *DailyRate
+	analysis *RateAnalysisRes
+	summary  *SummaryRes
+}
+
+var cache = &Cache{}
+
+func (c *Cache) Latest() *DailyRate {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.latest
+}
+
+func (c *Cache) SetLatest(res *DailyRate) {
+	c.mu.Lock()
+	c.latest = res
+	c.mu.Unlock()
+}
+
+func (c *Cache) Analysis() *RateAnalysisRes {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.analysis
+}
+
+func (c *Cache) SetAnalysis(res *RateAnalysisRes) {
+	c.mu.Lock()
+	c.analysis = res
+	c.mu.Unlock()
+}
+
+func (c *Cache) Summary() *SummaryRes {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.summary
+}
+
+func (c *Cache) SetSummary(res *SummaryRes) {
+	c.mu.Lock()
+	c.summary = res
+	c.mu.Unlock()
+}
+
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	c.latest = nil
+	c.analysis = nil
+	c.summary = nil
+	c.mu.Unlock()
+}
+
+// warmup recomputes the latest and default analysis entries right after a
+// refresh so the first client request doesn't pay for a cold cache.
+func warmup() {
+	start := time.Now()
+
+	if res, err := buildLatest(p); err != nil {
+		log.Println("warmup, error on buildLatest", err)
+	} else {
+		cache.SetLatest(res)
+	}
+
+	if res, err := buildAnalysis(p); err != nil {
+		log.Println("warmup, error on buildAnalysis", err)
+	} else {
+		cache.SetAnalysis(res)
+	}
+
+	log.Println("warmup done in", time.Since(start))
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestRefreshWarmsCache(t *testing.T) {
+	defer func(prev bool) { config.WarmupCache = prev }(config.WarmupCache)
+	defer cache.Invalidate()
+
+	for _, warm := range []bool{true, false} {
+		testStore(t)
+		testECB(t, ecbTestFile)
+		config.WarmupCache = warm
+		cache.SetLatest(&DailyRate{Date: "2024-01-02"})
+		cache.SetAnalysis(&RateAnalysisRes{})
+
+		if _, err := Refresh(TriggerManual); err != nil {
+			t.Fatal(err)
+		}
+		latest, analysis := cache.Latest(), cache.Analysis()
+		if !warm {
+			if latest != nil || analysis != nil {
+				t.Errorf("without warmup the refresh left %+v and %+v cached", latest, analysis)
+			}
+			continue
+		}
+		if latest == nil || latest.Date != "2024-01-31" {
+			t.Errorf("latest after refresh = %+v, want 2024-01-31 without a request", latest)
+		}
+		if analysis == nil || analysis.Rates["USD"] == nil {
+			t.Errorf("analysis after refresh = %+v, want it rebuilt", analysis)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"text/tabwriter"
+	"time"
+)
+
+type Check struct {
+	Name string
+	Run  func() error
+}
+
+var checks = []Check{
+	{"mongo", checkMongo},
+	{"indexes", checkIndexes},
+	{"provider", checkProvider},
+	{"config", checkConfig},
+	{"freshness", checkDataFreshness},
+	{"schema", checkSchemaVersion},
+}
+
+// runChecks runs every check in order, prints a table and returns the
+// process exit code. Checks after a failed mongo connection still run so the
+// table is complete.
+func runChecks(w io.Writer) int {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL")
+	code := 0
+	for _, check := range checks {
+		status, detail := "ok", ""
+		if err := check.Run(); err != nil {
+			status, detail = "FAIL", err.Error()
+			code = 1
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", check.Name, status, detail)
+	}
+	tw.Flush()
+	return code
+}
+
+// checkMongo only dials and pings; check must not create the indexes the
+// next check looks for.
+func checkMongo() error {
+	if db != nil {
+		return db.Session.Ping()
+	}
+	return p.Dial()
+}
+
+func checkIndexes() error {
+	if db == nil {
+		return fmt.Errorf("not connected")
+	}
+	indexes, err := db.C(config.Collection).Indexes()
+	if err != nil {
+		return err
+	}
+	for _, idx := range indexes {
+		if len(idx.Key) > 0 && idx.Key[0] == "rate_date" {
+			return nil
+		}
+	}
+	return fmt.Errorf("missing index on rate_date")
+}
+
+func checkProvider() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequest("HEAD", ECB_URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := ecbClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s returned %s", ECB_URL, resp.Status)
+	}
+	return nil
+}
+
+func checkConfig() error {
+	if errs := config.Validate(); len(errs) > 0 {
+		return errs[0]
+	}
+	if config.AdminAPIKey == "" {
+		return fmt.Errorf("ADMIN_API_KEY is empty, admin endpoints will reject every request")
+	}
+	return nil
+}
+
+func checkDataFreshness() error {
+	if db == nil {
+		return fmt.Errorf("not connected")
+	}
+	f, err := checkFreshness(p, time.Now())
+	if err != nil {
+		return err
+	}
+	if f.Stale {
+		return fmt.Errorf("latest date %s is %d business days old", f.LatestDate, f.AgeBusinessDays)
+	}
+	return nil
+}
+
+func checkSchemaVersion() error {
+	if db == nil {
+		return fmt.Errorf("not connected")
+	}
+	n, err := p.CountNewer(SCHEMA_VERSION)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("%d documents are newer than schema version %d", n, SCHEMA_VERSION)
+	}
+	outdated, err := db.C(config.Collection).Find(olderThan(SCHEMA_VERSION)).Count()
+	if err != nil {
+		return err
+	}
+	if outdated > 0 {
+		return fmt.Errorf("%d documents need migrate to reach schema version %d", outdated, SCHEMA_VERSION)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestChecksNotConnected(t *testing.T) {
+	for _, check := range []Check{
+		{"indexes", checkIndexes},
+		{"freshness", checkDataFreshness},
+		{"schema", checkSchemaVersion},
+	} {
+		if err := check.Run(); err == nil || err.Error() != "not connected" {
+			t.Errorf("%s without a connection: %v, want not connected", check.Name, err)
+		}
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	defer func(prev Config) { *config = prev }(*config)
+	tests := []struct {
+		name    string
+		port    string
+		key     string
+		wantErr bool
+	}{
+		{"ok", "3000", "s3cret", false},
+		{"invalid", "0", "s3cret", true},
+		{"no admin key", "3000", "", true},
+	}
+	for _, tt := range tests {
+		config.Port, config.AdminAPIKey = tt.port, tt.key
+		if err := checkConfig(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: checkConfig() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckProvider(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusServiceUnavailable} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+		}))
+		target, _ := url.Parse(srv.URL)
+		prev := ecbClient
+		ecbClient = &http.Client{Transport: rewriteHost{target}}
+		err := checkProvider()
+		ecbClient = prev
+		srv.Close()
+		if (err != nil) != (status >= 400) {
+			t.Errorf("provider answering %d: checkProvider() = %v", status, err)
+		}
+	}
+}
+
+func TestCheckIndexes(t *testing.T) {
+	store := testStore(t)
+	if _, err := store.Save(rateOf("2024-01-31", map[string]float32{"USD": 1.0837})); err != nil {
+		t.Fatal(err)
+	}
+
+	// Dial the throwaway database the way the check command does.
+	defer func(prev Config) { *config = prev }(*config)
+	config.MongoHost, config.DBName = os.Getenv("MONGO_TEST_HOST"), db.Name
+	own := db
+	db = nil
+	t.Cleanup(func() {
+		if db != nil && db != own {
+			db.Session.Close()
+		}
+		db = own
+	})
+	if err := checkMongo(); err != nil {
+		t.Fatalf("checkMongo: %v", err)
+	}
+
+	if err := checkIndexes(); err == nil {
+		t.Error("checkIndexes passed before EnsureIndexes; checkMongo must not create them")
+	}
+	if err := store.EnsureIndexes(); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkIndexes(); err != nil {
+		t.Errorf("checkIndexes after EnsureIndexes: %v", err)
+	}
+}
+
+func TestCheckDataFreshness(t *testing.T) {
+	store := testStore(t)
+	defer func(prev int) { config.StaleThresholdDays = prev }(config.StaleThresholdDays)
+	config.StaleThresholdDays = 3
+
+	old := time.Now().UTC().AddDate(0, 0, -30).Format(DATE_LAYOUT)
+	if _, err := store.Save(rateOf(old, map[string]float32{"USD": 1.08})); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkDataFreshness(); err == nil {
+		t.Errorf("latest date %s passed the freshness check", old)
+	}
+	today := time.Now().UTC().Format(DATE_LAYOUT)
+	if _, err := store.Save(rateOf(today, map[string]float32{"USD": 1.08})); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkDataFreshness(); err != nil {
+		t.Errorf("latest date %s: %v", today, err)
+	}
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	store := testStore(t)
+	if _, err := store.Save(rateOf("2024-01-31", map[string]float32{"USD": 1.0837})); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSchemaVersion(); err != nil {
+		t.Errorf("current documents: %v", err)
+	}
+	newer := bson.M{"_id": bson.NewObjectId(), "rate_date": "2024-02-01", "schema_version": SCHEMA_VERSION + 1}
+	if err := db.C(config.Collection).Insert(newer); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSchemaVersion(); err == nil {
+		t.Error("a document newer than SCHEMA_VERSION passed the schema check")
+	}
+}
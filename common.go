@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (p *DB) FindLatestWithAll(symbols []string) (*Rate, error) {
+	span := p.startSpan("FindLatestWithAll")
+	defer span.End()
+
+	var rate Rate
+	err := db.C(config.Collection).Find(live(bson.M{"rates.currency": bson.M{"$all": symbols}})).Sort("-rate_date").One(&rate)
+	return &rate, err
+}
+
+func getCommonLatest(c echo.Context) error {
+	symbols, err := parseSymbols(c.QueryParam("symbols"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if len(symbols) == 0 {
+		return c.JSON(http.StatusBadRequest, "symbols is required")
+	}
+
+	rate, err := storeFor(c).FindLatestWithAll(symbols)
+	if err == mgo.ErrNotFound {
+		return c.JSON(http.StatusNotFound, "no date has rates for all of the requested currencies")
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	all := rate.RateMap()
+	rates := map[string]float32{}
+	for _, sym := range symbols {
+		rates[sym] = all[sym]
+	}
+
+	res := &DailyRate{
+		Base:  "EUR",
+		Date:  rate.RateDate,
+		Rates: rates,
+	}
+	return jsonFields(c, http.StatusOK, res)
+}
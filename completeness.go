@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo"
+)
+
+type DateCompleteness struct {
+	Date    string   `json:"date"`
+	Count   int      `json:"count"`
+	Missing []string `json:"missing"`
+}
+
+type CompletenessRes struct {
+	Range    *DateRange          `json:"range"`
+	Expected []string            `json:"expected"`
+	Dates    []*DateCompleteness `json:"dates"`
+}
+
+// completeness reports per date which expected currencies are missing.
+// Without a configured list the expectation is the union over rates.
+func completeness(rates []Rate, expected []string) *CompletenessRes {
+	if len(expected) == 0 {
+		union := map[string]bool{}
+		for i := range rates {
+			for _, item := range rates[i].Rates {
+				union[item.Currency] = true
+			}
+		}
+		for code := range union {
+			expected = append(expected, code)
+		}
+	}
+	sort.Strings(expected)
+
+	res := &CompletenessRes{Expected: expected, Dates: []*DateCompleteness{}}
+	for i := range rates {
+		present := rates[i].RateMap()
+		d := &DateCompleteness{Date: rates[i].RateDate, Count: len(present), Missing: []string{}}
+		for _, code := range expected {
+			if _, ok := present[code]; !ok {
+				d.Missing = append(d.Missing, code)
+			}
+		}
+		res.Dates = append(res.Dates, d)
+	}
+	return res
+}
+
+func getCompleteness(c echo.Context) error {
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+	order := c.QueryParam("sort")
+	if order != "" && order != "date" && order != "missing" {
+		return c.JSON(http.StatusBadRequest, "sort must be date or missing")
+	}
+
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	res := completeness(rates, append([]string{}, config.ExpectedCurrencies...))
+	res.Range = r
+	if order == "missing" {
+		sort.SliceStable(res.Dates, func(i, j int) bool {
+			return len(res.Dates[i].Missing) > len(res.Dates[j].Missing)
+		})
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,602 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is resolved with precedence flags > env > config file > defaults.
+// Fields tagged secret:"true" are redacted whenever the config is printed;
+// secret:"userinfo" only masks the password of a URL.
+type Config struct {
+	MongoHost  string `yaml:"mongo_host" secret:"userinfo"`
+	DBName     string `yaml:"db_name"`
+	Collection string `yaml:"collection"`
+
+	Port            string        `yaml:"port"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	WarmupCache     bool          `yaml:"warmup_cache"`
+	AdminAPIKey     string        `yaml:"admin_api_key" secret:"true"`
+	EnablePprof     bool          `yaml:"enable_pprof"`
+	PprofAddr       string        `yaml:"pprof_addr"`
+
+	Timezone string `yaml:"timezone"`
+	// ExpectedCurrencies is the set /admin/completeness checks each date
+	// against; empty means the union over the requested range.
+	ExpectedCurrencies []string `yaml:"expected_currencies"`
+	Holidays           []string `yaml:"holidays"`
+	CSVMaxRows         int      `yaml:"csv_max_rows"`
+
+	DerivedRates map[string]DerivedRate `yaml:"derived_rates"`
+
+	// APIKeys maps a partner name to its X-API-Key and daily quota. The keys
+	// are redacted when the config is printed.
+	APIKeys            map[string]APIKey `yaml:"api_keys"`
+	UsageFlushInterval time.Duration     `yaml:"usage_flush_interval"`
+	// AnonymousQuota is the daily quota per client IP of requests without
+	// X-API-Key; 0 leaves them unlimited.
+	AnonymousQuota int `yaml:"anonymous_daily_quota"`
+
+	SSEHeartbeat time.Duration `yaml:"sse_heartbeat"`
+
+	StaleThresholdDays int `yaml:"stale_threshold_days"`
+	StaleStatus        int `yaml:"stale_status"`
+
+	DisabledFeatures      []string `yaml:"disabled_features"`
+	FeatureDisabledStatus int      `yaml:"feature_disabled_status"`
+
+	BreakerFailures int           `yaml:"breaker_failures"`
+	BreakerCooldown time.Duration `yaml:"breaker_cooldown"`
+	LockTTL         time.Duration `yaml:"lock_ttl"`
+
+	IngestRunRetention int `yaml:"ingest_run_retention"`
+
+	MaxAggregationResults int `yaml:"max_aggregation_results"`
+
+	// MaxRangeDays caps the start to end span of the public range params;
+	// 0 disables the check. Admin routes are exempt.
+	MaxRangeDays int `yaml:"max_range_days"`
+	// MaxExportDays is the same cap for /rates/export, which streams and can
+	// afford a longer span.
+	MaxExportDays int `yaml:"max_export_days"`
+
+	PostProcessors []string `yaml:"post_processors"`
+	RatePrecision  int      `yaml:"rate_precision"`
+	OutlierMaxPct  float64  `yaml:"outlier_max_pct"`
+
+	QuarantineOutliers bool `yaml:"quarantine_outliers"`
+
+	CacheFile string `yaml:"cache_file"`
+
+	// IdempotencyTTL is how long a response sent with an Idempotency-Key
+	// is replayed.
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl"`
+	// IdempotencyLease is how long a key stays claimed by a request that
+	// never finishes, e.g. because the process died.
+	IdempotencyLease time.Duration `yaml:"idempotency_lease"`
+
+	// IngestQueueSize bounds the batches kept while Mongo is unavailable;
+	// IngestSpoolFile, when set, keeps them across restarts.
+	IngestQueueSize int    `yaml:"ingest_queue_size"`
+	IngestSpoolFile string `yaml:"ingest_spool_file"`
+
+	// ReadOnly disables ingestion and every route and store method that
+	// writes; see readonly.go.
+	ReadOnly bool `yaml:"read_only"`
+
+	// ArchiveAfterDays moves documents older than this many days to
+	// rates_archive on every scheduled refresh; 0 disables archiving.
+	ArchiveAfterDays int `yaml:"archive_after_days"`
+
+	// SourcePrecedence decides which source keeps a date when several
+	// write it; see sourceWins.
+	SourcePrecedence []string `yaml:"source_precedence"`
+
+	// SchemaCheck is "fail" or "warn": what to do at startup when the
+	// collection holds documents newer than SCHEMA_VERSION.
+	SchemaCheck string `yaml:"schema_check"`
+
+	// ECBProxy is the proxy for ECB requests; when empty HTTP_PROXY and
+	// HTTPS_PROXY apply. ECBCAFile is a PEM bundle trusted on top of the
+	// system roots, for proxies that intercept TLS.
+	ECBProxy  string `yaml:"ecb_proxy" secret:"userinfo"`
+	ECBCAFile string `yaml:"ecb_ca_file"`
+
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	OTLPInsecure bool   `yaml:"otlp_insecure"`
+}
+
+var config = defaultConfig()
+
+func defaultConfig() *Config {
+	return &Config{
+		MongoHost:             SERVER,
+		DBName:                DBNAME,
+		Collection:            COLLECTION,
+		Port:                  "3000",
+		WarmupCache:           true,
+		StaleThresholdDays:    3,
+		StaleStatus:           http.StatusServiceUnavailable,
+		Timezone:              "Europe/Berlin",
+		Holidays:              []string{},
+		ExpectedCurrencies:    []string{},
+		CSVMaxRows:            10000,
+		DerivedRates:          map[string]DerivedRate{},
+		APIKeys:               map[string]APIKey{},
+		UsageFlushInterval:    5 * time.Second,
+		AnonymousQuota:        0,
+		SSEHeartbeat:          15 * time.Second,
+		DisabledFeatures:      []string{},
+		FeatureDisabledStatus: http.StatusNotFound,
+		BreakerFailures:       5,
+		BreakerCooldown:       5 * time.Minute,
+		LockTTL:               time.Minute,
+		IngestRunRetention:    500,
+		IngestQueueSize:       10,
+		IdempotencyTTL:        24 * time.Hour,
+		IdempotencyLease:      5 * time.Minute,
+		MaxAggregationResults: 1000,
+		MaxRangeDays:          366,
+		MaxExportDays:         3660,
+		PostProcessors:        []string{},
+		RatePrecision:         6,
+		OutlierMaxPct:         20,
+		SchemaCheck:           "fail",
+		SourcePrecedence:      []string{"ecb", "import", "seed"},
+	}
+}
+
+type Flags struct {
+	ConfigPath  string
+	PrintConfig bool
+	DryRun      bool
+	Port        string
+	// Days defaults per command: 30 for purge, 120 for seed.
+	Days       int
+	Currencies string
+	Seed       int64
+	Start      string
+	End        string
+	Force      bool
+}
+
+func parseFlags(args []string) (*Flags, error) {
+	f := &Flags{}
+	fs := flag.NewFlagSet("currencyrate", flag.ContinueOnError)
+	fs.StringVar(&f.ConfigPath, "config", "", "path to a YAML config file")
+	fs.BoolVar(&f.PrintConfig, "print-config", false, "print the effective configuration and exit")
+	fs.BoolVar(&f.DryRun, "dry-run", false, "fetch the provider data, print what an ingest would change and exit; with dedupe, report without writing")
+	fs.StringVar(&f.Port, "port", "", "HTTP listen port")
+	fs.IntVar(&f.Days, "days", 0, "purge: remove documents soft-deleted more than this many days ago; seed: business days to generate")
+	fs.StringVar(&f.Currencies, "currencies", "USD,GBP,JPY,CHF", "seed: comma-separated currencies to generate")
+	fs.Int64Var(&f.Seed, "seed", 1, "seed: random seed; the same seed generates the same data")
+	fs.StringVar(&f.Start, "start", "", "backfill: first date to ingest, YYYY-MM-DD")
+	fs.StringVar(&f.End, "end", "", "backfill: last date to ingest, YYYY-MM-DD")
+	fs.BoolVar(&f.Force, "force", false, "backfill: overwrite dates that are already stored")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// loadConfig layers the config file, the environment and the flags over the
+// defaults and returns every problem found rather than stopping at the first.
+func loadConfig(flags *Flags) (*Config, []error) {
+	cfg := defaultConfig()
+	var errs []error
+
+	if flags.ConfigPath != "" {
+		errs = append(errs, loadConfigFile(cfg, flags.ConfigPath)...)
+	}
+
+	env := &envLoader{}
+	env.str("MONGO_HOST", &cfg.MongoHost)
+	env.str("MONGO_DB", &cfg.DBName)
+	env.str("MONGO_COLLECTION", &cfg.Collection)
+	env.str("PORT", &cfg.Port)
+	env.duration("REFRESH_INTERVAL", &cfg.RefreshInterval)
+	env.boolean("WARMUP_CACHE", &cfg.WarmupCache)
+	env.str("ADMIN_API_KEY", &cfg.AdminAPIKey)
+	env.boolean("ENABLE_PPROF", &cfg.EnablePprof)
+	env.str("PPROF_ADDR", &cfg.PprofAddr)
+	env.str("TIMEZONE", &cfg.Timezone)
+	env.list("HOLIDAYS", &cfg.Holidays)
+	env.list("EXPECTED_CURRENCIES", &cfg.ExpectedCurrencies)
+	env.integer("CSV_MAX_ROWS", &cfg.CSVMaxRows)
+	env.duration("SSE_HEARTBEAT", &cfg.SSEHeartbeat)
+	env.derived("DERIVED_RATES", &cfg.DerivedRates)
+	env.apiKeys("API_KEYS", &cfg.APIKeys)
+	env.duration("USAGE_FLUSH_INTERVAL", &cfg.UsageFlushInterval)
+	env.integer("ANONYMOUS_DAILY_QUOTA", &cfg.AnonymousQuota)
+	env.integer("STALE_THRESHOLD_DAYS", &cfg.StaleThresholdDays)
+	env.integer("STALE_STATUS", &cfg.StaleStatus)
+	env.list("DISABLED_FEATURES", &cfg.DisabledFeatures)
+	env.integer("FEATURE_DISABLED_STATUS", &cfg.FeatureDisabledStatus)
+	env.integer("BREAKER_FAILURES", &cfg.BreakerFailures)
+	env.duration("BREAKER_COOLDOWN", &cfg.BreakerCooldown)
+	env.duration("LOCK_TTL", &cfg.LockTTL)
+	env.integer("INGEST_RUN_RETENTION", &cfg.IngestRunRetention)
+	env.integer("MAX_AGGREGATION_RESULTS", &cfg.MaxAggregationResults)
+	env.integer("MAX_RANGE_DAYS", &cfg.MaxRangeDays)
+	env.integer("MAX_EXPORT_DAYS", &cfg.MaxExportDays)
+	env.list("POST_PROCESSORS", &cfg.PostProcessors)
+	env.integer("RATE_PRECISION", &cfg.RatePrecision)
+	env.float("OUTLIER_MAX_PCT", &cfg.OutlierMaxPct)
+	env.boolean("QUARANTINE_OUTLIERS", &cfg.QuarantineOutliers)
+	env.str("CACHE_FILE", &cfg.CacheFile)
+	env.duration("IDEMPOTENCY_TTL", &cfg.IdempotencyTTL)
+	env.duration("IDEMPOTENCY_LEASE", &cfg.IdempotencyLease)
+	env.integer("INGEST_QUEUE_SIZE", &cfg.IngestQueueSize)
+	env.str("INGEST_SPOOL_FILE", &cfg.IngestSpoolFile)
+	env.boolean("READ_ONLY", &cfg.ReadOnly)
+	env.str("SCHEMA_CHECK", &cfg.SchemaCheck)
+	env.list("SOURCE_PRECEDENCE", &cfg.SourcePrecedence)
+	env.integer("ARCHIVE_AFTER_DAYS", &cfg.ArchiveAfterDays)
+	env.str("ECB_PROXY", &cfg.ECBProxy)
+	env.str("ECB_CA_FILE", &cfg.ECBCAFile)
+	env.str("OTEL_EXPORTER_OTLP_ENDPOINT", &cfg.OTLPEndpoint)
+	env.boolean("OTEL_EXPORTER_OTLP_INSECURE", &cfg.OTLPInsecure)
+	errs = append(errs, env.errs...)
+
+	if flags.Port != "" {
+		cfg.Port = flags.Port
+	}
+
+	errs = append(errs, cfg.Validate()...)
+	return cfg, errs
+}
+
+func loadConfigFile(cfg *Config, path string) []error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+	if err := yaml.Unmarshal(b, cfg); err != nil {
+		return []error{fmt.Errorf("%s: %v", path, err)}
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return []error{fmt.Errorf("%s: %v", path, err)}
+	}
+	known := map[string]bool{}
+	t := reflect.TypeOf(*cfg)
+	for i := 0; i < t.NumField(); i++ {
+		known[t.Field(i).Tag.Get("yaml")] = true
+	}
+	for key := range raw {
+		if !known[key] {
+			log.Printf("config: %s: unknown key %q ignored", path, key)
+		}
+	}
+	return nil
+}
+
+func (c *Config) Validate() []error {
+	var errs []error
+	if c.MongoHost == "" {
+		errs = append(errs, fmt.Errorf("mongo_host: must not be empty"))
+	}
+	if c.DBName == "" {
+		errs = append(errs, fmt.Errorf("db_name: must not be empty"))
+	}
+	if c.Collection == "" {
+		errs = append(errs, fmt.Errorf("collection: must not be empty"))
+	}
+	if n, err := strconv.Atoi(c.Port); err != nil || n < 1 || n > 65535 {
+		errs = append(errs, fmt.Errorf("port: %q is not a valid port", c.Port))
+	}
+	if c.RefreshInterval < 0 {
+		errs = append(errs, fmt.Errorf("refresh_interval: must not be negative"))
+	}
+	if c.PprofAddr != "" {
+		if _, _, err := net.SplitHostPort(c.PprofAddr); err != nil {
+			errs = append(errs, fmt.Errorf("pprof_addr: %v", err))
+		}
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		errs = append(errs, fmt.Errorf("timezone: %v", err))
+	}
+	for _, code := range c.ExpectedCurrencies {
+		if !currencyRe.MatchString(code) {
+			errs = append(errs, fmt.Errorf("expected_currencies: %q is not a currency code", code))
+		}
+	}
+	for _, h := range c.Holidays {
+		if _, err := time.Parse(DATE_LAYOUT, h); err != nil {
+			errs = append(errs, fmt.Errorf("holidays: %q is not a YYYY-MM-DD date", h))
+		}
+	}
+	if c.CSVMaxRows < 1 {
+		errs = append(errs, fmt.Errorf("csv_max_rows: must be at least 1"))
+	}
+	for code, d := range c.DerivedRates {
+		if !currencyRe.MatchString(code) || !currencyRe.MatchString(d.Pivot) {
+			errs = append(errs, fmt.Errorf("derived_rates: %s/%s is not a pair of currency codes", code, d.Pivot))
+		}
+		if d.Factor <= 0 {
+			errs = append(errs, fmt.Errorf("derived_rates: factor for %s must be positive", code))
+		}
+	}
+	if c.SSEHeartbeat <= 0 {
+		errs = append(errs, fmt.Errorf("sse_heartbeat: must be positive"))
+	}
+	if c.StaleThresholdDays < 0 {
+		errs = append(errs, fmt.Errorf("stale_threshold_days: must not be negative"))
+	}
+	if http.StatusText(c.StaleStatus) == "" {
+		errs = append(errs, fmt.Errorf("stale_status: %d is not an HTTP status", c.StaleStatus))
+	}
+	if http.StatusText(c.FeatureDisabledStatus) == "" {
+		errs = append(errs, fmt.Errorf("feature_disabled_status: %d is not an HTTP status", c.FeatureDisabledStatus))
+	}
+	if c.BreakerFailures < 1 {
+		errs = append(errs, fmt.Errorf("breaker_failures: must be at least 1"))
+	}
+	if c.BreakerCooldown <= 0 {
+		errs = append(errs, fmt.Errorf("breaker_cooldown: must be positive"))
+	}
+	if c.LockTTL < 3*time.Second {
+		errs = append(errs, fmt.Errorf("lock_ttl: must be at least 3s"))
+	}
+	if c.MaxAggregationResults < 1 {
+		errs = append(errs, fmt.Errorf("max_aggregation_results: must be at least 1"))
+	}
+	if c.MaxRangeDays < 0 {
+		errs = append(errs, fmt.Errorf("max_range_days: must not be negative"))
+	}
+	if c.MaxExportDays < 0 {
+		errs = append(errs, fmt.Errorf("max_export_days: must not be negative"))
+	}
+	if c.IngestRunRetention < 1 {
+		errs = append(errs, fmt.Errorf("ingest_run_retention: must be at least 1"))
+	}
+	if c.IdempotencyTTL < time.Minute {
+		errs = append(errs, fmt.Errorf("idempotency_ttl: must be at least 1m"))
+	}
+	if c.IdempotencyLease < time.Second || c.IdempotencyLease > c.IdempotencyTTL {
+		errs = append(errs, fmt.Errorf("idempotency_lease: must be between 1s and idempotency_ttl"))
+	}
+	if c.IngestQueueSize < 1 {
+		errs = append(errs, fmt.Errorf("ingest_queue_size: must be at least 1"))
+	}
+	for _, name := range c.PostProcessors {
+		if _, ok := postProcessorFactories[name]; !ok {
+			errs = append(errs, fmt.Errorf("post_processors: unknown processor %q", name))
+		}
+	}
+	if c.RatePrecision < 0 || c.RatePrecision > 10 {
+		errs = append(errs, fmt.Errorf("rate_precision: must be between 0 and 10"))
+	}
+	if c.OutlierMaxPct <= 0 {
+		errs = append(errs, fmt.Errorf("outlier_max_pct: must be positive"))
+	}
+	seen := map[string]string{}
+	for name, k := range c.APIKeys {
+		if k.Key == "" {
+			errs = append(errs, fmt.Errorf("api_keys: %s has no key", name))
+		} else if other, ok := seen[k.Key]; ok {
+			errs = append(errs, fmt.Errorf("api_keys: %s and %s share a key", name, other))
+		}
+		seen[k.Key] = name
+		if k.DailyQuota < 0 {
+			errs = append(errs, fmt.Errorf("api_keys: %s has a negative quota", name))
+		}
+	}
+	if c.UsageFlushInterval < time.Second {
+		errs = append(errs, fmt.Errorf("usage_flush_interval: must be at least 1s"))
+	}
+	if c.AnonymousQuota < 0 {
+		errs = append(errs, fmt.Errorf("anonymous_daily_quota: must not be negative"))
+	}
+	if c.ArchiveAfterDays < 0 {
+		errs = append(errs, fmt.Errorf("archive_after_days: must not be negative"))
+	}
+	for _, source := range c.SourcePrecedence {
+		if !isKnownSource(source) {
+			errs = append(errs, fmt.Errorf("source_precedence: unknown source %q", source))
+		}
+	}
+	if c.SchemaCheck != "fail" && c.SchemaCheck != "warn" {
+		errs = append(errs, fmt.Errorf("schema_check: must be fail or warn"))
+	}
+	if c.ECBProxy != "" {
+		if _, err := parseProxyURL(c.ECBProxy); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if c.ECBCAFile != "" {
+		if _, err := loadCAFile(c.ECBCAFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// Location returns the configured timezone, falling back to UTC.
+func (c *Config) Location() *time.Location {
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Redacted returns a copy of the config with every secret field masked.
+func (c *Config) Redacted() *Config {
+	cp := *c
+	v := reflect.ValueOf(&cp).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := v.Field(i)
+		if f.String() == "" {
+			continue
+		}
+		switch t.Field(i).Tag.Get("secret") {
+		case "true":
+			f.SetString("REDACTED")
+		case "userinfo":
+			f.SetString(redactUserinfo(f.String()))
+		}
+	}
+	cp.APIKeys = map[string]APIKey{}
+	for name, k := range c.APIKeys {
+		k.Key = "REDACTED"
+		cp.APIKeys[name] = k
+	}
+	return &cp
+}
+
+func redactUserinfo(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	}
+	return u.String()
+}
+
+// Map returns the redacted config keyed by its YAML names, with durations
+// rendered as strings.
+func (c *Config) Map() (map[string]interface{}, error) {
+	b, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func getDebugConfig(c echo.Context) error {
+	m, err := config.Map()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, m)
+}
+
+func printConfig(c *Config) error {
+	b, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(b)
+	return err
+}
+
+// envLoader overrides config fields from environment variables that are set
+// and collects the ones that fail to parse.
+type envLoader struct {
+	errs []error
+}
+
+func (l *envLoader) lookup(key string) (string, bool) {
+	v, ok := os.LookupEnv(key)
+	return v, ok && v != ""
+}
+
+func (l *envLoader) str(key string, dst *string) {
+	if v, ok := l.lookup(key); ok {
+		*dst = v
+	}
+}
+
+func (l *envLoader) boolean(key string, dst *bool) {
+	if v, ok := l.lookup(key); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q is not a boolean", key, v))
+			return
+		}
+		*dst = b
+	}
+}
+
+func (l *envLoader) integer(key string, dst *int) {
+	if v, ok := l.lookup(key); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q is not an integer", key, v))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (l *envLoader) float(key string, dst *float64) {
+	if v, ok := l.lookup(key); ok {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q is not a number", key, v))
+			return
+		}
+		*dst = f
+	}
+}
+
+func (l *envLoader) duration(key string, dst *time.Duration) {
+	if v, ok := l.lookup(key); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q is not a duration", key, v))
+			return
+		}
+		*dst = d
+	}
+}
+
+func (l *envLoader) list(key string, dst *[]string) {
+	if v, ok := l.lookup(key); ok {
+		*dst = parseList(v)
+	}
+}
+
+func (l *envLoader) apiKeys(key string, dst *map[string]APIKey) {
+	if v, ok := l.lookup(key); ok {
+		k, err := parseAPIKeys(v)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %v", key, err))
+			return
+		}
+		*dst = k
+	}
+}
+
+func (l *envLoader) derived(key string, dst *map[string]DerivedRate) {
+	if v, ok := l.lookup(key); ok {
+		d, err := parseDerivedRates(v)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Errorf("%s: %v", key, err))
+			return
+		}
+		*dst = d
+	}
+}
+
+func parseList(s string) []string {
+	list := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	ModeForward = "forward"
+	ModeReverse = "reverse"
+)
+
+type ConvertRes struct {
+	Mode     string  `json:"mode"`
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Amount   float64 `json:"amount"`
+	Date     string  `json:"date"`
+	Rate     float64 `json:"rate"`
+	Result   float64 `json:"result"`
+	Places   int     `json:"places"`
+	Rounding string  `json:"rounding"`
+
+	Path    []*Leg `json:"path"`
+	Formula string `json:"formula"`
+
+	MarginBps       float64 `json:"margin_bps,omitempty"`
+	EffectiveRate   float64 `json:"effective_rate,omitempty"`
+	EffectiveResult float64 `json:"effective_result,omitempty"`
+	MarginCost      float64 `json:"margin_cost,omitempty"`
+}
+
+// eurRate returns how many units of code one EUR buys in rates.
+func eurRate(rates map[string]float32, code string) (float64, bool) {
+	if code == "EUR" {
+		return 1, true
+	}
+	r, ok := rates[code]
+	if !ok || r == 0 {
+		return 0, false
+	}
+	return float64(r), true
+}
+
+// Leg is one EUR quote used in a conversion. Inverted legs are divided by
+// rather than multiplied with.
+type Leg struct {
+	Pair     string  `json:"pair"`
+	Rate     float64 `json:"rate"`
+	Inverted bool    `json:"inverted,omitempty"`
+}
+
+type Conversion struct {
+	Rate    float64 `json:"rate"`
+	Path    []*Leg  `json:"path"`
+	Formula string  `json:"formula"`
+}
+
+// conversionPath works out the to-per-from rate through EUR and records the
+// legs it used: the from leg inverted, then the to leg.
+func conversionPath(rates map[string]float32, from, to string) (*Conversion, error) {
+	fr, ok := eurRate(rates, from)
+	if !ok {
+		return nil, &UnknownCurrencyError{Field: "from", Code: from}
+	}
+	tr, ok := eurRate(rates, to)
+	if !ok {
+		return nil, &UnknownCurrencyError{Field: "to", Code: to}
+	}
+
+	conv := &Conversion{Rate: tr / fr, Path: []*Leg{}}
+	terms := []string{"amount"}
+	if from != "EUR" && from != to {
+		conv.Path = append(conv.Path, &Leg{Pair: "EUR/" + from, Rate: fr, Inverted: true})
+		terms = append(terms, "/ EUR/"+from)
+	}
+	if to != "EUR" && from != to {
+		conv.Path = append(conv.Path, &Leg{Pair: "EUR/" + to, Rate: tr})
+		terms = append(terms, "* EUR/"+to)
+	}
+	conv.Formula = strings.Join(terms, " ")
+	return conv, nil
+}
+
+// conversionVia routes from -> via -> to through a vehicle currency, each
+// leg being a cross rate in to-per-from terms. A vehicle equal to either
+// side collapses to a single leg; via EUR is the plain EUR path.
+func conversionVia(rates map[string]float32, from, via, to string) (*Conversion, error) {
+	if via == "" || via == "EUR" {
+		return conversionPath(rates, from, to)
+	}
+	if _, ok := eurRate(rates, via); !ok {
+		return nil, &UnknownCurrencyError{Field: "via", Code: via}
+	}
+	hops := []string{from, via, to}
+	if via == from || via == to {
+		hops = []string{from, to}
+	}
+
+	conv := &Conversion{Rate: 1, Path: []*Leg{}}
+	terms := []string{"amount"}
+	for i := 1; i < len(hops); i++ {
+		r, err := crossRate(rates, hops[i-1], hops[i])
+		if err != nil {
+			return nil, err
+		}
+		pair := hops[i-1] + "/" + hops[i]
+		conv.Rate *= r
+		conv.Path = append(conv.Path, &Leg{Pair: pair, Rate: r})
+		terms = append(terms, "* "+pair)
+	}
+	conv.Formula = strings.Join(terms, " ")
+	return conv, nil
+}
+
+// crossRate returns the number of to units per from unit.
+func crossRate(rates map[string]float32, from, to string) (float64, error) {
+	conv, err := conversionPath(rates, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return conv.Rate, nil
+}
+
+// findRateForDate returns the document for date, or the latest one when date
+// is empty.
+func findRateForDate(store *DB, date string) (*Rate, error) {
+	if date == "" {
+		r, err := store.GetLatest()
+		return &r, err
+	}
+	if _, err := time.Parse(DATE_LAYOUT, date); err != nil {
+		return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+	}
+	return store.FindByDate(date)
+}
+
+type ConvertReq struct {
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Amount float64 `json:"amount"`
+	Date   string  `json:"date"`
+	Places *int    `json:"places,omitempty"`
+	// TargetAmount switches to reverse mode: solve for the source amount
+	// that yields this many target units.
+	TargetAmount *float64 `json:"targetAmount,omitempty"`
+	// Via routes the conversion through a vehicle currency.
+	Via       string  `json:"via,omitempty"`
+	Rounding  string  `json:"rounding,omitempty"`
+	MarginBps float64 `json:"marginBps,omitempty"`
+
+	places int
+}
+
+func (r *ConvertReq) normalize() error {
+	r.From = strings.ToUpper(strings.TrimSpace(r.From))
+	r.To = strings.ToUpper(strings.TrimSpace(r.To))
+	if !currencyRe.MatchString(r.From) {
+		return &UnknownCurrencyError{Field: "from", Code: r.From}
+	}
+	if !currencyRe.MatchString(r.To) {
+		return &UnknownCurrencyError{Field: "to", Code: r.To}
+	}
+	r.Via = strings.ToUpper(strings.TrimSpace(r.Via))
+	if r.Via != "" && !currencyRe.MatchString(r.Via) {
+		return &UnknownCurrencyError{Field: "via", Code: r.Via}
+	}
+	if r.Date != "" {
+		if _, err := time.Parse(DATE_LAYOUT, r.Date); err != nil {
+			return fmt.Errorf("invalid date %q, expected YYYY-MM-DD", r.Date)
+		}
+	}
+	if r.Amount < 0 {
+		return fmt.Errorf("amount must not be negative")
+	}
+	if r.MarginBps < 0 || r.MarginBps > 1000 {
+		return fmt.Errorf("marginBps must be between 0 and 1000")
+	}
+
+	// Rounding applies to whichever amount is computed: the target in
+	// forward mode, the source in reverse mode.
+	roundTo := r.To
+	if r.TargetAmount != nil {
+		if r.Amount != 0 {
+			return fmt.Errorf("amount and targetAmount are mutually exclusive")
+		}
+		if *r.TargetAmount < 0 {
+			return fmt.Errorf("targetAmount must not be negative")
+		}
+		if r.MarginBps > 0 {
+			return fmt.Errorf("marginBps is not supported with targetAmount")
+		}
+		roundTo = r.From
+	}
+
+	placesParam := ""
+	if r.Places != nil {
+		placesParam = strconv.Itoa(*r.Places)
+	}
+	places, mode, err := parseRounding(placesParam, r.Rounding, roundTo)
+	if err != nil {
+		return err
+	}
+	r.places, r.Rounding = places, mode
+	return nil
+}
+
+func convert(rate *Rate, req *ConvertReq) (*ConvertRes, error) {
+	conv, err := conversionVia(rate.RateMap(), req.From, req.Via, req.To)
+	if err != nil {
+		return nil, err
+	}
+	res := &ConvertRes{
+		Mode:     ModeForward,
+		From:     req.From,
+		To:       req.To,
+		Amount:   req.Amount,
+		Date:     rate.RateDate,
+		Rate:     conv.Rate,
+		Result:   roundAmount(req.Amount*conv.Rate, req.places, req.Rounding),
+		Places:   req.places,
+		Rounding: req.Rounding,
+		Path:     conv.Path,
+		Formula:  conv.Formula,
+	}
+	if req.TargetAmount != nil {
+		res.Mode = ModeReverse
+		res.Result = *req.TargetAmount
+		res.Amount = roundAmount(*req.TargetAmount/conv.Rate, req.places, req.Rounding)
+		res.Formula = reverseFormula(conv.Formula)
+	}
+	if req.MarginBps > 0 {
+		applyMargin(res, req.MarginBps)
+	}
+	return res, nil
+}
+
+// reverseFormula turns "amount / EUR/USD * EUR/GBP" into
+// "targetAmount * EUR/USD / EUR/GBP".
+func reverseFormula(formula string) string {
+	terms := strings.Fields(formula)
+	terms[0] = "targetAmount"
+	for i, t := range terms {
+		switch t {
+		case "/":
+			terms[i] = "*"
+		case "*":
+			terms[i] = "/"
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// applyMargin skews the rate against the customer. Rate is always target
+// units per source unit, whichever side EUR is on, so the customer receives
+// fewer target units: effective = mid * (1 - bps/10000).
+func applyMargin(res *ConvertRes, bps float64) {
+	res.MarginBps = bps
+	res.EffectiveRate = res.Rate * (1 - bps/10000)
+	mid := res.Amount * res.Rate
+	effective := res.Amount * res.EffectiveRate
+	res.EffectiveResult = roundAmount(effective, res.Places, res.Rounding)
+	res.MarginCost = roundAmount(mid-effective, res.Places, res.Rounding)
+}
+
+func getConvert(c echo.Context) error {
+	req := &ConvertReq{
+		From:     c.QueryParam("from"),
+		To:       c.QueryParam("to"),
+		Date:     c.QueryParam("date"),
+		Rounding: c.QueryParam("rounding"),
+		Via:      c.QueryParam("via"),
+	}
+	amountParam, targetParam := c.QueryParam("amount"), c.QueryParam("targetAmount")
+	switch {
+	case amountParam != "" && targetParam != "":
+		return c.JSON(http.StatusBadRequest, "amount and targetAmount are mutually exclusive")
+	case targetParam != "":
+		target, err := strconv.ParseFloat(targetParam, 64)
+		if err != nil || math.IsNaN(target) || math.IsInf(target, 0) {
+			return c.JSON(http.StatusBadRequest, "targetAmount must be a number")
+		}
+		req.TargetAmount = &target
+	default:
+		amount, err := strconv.ParseFloat(amountParam, 64)
+		if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+			return c.JSON(http.StatusBadRequest, "amount must be a number")
+		}
+		req.Amount = amount
+	}
+	if v := c.QueryParam("places"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, "places must be an integer")
+		}
+		req.Places = &n
+	}
+	if v := c.QueryParam("marginBps"); v != "" {
+		bps, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(bps) || math.IsInf(bps, 0) {
+			return c.JSON(http.StatusBadRequest, "marginBps must be a number")
+		}
+		req.MarginBps = bps
+	}
+	normErr := req.normalize()
+	if _, ok := normErr.(*UnknownCurrencyError); normErr != nil && !ok {
+		return c.JSON(http.StatusBadRequest, normErr.Error())
+	}
+
+	rate, err := findRateForDate(storeFor(c), req.Date)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
+	if normErr != nil {
+		return c.JSON(http.StatusUnprocessableEntity, unknownCurrencyEnvelope(normErr.(*UnknownCurrencyError), rate))
+	}
+	res, err := convert(rate, req)
+	if uerr, ok := err.(*UnknownCurrencyError); ok {
+		return c.JSON(http.StatusUnprocessableEntity, unknownCurrencyEnvelope(uerr, rate))
+	}
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, err.Error())
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo"
+)
+
+type ConvertAllRes struct {
+	From       string             `json:"from"`
+	Amount     float64            `json:"amount"`
+	Date       string             `json:"date"`
+	SourceRate float64            `json:"source_rate"`
+	Rounding   string             `json:"rounding"`
+	Results    map[string]float64 `json:"results"`
+}
+
+// getConvertAll expresses amount in every currency quoted on the date,
+// including EUR, each rounded to its own minor unit unless places is given.
+func getConvertAll(c echo.Context) error {
+	amount, err := strconv.ParseFloat(c.QueryParam("amount"), 64)
+	if err != nil || amount < 0 {
+		return c.JSON(http.StatusBadRequest, "amount must be a non-negative number")
+	}
+	symbols, err := parseSymbols(c.QueryParam("symbols"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if _, _, err := parseRounding(c.QueryParam("places"), c.QueryParam("rounding"), "EUR"); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	rate, err := findRateForDate(storeFor(c), c.QueryParam("date"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
+
+	req := &ConvertReq{From: c.QueryParam("from"), To: "EUR"}
+	if err := req.normalize(); err != nil {
+		if uerr, ok := err.(*UnknownCurrencyError); ok {
+			return c.JSON(http.StatusUnprocessableEntity, unknownCurrencyEnvelope(uerr, rate))
+		}
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	rates := rate.RateMap()
+	sourceRate, ok := eurRate(rates, req.From)
+	if !ok {
+		return c.JSON(http.StatusUnprocessableEntity, unknownCurrencyEnvelope(&UnknownCurrencyError{Field: "from", Code: req.From}, rate))
+	}
+
+	targets := symbols
+	if len(targets) == 0 {
+		targets = validCodes(rate)
+	}
+
+	res := &ConvertAllRes{
+		From:       req.From,
+		Amount:     amount,
+		Date:       rate.RateDate,
+		SourceRate: sourceRate,
+		Results:    map[string]float64{},
+	}
+	for _, to := range targets {
+		cross, err := crossRate(rates, req.From, to)
+		if err != nil {
+			continue
+		}
+		places, mode, _ := parseRounding(c.QueryParam("places"), c.QueryParam("rounding"), to)
+		res.Rounding = mode
+		res.Results[to] = roundAmount(amount*cross, places, mode)
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestConversionPath(t *testing.T) {
+	rates := map[string]float32{"USD": 1.25, "GBP": 0.8}
+	tests := []struct {
+		from, to string
+		rate     float64
+		legs     int
+		formula  string
+	}{
+		{"USD", "GBP", 0.8 / 1.25, 2, "amount / EUR/USD * EUR/GBP"},
+		{"EUR", "USD", 1.25, 1, "amount * EUR/USD"},
+		{"USD", "EUR", 1 / 1.25, 1, "amount / EUR/USD"},
+		{"USD", "USD", 1, 0, "amount"},
+		{"EUR", "EUR", 1, 0, "amount"},
+	}
+	for _, tt := range tests {
+		conv, err := conversionPath(rates, tt.from, tt.to)
+		if err != nil {
+			t.Errorf("%s->%s: %v", tt.from, tt.to, err)
+			continue
+		}
+		if math.Abs(conv.Rate-tt.rate) > 1e-6 {
+			t.Errorf("%s->%s rate = %v, want %v", tt.from, tt.to, conv.Rate, tt.rate)
+		}
+		if len(conv.Path) != tt.legs {
+			t.Errorf("%s->%s legs = %d, want %d", tt.from, tt.to, len(conv.Path), tt.legs)
+		}
+		if conv.Formula != tt.formula {
+			t.Errorf("%s->%s formula = %q, want %q", tt.from, tt.to, conv.Formula, tt.formula)
+		}
+	}
+}
+
+func TestConversionPathUnknown(t *testing.T) {
+	rates := map[string]float32{"USD": 1.25}
+	tests := []struct {
+		from, to, field string
+	}{
+		{"XXX", "USD", "from"},
+		{"USD", "XXX", "to"},
+	}
+	for _, tt := range tests {
+		_, err := conversionPath(rates, tt.from, tt.to)
+		uerr, ok := err.(*UnknownCurrencyError)
+		if !ok || uerr.Field != tt.field {
+			t.Errorf("%s->%s err = %v, want unknown %s", tt.from, tt.to, err, tt.field)
+		}
+	}
+}
+
+func TestConvertReqNormalize(t *testing.T) {
+	tests := []struct {
+		req     ConvertReq
+		wantErr bool
+	}{
+		{ConvertReq{From: "usd", To: "gbp", Amount: 10}, false},
+		{ConvertReq{From: "USD", To: "GBP", Amount: 10, Date: "2024-01-31"}, false},
+		{ConvertReq{From: "USD", To: "GBP", Amount: 10, Date: "2024-02-30"}, true},
+		{ConvertReq{From: "USD", To: "GBP", Amount: 10, Date: "31/01/2024"}, true},
+		{ConvertReq{From: "USD", To: "GBP", Amount: -1}, true},
+		{ConvertReq{From: "USD", To: "GBP", Amount: 10, MarginBps: 1001}, true},
+	}
+	for _, tt := range tests {
+		req := tt.req
+		err := req.normalize()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%+v: err = %v, wantErr %v", tt.req, err, tt.wantErr)
+		}
+		if _, ok := err.(*UnknownCurrencyError); ok {
+			t.Errorf("%+v: got UnknownCurrencyError, want a 400 error", tt.req)
+		}
+	}
+}
+
+func TestConvertMargin(t *testing.T) {
+	rate := rateOf("2024-01-31", map[string]float32{"USD": 1.25, "GBP": 0.5})
+	tests := []struct {
+		name, from, to       string
+		mid, effective, cost float64
+	}{
+		{"from EUR", "EUR", "USD", 125, 123.75, 1.25},
+		{"to EUR", "USD", "EUR", 80, 79.2, 0.8},
+		{"cross", "USD", "GBP", 40, 39.6, 0.4},
+	}
+	for _, tt := range tests {
+		req := &ConvertReq{From: tt.from, To: tt.to, Amount: 100, MarginBps: 100}
+		if err := req.normalize(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		res, err := convert(rate, req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if res.Result != tt.mid || res.EffectiveResult != tt.effective || res.MarginCost != tt.cost {
+			t.Errorf("%s: result %v effective %v cost %v, want %v %v %v",
+				tt.name, res.Result, res.EffectiveResult, res.MarginCost, tt.mid, tt.effective, tt.cost)
+		}
+		if res.EffectiveRate >= res.Rate {
+			t.Errorf("%s: effective rate %v is not below mid %v", tt.name, res.EffectiveRate, res.Rate)
+		}
+	}
+}
+
+func TestConversionVia(t *testing.T) {
+	rates := map[string]float32{"USD": 1.25, "GBP": 0.8, "JPY": 160}
+	tests := []struct {
+		from, via, to string
+		legs          []string
+		err           string
+	}{
+		{"GBP", "EUR", "JPY", []string{"EUR/GBP", "EUR/JPY"}, ""},
+		{"GBP", "", "JPY", []string{"EUR/GBP", "EUR/JPY"}, ""},
+		{"GBP", "USD", "JPY", []string{"GBP/USD", "USD/JPY"}, ""},
+		{"USD", "USD", "JPY", []string{"USD/JPY"}, ""},
+		{"GBP", "USD", "USD", []string{"GBP/USD"}, ""},
+		{"GBP", "CHF", "JPY", nil, "via"},
+	}
+	for _, tt := range tests {
+		conv, err := conversionVia(rates, tt.from, tt.via, tt.to)
+		if tt.err != "" {
+			if uerr, ok := err.(*UnknownCurrencyError); !ok || uerr.Field != tt.err {
+				t.Errorf("%s via %q to %s: err = %v, want unknown %s", tt.from, tt.via, tt.to, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s via %q to %s: %v", tt.from, tt.via, tt.to, err)
+			continue
+		}
+		var legs []string
+		for _, leg := range conv.Path {
+			legs = append(legs, leg.Pair)
+		}
+		if strings.Join(legs, ",") != strings.Join(tt.legs, ",") {
+			t.Errorf("%s via %q to %s: legs %v, want %v", tt.from, tt.via, tt.to, legs, tt.legs)
+		}
+		// via=USD lands on the same cross as the EUR path from one document.
+		if want, _ := conversionPath(rates, tt.from, tt.to); math.Abs(conv.Rate-want.Rate) > 1e-9 {
+			t.Errorf("%s via %q to %s: rate %v, want %v", tt.from, tt.via, tt.to, conv.Rate, want.Rate)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type CorrelationPoint struct {
+	Date        string  `json:"date"`
+	Correlation float64 `json:"correlation"`
+}
+
+type RollingCorrelationRes struct {
+	A      string              `json:"a"`
+	B      string              `json:"b"`
+	Window int                 `json:"window"`
+	Range  *DateRange          `json:"range"`
+	Series []*CorrelationPoint `json:"series"`
+}
+
+// rollingCorrelation slides a window of n aligned returns and reports the
+// correlation at the last date of each full window.
+func rollingCorrelation(aligned *AlignedReturns, n int) []*CorrelationPoint {
+	series := []*CorrelationPoint{}
+	for end := n; end <= len(aligned.A); end++ {
+		series = append(series, &CorrelationPoint{
+			Date:        aligned.Dates[end-1],
+			Correlation: correlation(aligned.A[end-n:end], aligned.B[end-n:end]),
+		})
+	}
+	return series
+}
+
+func getRollingCorrelation(c echo.Context) error {
+	a := strings.ToUpper(c.QueryParam("a"))
+	b := strings.ToUpper(c.QueryParam("b"))
+	if !currencyRe.MatchString(a) || !currencyRe.MatchString(b) {
+		return c.JSON(http.StatusBadRequest, "a and b must be currency codes")
+	}
+	window := 30
+	if v := c.QueryParam("window"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < MIN_OBSERVATIONS || n > 1000 {
+			return c.JSON(http.StatusBadRequest, "window must be between "+strconv.Itoa(MIN_OBSERVATIONS)+" and 1000")
+		}
+		window = n
+	}
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	aligned := alignReturns(rates, Basket{a: 1}, Basket{b: 1})
+	res := &RollingCorrelationRes{
+		A:      a,
+		B:      b,
+		Window: window,
+		Range:  r,
+		Series: rollingCorrelation(aligned, window),
+	}
+	return c.JSON(http.StatusOK, res)
+}
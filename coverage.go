@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo"
+)
+
+type CoverageChange struct {
+	Date         string   `bson:"date" json:"date"`
+	PreviousDate string   `bson:"previous_date" json:"previous_date"`
+	Added        []string `bson:"added" json:"added"`
+	Removed      []string `bson:"removed" json:"removed"`
+}
+
+// diffCoverage returns the currencies added and removed between two
+// documents, or nil when both quote the same set.
+func diffCoverage(prev, cur *Rate) *CoverageChange {
+	before, after := prev.RateMap(), cur.RateMap()
+	change := &CoverageChange{Date: cur.RateDate, PreviousDate: prev.RateDate, Added: []string{}, Removed: []string{}}
+	for code := range after {
+		if _, ok := before[code]; !ok {
+			change.Added = append(change.Added, code)
+		}
+	}
+	for code := range before {
+		if _, ok := after[code]; !ok {
+			change.Removed = append(change.Removed, code)
+		}
+	}
+	if len(change.Added) == 0 && len(change.Removed) == 0 {
+		return nil
+	}
+	sort.Strings(change.Added)
+	sort.Strings(change.Removed)
+	return change
+}
+
+// coverageChanges scans consecutive non-empty documents, sorted by date.
+func coverageChanges(rates []Rate) []*CoverageChange {
+	changes := []*CoverageChange{}
+	var prev *Rate
+	for i := range rates {
+		if len(rates[i].Rates) == 0 {
+			continue
+		}
+		if prev != nil {
+			if change := diffCoverage(prev, &rates[i]); change != nil {
+				changes = append(changes, change)
+			}
+		}
+		prev = &rates[i]
+	}
+	return changes
+}
+
+func getCoverageChanges(c echo.Context) error {
+	r, err := parseHistoryRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, coverageChanges(rates))
+}
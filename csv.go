@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type RowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type CSVErrorRes struct {
+	Rows   int         `json:"rows"`
+	Failed int         `json:"failed"`
+	Errors []*RowError `json:"errors"`
+}
+
+var csvColumns = []string{"date", "from", "to", "amount"}
+
+// postConvertCSV converts an uploaded date,from,to,amount CSV row by row.
+// Output goes to a temp file so memory stays flat; it is sent back with rate
+// and result columns appended only when every row converted, otherwise the
+// row errors are returned as JSON.
+func postConvertCSV(c echo.Context) error {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "multipart field file is required")
+	}
+	src, err := fh.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	defer src.Close()
+
+	out, err := ioutil.TempFile("", "convert-*.csv")
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	r := csv.NewReader(src)
+	r.FieldsPerRecord = len(csvColumns)
+	w := csv.NewWriter(out)
+
+	header, err := r.Read()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "missing header row")
+	}
+	for i, col := range csvColumns {
+		if strings.ToLower(strings.TrimSpace(header[i])) != col {
+			return c.JSON(http.StatusBadRequest, "header must be "+strings.Join(csvColumns, ","))
+		}
+	}
+	w.Write(append(header, "rate", "result"))
+
+	byDate := newRatesByDate(storeFor(c))
+	res := &CSVErrorRes{Errors: []*RowError{}}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		res.Rows++
+		row := res.Rows + 1
+		if res.Rows > config.CSVMaxRows {
+			return c.JSON(http.StatusRequestEntityTooLarge, fmt.Sprintf("at most %d rows are accepted", config.CSVMaxRows))
+		}
+		if err != nil {
+			res.Errors = append(res.Errors, &RowError{Row: row, Error: err.Error()})
+			continue
+		}
+
+		conv, err := convertCSVRecord(byDate, record)
+		if err != nil {
+			res.Errors = append(res.Errors, &RowError{Row: row, Error: err.Error()})
+			continue
+		}
+		w.Write(append(record,
+			strconv.FormatFloat(conv.Rate, 'f', -1, 64),
+			strconv.FormatFloat(conv.Result, 'f', conv.Places, 64),
+		))
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	if len(res.Errors) > 0 {
+		res.Failed = len(res.Errors)
+		return c.JSON(http.StatusUnprocessableEntity, res)
+	}
+
+	if _, err := out.Seek(0, io.SeekStart); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="converted.csv"`)
+	return c.Stream(http.StatusOK, "text/csv", out)
+}
+
+func convertCSVRecord(byDate *ratesByDate, record []string) (*ConvertRes, error) {
+	amount, err := strconv.ParseFloat(strings.TrimSpace(record[3]), 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return nil, fmt.Errorf("bad amount %q", record[3])
+	}
+	req := &ConvertReq{
+		Date:   strings.TrimSpace(record[0]),
+		From:   record[1],
+		To:     record[2],
+		Amount: amount,
+	}
+	if err := req.normalize(); err != nil {
+		return nil, err
+	}
+	if req.Date == "" {
+		return nil, fmt.Errorf("missing date")
+	}
+
+	rate, err := byDate.Get(req.Date)
+	if err != nil {
+		return nil, fmt.Errorf("no rates for %s", req.Date)
+	}
+	return convert(rate, req)
+}
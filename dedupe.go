@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/labstack/echo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type DuplicateGroup struct {
+	Date       string   `json:"date"`
+	Documents  int      `json:"documents"`
+	Kept       string   `json:"kept"`
+	Removed    []string `json:"removed"`
+	Currencies int      `json:"currencies"`
+}
+
+type DedupeReport struct {
+	DryRun  bool              `json:"dry_run"`
+	Groups  []*DuplicateGroup `json:"groups"`
+	Removed int               `json:"removed"`
+}
+
+// FindDuplicateDates returns the rate_date values stored in more than one
+// document, at most config.MaxAggregationResults of them per call; dedupe
+// can simply be run again for the rest.
+func (p *DB) FindDuplicateDates() ([]string, error) {
+	span := p.startSpan("FindDuplicateDates")
+	defer span.End()
+
+	var groups []struct {
+		Date string `bson:"_id"`
+	}
+	err := db.C(config.Collection).Pipe([]bson.M{
+		{"$group": bson.M{"_id": "$rate_date", "n": bson.M{"$sum": 1}}},
+		{"$match": bson.M{"n": bson.M{"$gt": 1}}},
+		{"$sort": bson.M{"_id": 1}},
+		{"$limit": config.MaxAggregationResults},
+	}).All(&groups)
+	dates := []string{}
+	for _, g := range groups {
+		dates = append(dates, g.Date)
+	}
+	return dates, err
+}
+
+// FindAllByDate returns every document stored for date, oldest first.
+func (p *DB) FindAllByDate(date string) ([]Rate, error) {
+	span := p.startSpan("FindAllByDate")
+	defer span.End()
+
+	rates := []Rate{}
+	err := db.C(config.Collection).Find(bson.M{"rate_date": date}).Sort("_id").All(&rates)
+	return rates, err
+}
+
+func (p *DB) RemoveRate(id bson.ObjectId) error {
+	span := p.startSpan("RemoveRate")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	return db.C(config.Collection).RemoveId(id)
+}
+
+// mergeDuplicates merges the rates of docs, sorted oldest first, into the
+// newest one. A newer document wins when two quote the same currency; the
+// annotations of all of them are kept.
+func mergeDuplicates(docs []Rate) *Rate {
+	merged := map[string]float32{}
+	for i := range docs {
+		for code, v := range docs[i].RateMap() {
+			merged[code] = v
+		}
+	}
+	keep := docs[len(docs)-1]
+	keep.Annotations = []*Annotation{}
+	for i := range docs {
+		keep.Annotations = append(keep.Annotations, docs[i].Annotations...)
+	}
+	keep.Rates = []*Item{}
+	for code, v := range merged {
+		keep.Rates = append(keep.Rates, &Item{Currency: code, Rate: v})
+	}
+	sort.Slice(keep.Rates, func(i, j int) bool { return keep.Rates[i].Currency < keep.Rates[j].Currency })
+	return &keep
+}
+
+// dedupe merges every group of documents sharing a rate_date into the newest
+// one and deletes the others. dryRun reports the plan without writing.
+func dedupe(store *DB, dryRun bool) (*DedupeReport, error) {
+	dates, err := store.FindDuplicateDates()
+	if err != nil {
+		return nil, err
+	}
+	report := &DedupeReport{DryRun: dryRun, Groups: []*DuplicateGroup{}}
+	for _, date := range dates {
+		docs, err := store.FindAllByDate(date)
+		if err != nil {
+			return report, err
+		}
+		if len(docs) < 2 {
+			continue
+		}
+		keep := mergeDuplicates(docs)
+		group := &DuplicateGroup{
+			Date:       date,
+			Documents:  len(docs),
+			Kept:       keep.ID.Hex(),
+			Removed:    []string{},
+			Currencies: len(keep.Rates),
+		}
+		for _, doc := range docs[:len(docs)-1] {
+			group.Removed = append(group.Removed, doc.ID.Hex())
+		}
+		report.Groups = append(report.Groups, group)
+		report.Removed += len(group.Removed)
+		if dryRun {
+			continue
+		}
+
+		if err := store.Update(keep); err != nil {
+			return report, err
+		}
+		for _, doc := range docs[:len(docs)-1] {
+			if err := store.RemoveRate(doc.ID); err != nil {
+				return report, err
+			}
+		}
+	}
+	if !dryRun && report.Removed > 0 {
+		cache.Invalidate()
+	}
+	return report, nil
+}
+
+// getDuplicates lists the duplicate-date groups with their document ids,
+// i.e. a dedupe dry run.
+func getDuplicates(c echo.Context) error {
+	report, err := dedupe(storeFor(c), true)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+func postAdminDedupe(c echo.Context) error {
+	dryRun, _ := strconv.ParseBool(c.QueryParam("dryRun"))
+	report, err := dedupe(storeFor(c), dryRun)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// runDedupe is the CLI equivalent of POST /admin/dedupe.
+func runDedupe(w io.Writer, dryRun bool) int {
+	if err := p.Open(); err != nil {
+		fmt.Fprintln(w, "dedupe:", err)
+		return 1
+	}
+	report, err := dedupe(p, dryRun)
+	if err != nil {
+		fmt.Fprintln(w, "dedupe:", err)
+		return 1
+	}
+	for _, g := range report.Groups {
+		fmt.Fprintf(w, "%s: %d documents, kept %s, removed %v\n", g.Date, g.Documents, g.Kept, g.Removed)
+	}
+	verb := "removed"
+	if dryRun {
+		verb = "would remove"
+	}
+	fmt.Fprintf(w, "%d duplicate dates, %s %d documents\n", len(report.Groups), verb, report.Removed)
+	if !dryRun && report.Removed > 0 {
+		if err := p.EnsureIndexes(); err != nil {
+			fmt.Fprintln(w, "dedupe: EnsureIndexes:", err)
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestMergeDuplicates(t *testing.T) {
+	older := *rateOf("2020-01-02", map[string]float32{"USD": 1.1, "JPY": 120})
+	older.Annotations = []*Annotation{{Note: "old"}}
+	newer := *rateOf("2020-01-02", map[string]float32{"USD": 1.2, "GBP": 0.9})
+	newer.Annotations = []*Annotation{{Note: "new"}}
+
+	keep := mergeDuplicates([]Rate{older, newer})
+	want := map[string]float32{"GBP": 0.9, "JPY": 120, "USD": 1.2}
+	got := keep.RateMap()
+	if len(got) != len(want) {
+		t.Fatalf("merged %v, want %v", got, want)
+	}
+	for code, v := range want {
+		if got[code] != v {
+			t.Errorf("%s = %v, want %v", code, got[code], v)
+		}
+	}
+	for i := 1; i < len(keep.Rates); i++ {
+		if keep.Rates[i-1].Currency > keep.Rates[i].Currency {
+			t.Errorf("rates not sorted by currency")
+		}
+	}
+	if len(keep.Annotations) != 2 {
+		t.Errorf("kept %d annotations, want 2", len(keep.Annotations))
+	}
+}
+
+func TestDedupe(t *testing.T) {
+	store := testStore(t)
+	older := rateOf("2020-01-02", map[string]float32{"USD": 1.1, "JPY": 120})
+	older.ID = bson.NewObjectId()
+	newer := rateOf("2020-01-02", map[string]float32{"USD": 1.2})
+	newer.ID = bson.NewObjectId()
+	other := rateOf("2020-01-03", map[string]float32{"USD": 1.3})
+	other.ID = bson.NewObjectId()
+	// Inserted directly: Save would refuse the duplicate.
+	if err := db.C(config.Collection).Insert(older, newer, other); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := dedupe(store, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Groups) != 1 || report.Groups[0].Date != "2020-01-02" || report.Removed != 1 {
+		t.Fatalf("dry run report = %+v", report)
+	}
+	if report.Groups[0].Kept != newer.ID.Hex() || report.Groups[0].Removed[0] != older.ID.Hex() {
+		t.Errorf("dry run keeps %s and removes %v, want %s and %s",
+			report.Groups[0].Kept, report.Groups[0].Removed, newer.ID.Hex(), older.ID.Hex())
+	}
+	if docs, _ := store.FindAllByDate("2020-01-02"); len(docs) != 2 {
+		t.Fatalf("dry run left %d documents, want 2", len(docs))
+	}
+
+	if _, err := dedupe(store, false); err != nil {
+		t.Fatal(err)
+	}
+	docs, err := store.FindAllByDate("2020-01-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].ID != newer.ID {
+		t.Fatalf("after dedupe %d documents remain, want only the newest", len(docs))
+	}
+	if got := docs[0].RateMap(); got["USD"] != 1.2 || got["JPY"] != 120 {
+		t.Errorf("merged rates = %v", got)
+	}
+	if dates, err := store.FindDuplicateDates(); err != nil || len(dates) != 0 {
+		t.Errorf("duplicates after dedupe = %v, %v", dates, err)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// DerivedRate defines a currency the feed doesn't quote as Factor units per
+// unit of Pivot, e.g. AED pegged at 3.6725 per USD.
+type DerivedRate struct {
+	Pivot  string  `yaml:"pivot" json:"pivot"`
+	Factor float64 `yaml:"factor" json:"factor"`
+}
+
+// parseDerivedRates reads "AED:USD:3.6725,SAR:USD:3.75".
+func parseDerivedRates(s string) (map[string]DerivedRate, error) {
+	res := map[string]DerivedRate{}
+	for _, part := range parseList(s) {
+		fields := strings.Split(part, ":")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%q must be CODE:PIVOT:FACTOR", part)
+		}
+		factor, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q has an invalid factor", part)
+		}
+		res[strings.ToUpper(fields[0])] = DerivedRate{Pivot: strings.ToUpper(fields[1]), Factor: factor}
+	}
+	return res, nil
+}
+
+type DerivedRateRes struct {
+	Base      string  `json:"base"`
+	Currency  string  `json:"currency"`
+	Date      string  `json:"date"`
+	Rate      float64 `json:"rate"`
+	Derived   bool    `json:"derived"`
+	Pivot     string  `json:"pivot"`
+	PivotRate float64 `json:"pivot_rate"`
+	Factor    float64 `json:"factor"`
+}
+
+func getDerivedRate(c echo.Context) error {
+	currency := strings.ToUpper(c.Param("currency"))
+	def, ok := config.DerivedRates[currency]
+	if !ok {
+		return c.JSON(http.StatusNotFound, currency+" is not a configured derived currency")
+	}
+
+	latest, err := storeFor(c).GetLatest()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	pivotRate, ok := eurRate(latest.RateMap(), def.Pivot)
+	if !ok {
+		return c.JSON(http.StatusUnprocessableEntity, "pivot "+def.Pivot+" is missing on "+latest.RateDate)
+	}
+
+	res := &DerivedRateRes{
+		Base:      "EUR",
+		Currency:  currency,
+		Date:      latest.RateDate,
+		Rate:      pivotRate * def.Factor,
+		Derived:   true,
+		Pivot:     def.Pivot,
+		PivotRate: pivotRate,
+		Factor:    def.Factor,
+	}
+	return jsonFields(c, http.StatusOK, res)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+const (
+	DiffInsert    = "insert"
+	DiffUpdate    = "update"
+	DiffUnchanged = "unchanged"
+)
+
+type CurrencyDiff struct {
+	Currency  string   `json:"currency"`
+	Old       *float32 `json:"old"`
+	New       *float32 `json:"new"`
+	PctChange float64  `json:"pct_change,omitempty"`
+}
+
+type DateDiff struct {
+	Date    string          `json:"date"`
+	Action  string          `json:"action"`
+	Changes []*CurrencyDiff `json:"changes,omitempty"`
+}
+
+type DiffReport struct {
+	Tolerance float64     `json:"tolerance"`
+	Insert    []string    `json:"insert"`
+	Update    []*DateDiff `json:"update"`
+	Unchanged int         `json:"unchanged"`
+}
+
+// diffRates compares a stored document with an incoming one. Currencies that
+// appear on one side only are always reported; values present on both sides
+// are reported when their relative change exceeds tolerance.
+func diffRates(old, cur *Rate, tolerance float64) *DateDiff {
+	d := &DateDiff{Date: cur.RateDate, Action: DiffUnchanged}
+	if old == nil {
+		d.Action = DiffInsert
+		return d
+	}
+	if !sameRates(old, cur) {
+		d.Action = DiffUpdate
+	}
+
+	om, cm := old.RateMap(), cur.RateMap()
+	codes := map[string]bool{}
+	for code := range om {
+		codes[code] = true
+	}
+	for code := range cm {
+		codes[code] = true
+	}
+	for code := range codes {
+		o, inOld := om[code]
+		n, inNew := cm[code]
+		diff := &CurrencyDiff{Currency: code}
+		if inOld {
+			diff.Old = &o
+		}
+		if inNew {
+			diff.New = &n
+		}
+		if inOld && inNew {
+			diff.PctChange = percentChange(o, n)
+			if math.Abs(diff.PctChange)/100 <= tolerance {
+				continue
+			}
+		}
+		d.Changes = append(d.Changes, diff)
+	}
+	sort.Slice(d.Changes, func(i, j int) bool { return d.Changes[i].Currency < d.Changes[j].Currency })
+	return d
+}
+
+// diffAgainstStore reports what saving rates would change without writing.
+func diffAgainstStore(store *DB, rates []*Rate, tolerance float64) (*DiffReport, error) {
+	report := &DiffReport{
+		Tolerance: tolerance,
+		Insert:    []string{},
+		Update:    []*DateDiff{},
+	}
+	for _, rate := range rates {
+		if len(rate.Rates) == 0 {
+			continue
+		}
+		var old *Rate
+		var err error
+		if rate.Timestamp.IsZero() {
+			old, err = store.FindByDate(rate.RateDate)
+		} else {
+			old, err = store.FindIntraday(rate.RateDate, rate.Timestamp)
+		}
+		if err != nil {
+			old = nil
+		}
+		d := diffRates(old, rate, tolerance)
+		switch d.Action {
+		case DiffInsert:
+			report.Insert = append(report.Insert, d.Date)
+		case DiffUpdate:
+			report.Update = append(report.Update, d)
+		default:
+			report.Unchanged++
+		}
+	}
+	return report, nil
+}
+
+// DryRunRefresh fetches and parses the provider file unconditionally and
+// reports what a Refresh would change. Nothing is written.
+func DryRunRefresh(ctx context.Context, tolerance float64) (*DiffReport, error) {
+	ctx, span := tracer.Start(ctx, "ingest.dry_run")
+	defer span.End()
+
+	rates, err := fetchECBFull(ctx, ECB_URL)
+	if err != nil {
+		return nil, err
+	}
+	return diffAgainstStore(p.WithContext(ctx), rates, tolerance)
+}
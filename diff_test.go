@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func rateOf(date string, rates map[string]float32) *Rate {
+	r := &Rate{RateDate: date}
+	for code, v := range rates {
+		r.Rates = append(r.Rates, &Item{Currency: code, Rate: v})
+	}
+	return r
+}
+
+func TestDiffRates(t *testing.T) {
+	tests := []struct {
+		name      string
+		old, cur  *Rate
+		tolerance float64
+		action    string
+		changes   []string
+	}{
+		{"insert", nil, rateOf("2020-01-02", map[string]float32{"USD": 1.1}), 0, DiffInsert, nil},
+		{
+			"unchanged",
+			rateOf("2020-01-02", map[string]float32{"USD": 1.1}),
+			rateOf("2020-01-02", map[string]float32{"USD": 1.1}),
+			0, DiffUnchanged, nil,
+		},
+		{
+			"changed",
+			rateOf("2020-01-02", map[string]float32{"USD": 1.1, "GBP": 0.9}),
+			rateOf("2020-01-02", map[string]float32{"USD": 1.2, "GBP": 0.9}),
+			0, DiffUpdate, []string{"USD"},
+		},
+		{
+			"within tolerance",
+			rateOf("2020-01-02", map[string]float32{"USD": 1.1}),
+			rateOf("2020-01-02", map[string]float32{"USD": 1.1001}),
+			0.01, DiffUpdate, nil,
+		},
+		{
+			"one side only",
+			rateOf("2020-01-02", map[string]float32{"USD": 1.1, "JPY": 120}),
+			rateOf("2020-01-02", map[string]float32{"USD": 1.1, "CHF": 1.05}),
+			1, DiffUpdate, []string{"CHF", "JPY"},
+		},
+	}
+	for _, tt := range tests {
+		d := diffRates(tt.old, tt.cur, tt.tolerance)
+		if d.Action != tt.action {
+			t.Errorf("%s: action = %s, want %s", tt.name, d.Action, tt.action)
+		}
+		if len(d.Changes) != len(tt.changes) {
+			t.Errorf("%s: %d changes, want %d", tt.name, len(d.Changes), len(tt.changes))
+			continue
+		}
+		for i, code := range tt.changes {
+			if d.Changes[i].Currency != code {
+				t.Errorf("%s: change %d = %s, want %s", tt.name, i, d.Changes[i].Currency, code)
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+)
+
+type CurrencyRate struct {
+	Currency string  `json:"currency"`
+	Rate     float32 `json:"rate"`
+}
+
+type DispersionRes struct {
+	RequestedDate string        `json:"requested_date"`
+	Date          string        `json:"date"`
+	Currencies    int           `json:"currencies"`
+	Highest       *CurrencyRate `json:"highest"`
+	Lowest        *CurrencyRate `json:"lowest"`
+	Ratio         float64       `json:"ratio"`
+	Stddev        float64       `json:"stddev"`
+}
+
+// dispersion computes the cross-sectional spread of one day's EUR rates.
+func dispersion(rate *Rate) *DispersionRes {
+	res := &DispersionRes{Date: rate.RateDate, Currencies: len(rate.Rates)}
+	values := []float64{}
+	for _, item := range rate.Rates {
+		values = append(values, float64(item.Rate))
+		if res.Highest == nil || item.Rate > res.Highest.Rate {
+			res.Highest = &CurrencyRate{Currency: item.Currency, Rate: item.Rate}
+		}
+		if res.Lowest == nil || item.Rate < res.Lowest.Rate {
+			res.Lowest = &CurrencyRate{Currency: item.Currency, Rate: item.Rate}
+		}
+	}
+	if res.Lowest != nil && res.Lowest.Rate != 0 {
+		res.Ratio = float64(res.Highest.Rate) / float64(res.Lowest.Rate)
+	}
+	res.Stddev = stddev(values)
+	return res
+}
+
+func getDispersion(c echo.Context) error {
+	date := c.QueryParam("date")
+	if _, err := time.Parse(DATE_LAYOUT, date); err != nil {
+		return c.JSON(http.StatusBadRequest, "date must be YYYY-MM-DD")
+	}
+
+	rate, err := storeFor(c).FindNearest(date)
+	if err == mgo.ErrNotFound {
+		return c.JSON(http.StatusNotFound, "no rates stored")
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if len(rate.Rates) == 0 {
+		return c.JSON(http.StatusNotFound, "no rates for "+rate.RateDate)
+	}
+
+	res := dispersion(rate)
+	res.RequestedDate = date
+	return c.JSON(http.StatusOK, res)
+}
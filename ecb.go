@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	mgo "gopkg.in/mgo.v2"
+)
+
+const ECB_URL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist-90d.xml"
+const ECB_HIST_URL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.xml"
+
+var errNotModified = errors.New("ecb: not modified")
+
+// ECB_PUBLISH_HOUR is when the ECB publishes the day's reference rates, in
+// Central European Time.
+const ECB_PUBLISH_HOUR = 16
+
+// nextECBUpdate estimates the next publication after the latest stored date:
+// 16:00 CET on the following business day, expressed in loc.
+func nextECBUpdate(latest string, loc *time.Location) (time.Time, error) {
+	cet, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.ParseInLocation(DATE_LAYOUT, latest, cet)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t = t.AddDate(0, 0, 1)
+	for !isBusinessDay(t.Format(DATE_LAYOUT)) {
+		t = t.AddDate(0, 0, 1)
+	}
+	t = time.Date(t.Year(), t.Month(), t.Day(), ECB_PUBLISH_HOUR, 0, 0, 0, cet)
+	return t.In(loc), nil
+}
+
+// FetchState holds the HTTP validators of the last ingested ECB file.
+type FetchState struct {
+	ID           string    `bson:"_id" json:"id"`
+	ETag         string    `bson:"etag" json:"etag"`
+	LastModified string    `bson:"last_modified" json:"lastModified"`
+	UpdatedAt    time.Time `bson:"updated_at" json:"updatedAt"`
+}
+
+type ecbCube struct {
+	Currency string  `xml:"currency,attr"`
+	Rate     float32 `xml:"rate,attr"`
+}
+
+type ecbCubeDate struct {
+	Time  string     `xml:"time,attr"`
+	Cubes []*ecbCube `xml:"Cube"`
+}
+
+type ecbResponse struct {
+	CubeDates []*ecbCubeDate `xml:"Cube>Cube"`
+}
+
+func fetchECB(ctx context.Context, prev *FetchState) ([]*Rate, *FetchState, error) {
+	return fetchECBFile(ctx, ECB_URL, prev)
+}
+
+// fetchECBFile downloads and parses an ECB rates file. When prev carries
+// validators they are sent as conditional headers and errNotModified is
+// returned on 304.
+func fetchECBFile(ctx context.Context, url string, prev *FetchState) (rates []*Rate, state *FetchState, err error) {
+	ctx, span := tracer.Start(ctx, "ecb.fetch")
+	span.SetAttributes(attribute.String("http.url", url))
+	defer func() {
+		if err != nil && err != errNotModified {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if prev != nil {
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
+
+	resp, err := ecbClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		span.SetAttributes(attribute.Bool("ecb.not_modified", true))
+		return nil, prev, errNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("ecb: unexpected status %s", resp.Status)
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var response ecbResponse
+	err = xml.Unmarshal(respBody, &response)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, cube := range response.CubeDates {
+		items := []*Item{}
+		for _, c := range cube.Cubes {
+			items = append(items, &Item{
+				Currency: c.Currency,
+				Rate:     c.Rate,
+			})
+		}
+
+		rates = append(rates, &Rate{
+			RateDate: cube.Time,
+			Rates:    items,
+			Source:   "ecb",
+		})
+	}
+	span.SetAttributes(attribute.Int("ecb.dates", len(rates)))
+
+	state = &FetchState{
+		ID:           "ecb",
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	return rates, state, nil
+}
+
+func (p *DB) GetFetchState(id string) (*FetchState, error) {
+	span := p.startSpan("GetFetchState")
+	defer span.End()
+
+	var state FetchState
+	err := db.C(STATE_COLLECTION).FindId(id).One(&state)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (p *DB) SaveFetchState(state *FetchState) error {
+	span := p.startSpan("SaveFetchState")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	state.UpdatedAt = time.Now().UTC()
+	_, err := db.C(STATE_COLLECTION).UpsertId(state.ID, state)
+	return err
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const ecbTestFile = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<Cube>
+		<Cube time="2024-01-31">
+			<Cube currency="USD" rate="1.0837"/>
+			<Cube currency="GBP" rate="0.85405"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+func TestFetchECBFileNotModified(t *testing.T) {
+	const etag = `"v1"`
+	const lastModified = "Wed, 31 Jan 2024 15:00:00 GMT"
+	served := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		served++
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write([]byte(ecbTestFile))
+	}))
+	defer srv.Close()
+
+	rates, state, err := fetchECBFile(context.Background(), srv.URL, nil)
+	if err != nil {
+		t.Fatalf("first fetch: %v", err)
+	}
+	if len(rates) != 1 || rates[0].RateDate != "2024-01-31" || len(rates[0].Rates) != 2 {
+		t.Fatalf("first fetch parsed %+v", rates)
+	}
+	if state.ETag != etag || state.LastModified != lastModified {
+		t.Fatalf("first fetch state = %+v", state)
+	}
+
+	rates, next, err := fetchECBFile(context.Background(), srv.URL, state)
+	if err != errNotModified {
+		t.Fatalf("conditional fetch err = %v, want errNotModified", err)
+	}
+	if rates != nil {
+		t.Errorf("conditional fetch returned %d dates, want none", len(rates))
+	}
+	if next != state {
+		t.Errorf("conditional fetch state = %+v, want the previous one", next)
+	}
+	if served != 1 {
+		t.Errorf("file served %d times, want 1", served)
+	}
+}
+
+func TestFetchECBFullBreaker(t *testing.T) {
+	testECB(t, ecbTestFile)
+	ctx := context.Background()
+
+	rates, err := fetchECBFull(ctx, ECB_HIST_URL)
+	if err != nil || len(rates) != 1 {
+		t.Fatalf("closed breaker: %d dates, %v", len(rates), err)
+	}
+
+	ecbBreaker = NewCircuitBreaker("ecb", 1, time.Hour)
+	ecbBreaker.record(errors.New("provider down"), time.Now())
+	if _, err := fetchECBFull(ctx, ECB_HIST_URL); err != errCircuitOpen {
+		t.Errorf("open breaker: err = %v, want errCircuitOpen", err)
+	}
+	if _, err := DryRunRefresh(ctx, 0); err != errCircuitOpen {
+		t.Errorf("dry run with open breaker: err = %v, want errCircuitOpen", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type EMAPoint struct {
+	Date string  `json:"date"`
+	Rate float32 `json:"rate"`
+	EMA  float64 `json:"ema"`
+}
+
+type EMARes struct {
+	Currency string      `json:"currency"`
+	Span     int         `json:"span"`
+	Alpha    float64     `json:"alpha"`
+	Seed     string      `json:"seed"`
+	Range    *DateRange  `json:"range"`
+	Series   []*EMAPoint `json:"series"`
+}
+
+// ema smooths values with alpha = 2/(span+1). With seed "sma" the first
+// span-1 points are dropped and the EMA starts at their simple average;
+// otherwise it starts at the first value.
+func ema(dates []string, values []float32, span int, seed string) []*EMAPoint {
+	alpha := 2 / (float64(span) + 1)
+	series := []*EMAPoint{}
+	start, prev := 0, 0.0
+	if seed == "sma" {
+		if len(values) < span {
+			return series
+		}
+		sum := 0.0
+		for _, v := range values[:span] {
+			sum += float64(v)
+		}
+		start, prev = span-1, sum/float64(span)
+	} else if len(values) > 0 {
+		prev = float64(values[0])
+	}
+	for i := start; i < len(values); i++ {
+		if i > start {
+			prev = alpha*float64(values[i]) + (1-alpha)*prev
+		}
+		series = append(series, &EMAPoint{Date: dates[i], Rate: values[i], EMA: prev})
+	}
+	return series
+}
+
+func getEMA(c echo.Context) error {
+	currency := strings.ToUpper(c.QueryParam("currency"))
+	if !currencyRe.MatchString(currency) {
+		return c.JSON(http.StatusBadRequest, "invalid currency")
+	}
+	span, err := strconv.Atoi(c.QueryParam("span"))
+	if err != nil || span < 1 || span > 1000 {
+		return c.JSON(http.StatusBadRequest, "span must be between 1 and 1000")
+	}
+	seed := c.QueryParam("seed")
+	if seed == "" {
+		seed = "first"
+	}
+	if seed != "first" && seed != "sma" {
+		return c.JSON(http.StatusBadRequest, "seed must be first or sma")
+	}
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	dates, values := []string{}, []float32{}
+	for i := range rates {
+		if v, ok := rates[i].RateMap()[currency]; ok {
+			dates = append(dates, rates[i].RateDate)
+			values = append(values, v)
+		}
+	}
+
+	res := &EMARes{
+		Currency: currency,
+		Span:     span,
+		Alpha:    2 / (float64(span) + 1),
+		Seed:     seed,
+		Range:    r,
+		Series:   ema(dates, values, span, seed),
+	}
+	return c.JSON(http.StatusOK, res)
+}
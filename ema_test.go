@@ -0,0 +1,61 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func TestEMA(t *testing.T) {
+	dates := []string{"2024-01-01", "2024-01-02", "2024-01-03", "2024-01-04", "2024-01-05"}
+	values := []float32{1, 2, 3, 4, 5}
+	tests := []struct {
+		name  string
+		span  int
+		seed  string
+		n     int
+		dates []string
+		want  []float64
+	}{
+		// alpha = 2/(3+1) = 0.5: each point is halfway from the last EMA.
+		{"first value seed", 3, "first", 5, dates, []float64{1, 1.5, 2.25, 3.125, 4.0625}},
+		{"sma seed", 3, "sma", 5, dates[2:], []float64{2, 3, 4}},
+		{"span 1 follows the rate", 1, "first", 5, dates, []float64{1, 2, 3, 4, 5}},
+		{"sma seed too short", 3, "sma", 2, nil, nil},
+		{"empty", 3, "first", 0, nil, nil},
+	}
+	for _, tt := range tests {
+		got := ema(dates[:tt.n], values[:tt.n], tt.span, tt.seed)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d points, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, p := range got {
+			if p.Date != tt.dates[i] || math.Abs(p.EMA-tt.want[i]) > 1e-9 {
+				t.Errorf("%s: point %d = %s %v, want %s %v", tt.name, i, p.Date, p.EMA, tt.dates[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestGetEMAValidation(t *testing.T) {
+	e := echo.New()
+	e.GET("/rates/ema", getEMA)
+	for _, query := range []string{
+		"currency=USD",
+		"currency=USD&span=0",
+		"currency=USD&span=ten",
+		"currency=USD&span=1001",
+		"currency=USD&span=10&seed=median",
+		"currency=US&span=10",
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rates/ema?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (p *DB) Count() (int, error) {
+	span := p.startSpan("Count")
+	defer span.End()
+
+	return db.C(config.Collection).Find(live(nil)).Count()
+}
+
+// RateIter walks the archive and then the hot collection. Archived dates
+// the hot collection holds again are skipped, as in mergeArchived.
+type RateIter struct {
+	// Total is how many documents the iterator yields.
+	Total int
+
+	iters []*mgo.Iter
+	skip  map[string]bool
+	err   error
+}
+
+func (it *RateIter) Next(rate *Rate) bool {
+	for len(it.iters) > 0 {
+		if it.iters[0].Next(rate) {
+			if len(it.iters) > 1 && it.skip[rate.RateDate] {
+				*rate = Rate{}
+				continue
+			}
+			return true
+		}
+		if err := it.iters[0].Close(); err != nil && it.err == nil {
+			it.err = err
+		}
+		it.iters = it.iters[1:]
+	}
+	return false
+}
+
+func (it *RateIter) Close() error {
+	for _, iter := range it.iters {
+		if err := iter.Close(); err != nil && it.err == nil {
+			it.err = err
+		}
+	}
+	it.iters = nil
+	return it.err
+}
+
+// IterRange iterates the live documents between start and end, either
+// empty for open, archived ones first, each collection in date order.
+func (p *DB) IterRange(start, end string) (*RateIter, error) {
+	span := p.startSpan("IterRange")
+	defer span.End()
+
+	dates := bson.M{}
+	if start != "" {
+		dates["$gte"] = start
+	}
+	if end != "" {
+		dates["$lte"] = end
+	}
+	query, hotQuery := bson.M{}, live(nil)
+	if len(dates) > 0 {
+		query["rate_date"] = dates
+		hotQuery["rate_date"] = dates
+	}
+
+	it := &RateIter{skip: map[string]bool{}}
+	hot, err := db.C(config.Collection).Find(hotQuery).Count()
+	if err != nil {
+		return nil, err
+	}
+	it.Total = hot
+	if p.crossesArchive(start) {
+		var inHot []string
+		err := db.C(config.Collection).Find(live(bson.M{"rate_date": bson.M{"$lt": archiveBoundary.Get(p)}})).Distinct("rate_date", &inHot)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range inHot {
+			it.skip[d] = true
+		}
+		archived, err := db.C(ARCHIVE_COLLECTION).Find(query).Count()
+		if err != nil {
+			return nil, err
+		}
+		overlap, err := db.C(ARCHIVE_COLLECTION).Find(bson.M{"$and": []bson.M{query, {"rate_date": bson.M{"$in": inHot}}}}).Count()
+		if err != nil {
+			return nil, err
+		}
+		it.Total += archived - overlap
+		it.iters = append(it.iters, db.C(ARCHIVE_COLLECTION).Find(query).Sort("rate_date").Batch(500).Iter())
+	}
+	it.iters = append(it.iters, db.C(config.Collection).Find(hotQuery).Sort("rate_date").Batch(500).Iter())
+	return it, nil
+}
+
+// getExport streams the rate documents between start and end, archived ones
+// included, as gzipped NDJSON (default) or a JSON array. The span is capped
+// at MAX_EXPORT_DAYS, and a missing start defaults to that many days before
+// end. Documents are read through an iterator so memory stays flat.
+func getExport(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = "ndjson"
+	}
+	if format != "ndjson" && format != "json" {
+		return c.JSON(http.StatusBadRequest, "format must be ndjson or json")
+	}
+
+	r, err := newDateRangeMax(c.QueryParam("start"), c.QueryParam("end"), config.MaxExportDays)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+
+	store := storeFor(c)
+	iter, err := store.IterRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+
+	filename := "rates-" + time.Now().UTC().Format("2006-01-02") + "." + format + ".gz"
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "application/gzip")
+	res.Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	res.Header().Set("X-Total-Count", strconv.Itoa(iter.Total))
+	res.WriteHeader(http.StatusOK)
+
+	gz := gzip.NewWriter(res)
+	defer gz.Close()
+
+	var rate Rate
+	first := true
+	if format == "json" {
+		gz.Write([]byte("["))
+	}
+	for iter.Next(&rate) {
+		b, err := json.Marshal(&rate)
+		if err != nil {
+			logCtx(c.Request().Context(), "export, error on Marshal", err)
+			continue
+		}
+		if format == "json" && !first {
+			gz.Write([]byte(","))
+		}
+		gz.Write(b)
+		if format == "ndjson" {
+			gz.Write([]byte("\n"))
+		}
+		first = false
+		rate = Rate{}
+	}
+	if format == "json" {
+		gz.Write([]byte("]"))
+	}
+	if err := iter.Close(); err != nil {
+		logCtx(c.Request().Context(), "export, error on iter", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func TestExportSpan(t *testing.T) {
+	defer func(prev int) { config.MaxExportDays = prev }(config.MaxExportDays)
+	config.MaxExportDays = 30
+
+	e := echo.New()
+	e.GET("/rates/export", getExport)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rates/export?start=2024-01-01&end=2024-03-01", nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("60-day export with a 30-day cap: status %d, want 422", rec.Code)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	store := testStore(t)
+	seeded := map[string]map[string]float32{
+		"2024-01-30": {"USD": 1.0846, "GBP": 0.8551},
+		"2024-01-31": {"USD": 1.0837, "GBP": 0.85405},
+	}
+	for date, rates := range seeded {
+		rate := rateOf(date, rates)
+		rate.Source = "ecb"
+		if _, err := store.Save(rate); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := echo.New()
+	e.GET("/rates/export", getExport)
+	e.POST("/rates/import", postImport)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rates/export", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("X-Total-Count") != "2" {
+		t.Fatalf("export: status %d, X-Total-Count %q", rec.Code, rec.Header().Get("X-Total-Count"))
+	}
+	file := rec.Body.Bytes()
+
+	if err := db.C(config.Collection).DropCollection(); err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/rates/import", bytes.NewReader(file))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("import: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for date, want := range seeded {
+		got, err := store.FindByDate(date)
+		if err != nil {
+			t.Fatalf("%s: %v", date, err)
+		}
+		if m := got.RateMap(); len(m) != len(want) || m["USD"] != want["USD"] || m["GBP"] != want["GBP"] {
+			t.Errorf("%s: re-imported %v, want %v", date, m, want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/labstack/echo"
+)
+
+// FeatureFlags gates individual endpoints. Every feature registered through
+// feature() starts enabled unless listed in DISABLED_FEATURES.
+type FeatureFlags struct {
+	mu    sync.RWMutex
+	flags map[string]bool
+}
+
+var features = &FeatureFlags{flags: map[string]bool{}}
+
+func (f *FeatureFlags) Register(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.flags[name]; ok {
+		return
+	}
+	f.flags[name] = true
+	for _, d := range config.DisabledFeatures {
+		if d == name {
+			f.flags[name] = false
+		}
+	}
+}
+
+func (f *FeatureFlags) Enabled(name string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	enabled, ok := f.flags[name]
+	return !ok || enabled
+}
+
+func (f *FeatureFlags) Set(name string, enabled bool) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	old, ok := f.flags[name]
+	if !ok {
+		return false
+	}
+	f.flags[name] = enabled
+	if old != enabled {
+		log.Printf("feature %q enabled=%t", name, enabled)
+	}
+	return true
+}
+
+func (f *FeatureFlags) All() map[string]bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	all := make(map[string]bool, len(f.flags))
+	for k, v := range f.flags {
+		all[k] = v
+	}
+	return all
+}
+
+func feature(name string) echo.MiddlewareFunc {
+	features.Register(name)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !features.Enabled(name) {
+				return c.JSON(config.FeatureDisabledStatus, "feature "+name+" is disabled")
+			}
+			return next(c)
+		}
+	}
+}
+
+type FeatureRes struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+func getFeatures(c echo.Context) error {
+	res := []*FeatureRes{}
+	for name, enabled := range features.All() {
+		res = append(res, &FeatureRes{Name: name, Enabled: enabled})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return c.JSON(http.StatusOK, res)
+}
+
+func putFeature(c echo.Context) error {
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if body.Enabled == nil {
+		return c.JSON(http.StatusBadRequest, "enabled is required")
+	}
+
+	name := c.Param("name")
+	if !features.Set(name, *body.Enabled) {
+		return c.JSON(http.StatusNotFound, "unknown feature "+name)
+	}
+	return c.JSON(http.StatusOK, &FeatureRes{Name: name, Enabled: *body.Enabled})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func TestFeatureFlags(t *testing.T) {
+	defer func(prev Config) { *config = prev }(*config)
+	defer func(prev *FeatureFlags) { features = prev }(features)
+	config.DisabledFeatures = []string{"analyze"}
+	config.FeatureDisabledStatus = http.StatusServiceUnavailable
+	features = &FeatureFlags{flags: map[string]bool{}}
+
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	e := echo.New()
+	e.GET("/rates/analyze", ok, feature("analyze"))
+	e.GET("/rates/latest", ok, feature("latest"))
+	e.PUT("/admin/features/:name", putFeature)
+
+	steps := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodGet, "/rates/analyze", "", http.StatusServiceUnavailable},
+		{http.MethodGet, "/rates/latest", "", http.StatusOK},
+		{http.MethodPut, "/admin/features/analyze", `{"enabled":true}`, http.StatusOK},
+		{http.MethodGet, "/rates/analyze", "", http.StatusOK},
+		{http.MethodPut, "/admin/features/latest", `{"enabled":false}`, http.StatusOK},
+		{http.MethodGet, "/rates/latest", "", http.StatusServiceUnavailable},
+		{http.MethodPut, "/admin/features/matrix", `{"enabled":false}`, http.StatusNotFound},
+		{http.MethodPut, "/admin/features/analyze", `{}`, http.StatusBadRequest},
+	}
+	for i, s := range steps {
+		req := httptest.NewRequest(s.method, s.path, strings.NewReader(s.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != s.want {
+			t.Errorf("step %d, %s %s: status %d, want %d", i, s.method, s.path, rec.Code, s.want)
+		}
+	}
+}
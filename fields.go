@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// jsonFields writes v as JSON, keeping only the top-level fields named in
+// ?fields= (applied per element for arrays). Unknown names are ignored and
+// an empty parameter returns the full response. ?string_rates=true renders
+// the rates as strings formatted for ?locale= (en, de or fr). ?as=list turns
+// each rates object into an array; see parseListOrder.
+func jsonFields(c echo.Context, code int, v interface{}) error {
+	fields := parseList(c.QueryParam("fields"))
+	stringRates, _ := strconv.ParseBool(c.QueryParam("string_rates"))
+	locale := c.QueryParam("locale")
+	if locale == "" {
+		locale = "en"
+	}
+	format, ok := numberFormats[locale]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, "locale must be one of en, de, fr")
+	}
+	order, err := parseListOrder(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if len(fields) == 0 && !stringRates && order == nil {
+		return c.JSON(code, v)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return err
+	}
+	if order != nil {
+		listRates(decoded, order)
+	}
+	if stringRates {
+		stringifyRates(decoded, format)
+	}
+	if len(fields) == 0 {
+		return c.JSON(code, decoded)
+	}
+	return c.JSON(code, projectFields(decoded, fields))
+}
+
+func projectFields(v interface{}, fields []string) interface{} {
+	switch t := v.(type) {
+	case []interface{}:
+		for i := range t {
+			t[i] = projectFields(t[i], fields)
+		}
+		return t
+	case map[string]interface{}:
+		res := map[string]interface{}{}
+		for _, f := range fields {
+			if val, ok := t[strings.TrimSpace(f)]; ok {
+				res[f] = val
+			}
+		}
+		return res
+	}
+	return v
+}
+
+// ListOrder sorts the entries of a rates array by "currency" or "rate".
+type ListOrder struct {
+	By   string
+	Desc bool
+}
+
+// parseListOrder reads ?as=, ?sortBy= and ?order=. It returns nil for the
+// default map form.
+func parseListOrder(c echo.Context) (*ListOrder, error) {
+	order := &ListOrder{By: "currency"}
+	switch c.QueryParam("sortBy") {
+	case "", "currency":
+	case "rate":
+		order.By = "rate"
+	default:
+		return nil, fmt.Errorf("sortBy must be currency or rate")
+	}
+	switch c.QueryParam("order") {
+	case "", "asc":
+	case "desc":
+		order.Desc = true
+	default:
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+	switch c.QueryParam("as") {
+	case "", "map":
+		return nil, nil
+	case "list":
+		return order, nil
+	}
+	return nil, fmt.Errorf("as must be map or list")
+}
+
+// listRates replaces every "rates" object of a decoded response with a
+// [{"currency","rate"}] array in order. Date-keyed rates, as served for
+// /rates/2020-01, keep their dates and get an array per date.
+func listRates(v interface{}, order *ListOrder) {
+	switch t := v.(type) {
+	case []interface{}:
+		for i := range t {
+			listRates(t[i], order)
+		}
+	case map[string]interface{}:
+		for key, val := range t {
+			rates, ok := val.(map[string]interface{})
+			if key != "rates" || !ok {
+				listRates(val, order)
+				continue
+			}
+			t[key] = rateList(rates, order)
+		}
+	}
+}
+
+func rateList(rates map[string]interface{}, order *ListOrder) interface{} {
+	list := []interface{}{}
+	dated := false
+	for code, n := range rates {
+		if byDate, ok := n.(map[string]interface{}); ok {
+			rates[code] = rateList(byDate, order)
+			dated = true
+			continue
+		}
+		list = append(list, map[string]interface{}{"currency": code, "rate": n})
+	}
+	if dated {
+		return rates
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i].(map[string]interface{}), list[j].(map[string]interface{})
+		ca, cb := a["currency"].(string), b["currency"].(string)
+		if ra, rb := a["rate"].(float64), b["rate"].(float64); order.By == "rate" && ra != rb {
+			return (ra < rb) != order.Desc
+		}
+		return (ca < cb) != (order.Desc && order.By == "currency")
+	})
+	return list
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+)
+
+// FileCache is the last provider response that parsed, kept on disk so the
+// service can start with data while Mongo or the ECB is unavailable.
+type FileCache struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Rates     []*Rate   `json:"rates"`
+}
+
+// Latest returns the newest cached date with rates, or nil.
+func (f *FileCache) Latest() *Rate {
+	var latest *Rate
+	for _, rate := range f.Rates {
+		if len(rate.Rates) > 0 && (latest == nil || rate.RateDate > latest.RateDate) {
+			latest = rate
+		}
+	}
+	return latest
+}
+
+// writeCacheFile replaces path with rates.
+func writeCacheFile(path string, rates []*Rate) error {
+	b, err := json.Marshal(&FileCache{FetchedAt: time.Now().UTC(), Rates: rates})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+// writeFileAtomic writes b next to path and renames it into place so a crash
+// never leaves a truncated file behind.
+func writeFileAtomic(path string, b []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadCacheFile reads the cache file when CACHE_FILE is set. A missing,
+// unreadable or empty file is logged and treated as no cache.
+func loadCacheFile(path string) *FileCache {
+	if path == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		log.Println("cache file, error on ReadFile", err)
+		return nil
+	}
+	var f FileCache
+	if err := json.Unmarshal(b, &f); err != nil {
+		log.Println("cache file,", path, "is corrupt, ignoring it:", err)
+		return nil
+	}
+	if f.Latest() == nil {
+		log.Println("cache file,", path, "has no rates, ignoring it")
+		return nil
+	}
+	return &f
+}
+
+// restoreCacheFile saves the cached rates when the store has no data yet.
+func restoreCacheFile(store *DB, f *FileCache) error {
+	if _, err := store.GetLatest(); err != mgo.ErrNotFound {
+		return err
+	}
+	for _, rate := range f.Rates {
+		if len(rate.Rates) == 0 {
+			continue
+		}
+		if _, err := store.Save(rate); err != nil {
+			return err
+		}
+	}
+	log.Println("restored", len(f.Rates), "dates fetched at", f.FetchedAt, "from the cache file")
+	cache.Invalidate()
+	return nil
+}
+
+// startIngest runs the startup refresh and starts the scheduler unless the
+// service is read-only. An empty store is filled from the cache file first,
+// and batches spooled by a previous process are queued ahead of the refresh.
+// With a cache file a failed refresh is logged rather than fatal since there
+// is data to serve.
+func startIngest(fallback *FileCache) {
+	if err := checkSchema(p); err != nil {
+		log.Fatal(err, "; run a newer build or set SCHEMA_CHECK=warn")
+	}
+	if config.ReadOnly {
+		log.Println("read-only mode, ingestion disabled")
+		return
+	}
+	if fallback != nil {
+		if err := restoreCacheFile(p, fallback); err != nil {
+			log.Println("cache file, error on restoreCacheFile", err)
+		}
+	}
+	ingestQueue.Load()
+	ingestQueue.Start()
+
+	if _, err := Refresh(TriggerStartup); err == errLockHeld {
+		log.Println("startup refresh skipped:", err)
+	} else if err != nil && fallback == nil {
+		log.Fatal(err)
+	} else if err != nil {
+		log.Println("startup refresh failed, serving stored data:", err)
+	}
+	startScheduler()
+}
+
+// fallbackRetry is how often serveFallback dials Mongo again.
+var fallbackRetry = 5 * time.Second
+
+// serveFallback answers /rates/latest from the cache file while Mongo is
+// unreachable and keeps dialling it; once connected ingestion starts as
+// usual. Routes that need Mongo answer 503 until then, see requireStore.
+func serveFallback(fallback *FileCache, err error) {
+	latest := fallback.Latest()
+	log.Println("mongo unavailable, serving", latest.RateDate, "from", config.CacheFile+":", err)
+	cache.SetLatest(latestFrom(latest))
+
+	go func() {
+		for {
+			time.Sleep(fallbackRetry)
+			if err := p.Open(); err != nil {
+				log.Println("mongo still unavailable:", err)
+				continue
+			}
+			log.Println("mongo connected")
+			startIngest(fallback)
+			return
+		}
+	}()
+}
+
+// requireStore answers 503 while Mongo has not been dialled yet, except
+// for /healthz and a /rates/latest the cache can serve.
+func requireStore() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if dbReady.Load() {
+				return next(c)
+			}
+			switch path := c.Request().URL.Path; {
+			case path == "/healthz":
+				return next(c)
+			case path == "/rates/latest" && c.QueryParam("source") == "" && cache.Latest() != nil:
+				return next(c)
+			}
+			return c.JSON(http.StatusServiceUnavailable, "mongo unavailable")
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// fallbackServer routes /rates/latest and /rates/analyze behind
+// requireStore, as main does.
+func fallbackServer() *echo.Echo {
+	e := echo.New()
+	e.Use(requireStore())
+	e.GET("/rates/latest", getLatest)
+	e.GET("/rates/analyze", getAnalyze)
+	return e
+}
+
+// getDate returns the status and the date field of a GET to path.
+func getDate(e *echo.Echo, path string) (int, string) {
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var res struct {
+		Date string `json:"date"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &res)
+	return rec.Code, res.Date
+}
+
+func TestServeFromCacheFile(t *testing.T) {
+	defer cache.Invalidate()
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := writeCacheFile(path, []*Rate{rateOf("2024-01-31", map[string]float32{"USD": 1.0837})}); err != nil {
+		t.Fatal(err)
+	}
+	fallback := loadCacheFile(path)
+	if fallback == nil {
+		t.Fatal("loadCacheFile ignored the file it just wrote")
+	}
+	cache.SetLatest(latestFrom(fallback.Latest()))
+
+	e := fallbackServer()
+	if status, date := getDate(e, "/rates/latest"); status != http.StatusOK || date != "2024-01-31" {
+		t.Errorf("/rates/latest before Mongo: %d %q, want 200 2024-01-31", status, date)
+	}
+	if status, _ := getDate(e, "/rates/latest?source=ecb"); status != http.StatusServiceUnavailable {
+		t.Errorf("/rates/latest?source=ecb before Mongo: %d, want 503", status)
+	}
+	if status, _ := getDate(e, "/rates/analyze"); status != http.StatusServiceUnavailable {
+		t.Errorf("/rates/analyze before Mongo: %d, want 503", status)
+	}
+}
+
+func TestRefreshWritesCacheFile(t *testing.T) {
+	testStore(t)
+	testECB(t, ecbTestFile)
+	defer func(prev Config) { *config = prev }(*config)
+	config.CacheFile = filepath.Join(t.TempDir(), "rates.json")
+
+	if _, err := Refresh(TriggerManual); err != nil {
+		t.Fatal(err)
+	}
+	f := loadCacheFile(config.CacheFile)
+	if f == nil || f.Latest().RateDate != "2024-01-31" {
+		t.Errorf("cache file after refresh = %+v, want 2024-01-31", f)
+	}
+}
+
+func TestReconnectAfterFallback(t *testing.T) {
+	testStore(t)
+	testECB(t, ecbTestFile)
+	defer cache.Invalidate()
+	defer func(prev Config) { *config = prev }(*config)
+	config.MongoHost, config.DBName = os.Getenv("MONGO_TEST_HOST"), db.Name
+	config.CacheFile = filepath.Join(t.TempDir(), "rates.json")
+	config.RefreshInterval = 0
+
+	own := db
+	db = nil
+	dbReady.Store(false)
+	t.Cleanup(func() {
+		if db != own {
+			db.Session.Close()
+		}
+		db = own
+	})
+	defer func(prev time.Duration) { fallbackRetry = prev }(fallbackRetry)
+	fallbackRetry = 10 * time.Millisecond
+
+	fallback := &FileCache{Rates: []*Rate{rateOf("2024-01-30", map[string]float32{"USD": 1.0846})}}
+	serveFallback(fallback, errors.New("no reachable servers"))
+	e := fallbackServer()
+	if status, date := getDate(e, "/rates/latest"); status != http.StatusOK || date != "2024-01-30" {
+		t.Errorf("/rates/latest while dialling: %d %q, want 200 2024-01-30", status, date)
+	}
+
+	// Once connected the fallback date is restored, the refresh replaces
+	// the cached latest and the cache file is rewritten.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if _, date := getDate(e, "/rates/latest"); date == "2024-01-31" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("/rates/latest never moved past the cache file after reconnecting")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if _, err := p.FindByDate("2024-01-30"); err != nil {
+		t.Errorf("fallback date not restored: %v", err)
+	}
+	if status, _ := getDate(e, "/rates/analyze"); status == http.StatusServiceUnavailable {
+		t.Error("/rates/analyze still 503 after reconnecting")
+	}
+	if f := loadCacheFile(config.CacheFile); f == nil || f.Latest().RateDate != "2024-01-31" {
+		t.Errorf("cache file after reconnect = %+v, want 2024-01-31", f)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type HalfLifeRes struct {
+	Currency string `json:"currency"`
+	// HalfLife is in observations, i.e. ECB business days. It is null when
+	// the series is not mean-reverting.
+	HalfLife      *float64   `json:"half_life"`
+	MeanReverting bool       `json:"mean_reverting"`
+	Beta          float64    `json:"beta"`
+	Alpha         float64    `json:"alpha"`
+	SampleSize    int        `json:"sample_size"`
+	Range         *DateRange `json:"range"`
+}
+
+// halfLife regresses each daily change of currency on the prior level,
+// Δy = α + β·y₋₁, over rates (sorted by date ascending). A negative β pulls
+// the rate back towards -α/β, and the Ornstein-Uhlenbeck half-life of that
+// pull is -ln 2 / β. Dates that don't quote currency are skipped.
+func halfLife(rates []Rate, currency string) *HalfLifeRes {
+	res := &HalfLifeRes{Currency: currency, Range: &DateRange{}}
+	var levels []float64
+	for i := range rates {
+		v, ok := rates[i].RateMap()[currency]
+		if !ok {
+			continue
+		}
+		if res.Range.Start == "" {
+			res.Range.Start = rates[i].RateDate
+		}
+		res.Range.End = rates[i].RateDate
+		levels = append(levels, float64(v))
+	}
+	if len(levels) < 2 {
+		return res
+	}
+
+	prev := levels[:len(levels)-1]
+	changes := make([]float64, len(prev))
+	for i := range prev {
+		changes[i] = levels[i+1] - levels[i]
+	}
+	res.SampleSize = len(changes)
+	if res.SampleSize < MIN_OBSERVATIONS {
+		return res
+	}
+	v := variance(prev)
+	if v == 0 {
+		return res
+	}
+	res.Beta = covariance(prev, changes) / v
+	res.Alpha = mean(changes) - res.Beta*mean(prev)
+	if res.Beta < 0 {
+		h := -math.Ln2 / res.Beta
+		res.HalfLife = &h
+		res.MeanReverting = true
+	}
+	return res
+}
+
+func getHalfLife(c echo.Context) error {
+	currency := strings.ToUpper(c.QueryParam("currency"))
+	if !currencyRe.MatchString(currency) {
+		return c.JSON(http.StatusBadRequest, "invalid currency")
+	}
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	res := halfLife(rates, currency)
+	if res.SampleSize < MIN_OBSERVATIONS {
+		return c.JSON(http.StatusUnprocessableEntity, "not enough observations")
+	}
+	return c.JSON(http.StatusOK, res)
+}
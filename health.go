@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+const DATE_LAYOUT = "2006-01-02"
+
+type Freshness struct {
+	LatestDate      string `json:"latest_date"`
+	AgeBusinessDays int    `json:"age_business_days"`
+	ThresholdDays   int    `json:"threshold_days"`
+	Stale           bool   `json:"stale"`
+}
+
+type Health struct {
+	Status    string                    `json:"status"`
+	Mongo     string                    `json:"mongo"`
+	ReadOnly  bool                      `json:"read_only"`
+	Freshness *Freshness                `json:"freshness,omitempty"`
+	Breakers  map[string]*BreakerStatus `json:"breakers"`
+}
+
+// businessDaysBetween counts the business days after from up to and
+// including to, skipping configured holidays.
+func businessDaysBetween(from, to time.Time) int {
+	days := 0
+	for d := from.AddDate(0, 0, 1); !d.After(to); d = d.AddDate(0, 0, 1) {
+		if isBusinessDay(d.Format(DATE_LAYOUT)) {
+			days++
+		}
+	}
+	return days
+}
+
+func checkFreshness(store *DB, now time.Time) (*Freshness, error) {
+	latest, err := store.GetLatest()
+	if err != nil {
+		return nil, err
+	}
+	date, err := time.Parse(DATE_LAYOUT, latest.RateDate)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Freshness{
+		LatestDate:      latest.RateDate,
+		AgeBusinessDays: businessDaysBetween(date, now.UTC().Truncate(24*time.Hour)),
+		ThresholdDays:   config.StaleThresholdDays,
+	}
+	f.Stale = f.AgeBusinessDays > f.ThresholdDays
+	return f, nil
+}
+
+func getHealthz(c echo.Context) error {
+	res := &Health{
+		Status:   "ok",
+		Mongo:    "ok",
+		ReadOnly: config.ReadOnly,
+		Breakers: map[string]*BreakerStatus{ecbBreaker.Name: ecbBreaker.Status()},
+	}
+	if !dbReady.Load() {
+		res.Status = "unavailable"
+		res.Mongo = "not connected"
+		return c.JSON(http.StatusServiceUnavailable, res)
+	}
+	if err := db.Session.Ping(); err != nil {
+		res.Status = "unavailable"
+		res.Mongo = err.Error()
+		return c.JSON(http.StatusServiceUnavailable, res)
+	}
+
+	f, err := checkFreshness(storeFor(c), time.Now())
+	if err != nil {
+		res.Status = "unavailable"
+		return c.JSON(http.StatusServiceUnavailable, res)
+	}
+	res.Freshness = f
+	if f.Stale {
+		res.Status = "stale"
+		return c.JSON(config.StaleStatus, res)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func getFreshness(c echo.Context) error {
+	f, err := checkFreshness(storeFor(c), time.Now())
+	if err != nil {
+		return c.JSON(http.StatusServiceUnavailable, err.Error())
+	}
+	if f.Stale {
+		return c.JSON(config.StaleStatus, f)
+	}
+	return c.JSON(http.StatusOK, f)
+}
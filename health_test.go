@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBusinessDaysBetween(t *testing.T) {
+	defer func(prev []string) { config.Holidays = prev }(config.Holidays)
+	config.Holidays = []string{"2024-01-01"}
+
+	tests := []struct {
+		from, to string
+		want     int
+	}{
+		{"2024-01-05", "2024-01-05", 0},
+		{"2024-01-05", "2024-01-07", 0},
+		{"2024-01-05", "2024-01-08", 1},
+		{"2024-01-08", "2024-01-15", 5},
+		{"2023-12-29", "2024-01-02", 1},
+	}
+	for _, tt := range tests {
+		from, _ := time.Parse(DATE_LAYOUT, tt.from)
+		to, _ := time.Parse(DATE_LAYOUT, tt.to)
+		if got := businessDaysBetween(from, to); got != tt.want {
+			t.Errorf("businessDaysBetween(%s, %s) = %d, want %d", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
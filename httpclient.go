@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// ecbClient is used for every request to the ECB. main replaces it with
+// one built from the proxy and CA settings before anything is fetched.
+var ecbClient = &http.Client{}
+
+// newECBClient builds the ECB client. An explicit ECBProxy wins; otherwise
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY are honored as usual. ECBCAFile is
+// added to the system roots so a TLS-intercepting proxy can be trusted.
+func newECBClient(c *Config) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if c.ECBProxy != "" {
+		proxy, err := parseProxyURL(c.ECBProxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if c.ECBCAFile != "" {
+		pool, err := loadCAFile(c.ECBCAFile)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+func parseProxyURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("ecb_proxy: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("ecb_proxy: scheme must be http, https or socks5, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("ecb_proxy: %q has no host", redactUserinfo(s))
+	}
+	return u, nil
+}
+
+// loadCAFile returns the system roots plus every certificate in path.
+func loadCAFile(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ecb_ca_file: %v", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("ecb_ca_file: %s contains no PEM certificates", path)
+	}
+	return pool, nil
+}
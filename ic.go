@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// IC_MAX_WINDOW caps lookback and horizon, in observations.
+const IC_MAX_WINDOW = 250
+
+type ICReq struct {
+	Currency string `json:"currency"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	// Lookback defaults to Horizon.
+	Lookback int `json:"lookback"`
+	Horizon  int `json:"horizon"`
+}
+
+type ICRes struct {
+	Currency   string     `json:"currency"`
+	Lookback   int        `json:"lookback"`
+	Horizon    int        `json:"horizon"`
+	IC         float64    `json:"ic"`
+	SampleSize int        `json:"sample_size"`
+	Range      *DateRange `json:"range"`
+}
+
+// informationCoefficient pairs, on every date with a full window either
+// side, the percent move of currency over the prior lookback observations
+// with its move over the next horizon, and returns their Spearman
+// correlation. A positive IC means moves tend to continue, a negative one
+// that they revert. rates are sorted by date ascending; dates that don't
+// quote currency are skipped.
+func informationCoefficient(rates []Rate, currency string, lookback, horizon int) *ICRes {
+	res := &ICRes{Currency: currency, Lookback: lookback, Horizon: horizon, Range: &DateRange{}}
+	var dates []string
+	var levels []float32
+	for i := range rates {
+		if v, ok := rates[i].RateMap()[currency]; ok {
+			dates = append(dates, rates[i].RateDate)
+			levels = append(levels, v)
+		}
+	}
+
+	var past, future []float64
+	for i := lookback; i+horizon < len(levels); i++ {
+		if res.Range.Start == "" {
+			res.Range.Start = dates[i]
+		}
+		res.Range.End = dates[i]
+		past = append(past, percentChange(levels[i-lookback], levels[i]))
+		future = append(future, percentChange(levels[i], levels[i+horizon]))
+	}
+	res.SampleSize = len(past)
+	if res.SampleSize >= MIN_OBSERVATIONS {
+		res.IC = spearman(past, future)
+	}
+	return res
+}
+
+func postIC(c echo.Context) error {
+	var req ICReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	currency := strings.ToUpper(req.Currency)
+	if !currencyRe.MatchString(currency) {
+		return c.JSON(http.StatusBadRequest, "invalid currency")
+	}
+	if req.Horizon < 1 || req.Horizon > IC_MAX_WINDOW {
+		return c.JSON(http.StatusBadRequest, "horizon must be between 1 and "+strconv.Itoa(IC_MAX_WINDOW))
+	}
+	if req.Lookback == 0 {
+		req.Lookback = req.Horizon
+	}
+	if req.Lookback < 1 || req.Lookback > IC_MAX_WINDOW {
+		return c.JSON(http.StatusBadRequest, "lookback must be between 1 and "+strconv.Itoa(IC_MAX_WINDOW))
+	}
+	r, err := newDateRange(req.Start, req.End)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	res := informationCoefficient(rates, currency, req.Lookback, req.Horizon)
+	if res.SampleSize < MIN_OBSERVATIONS {
+		return c.JSON(http.StatusUnprocessableEntity, "not enough observations")
+	}
+	return c.JSON(http.StatusOK, res)
+}
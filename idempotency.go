@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const IDEMPOTENCY_COLLECTION = "idempotency_keys"
+
+// IdempotencyRecord is the stored outcome of a request sent with an
+// Idempotency-Key. Status is 0 while the first request is still running;
+// such a record expires after IDEMPOTENCY_LEASE rather than IDEMPOTENCY_TTL.
+// RequestHash covers the query string and the body; see requestHash.
+type IdempotencyRecord struct {
+	ID          string    `bson:"_id"`
+	RequestHash string    `bson:"body_hash"`
+	Status      int       `bson:"status"`
+	ContentType string    `bson:"content_type,omitempty"`
+	Body        []byte    `bson:"body,omitempty"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// ClaimIdempotencyKey records id as in progress for IDEMPOTENCY_LEASE. When
+// id is already held by an unexpired record, that record is returned instead
+// and nothing changes.
+func (p *DB) ClaimIdempotencyKey(id, hash string) (*IdempotencyRecord, error) {
+	span := p.startSpan("ClaimIdempotencyKey")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	change := mgo.Change{
+		Update: bson.M{
+			"$set": bson.M{
+				"body_hash":  hash,
+				"status":     0,
+				"expires_at": now.Add(config.IdempotencyLease),
+			},
+			"$unset": bson.M{"content_type": "", "body": ""},
+		},
+		Upsert: true,
+	}
+	// Matches only an expired record, so a live one makes the upsert
+	// collide on _id. Mongo's TTL monitor runs about once a minute.
+	query := bson.M{"_id": id, "expires_at": bson.M{"$lt": now}}
+	_, err := db.C(IDEMPOTENCY_COLLECTION).Find(query).Apply(change, nil)
+	if !mgo.IsDup(err) {
+		return nil, err
+	}
+
+	var rec IdempotencyRecord
+	if err := db.C(IDEMPOTENCY_COLLECTION).FindId(id).One(&rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (p *DB) CompleteIdempotencyKey(id string, status int, contentType string, body []byte) error {
+	span := p.startSpan("CompleteIdempotencyKey")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	return db.C(IDEMPOTENCY_COLLECTION).UpdateId(id, bson.M{"$set": bson.M{
+		"status":       status,
+		"content_type": contentType,
+		"body":         body,
+		"expires_at":   time.Now().UTC().Add(config.IdempotencyTTL),
+	}})
+}
+
+func (p *DB) ReleaseIdempotencyKey(id string) error {
+	span := p.startSpan("ReleaseIdempotencyKey")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	err := db.C(IDEMPOTENCY_COLLECTION).RemoveId(id)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// recordingWriter passes a response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// requestHash fingerprints what a key was first used with. The query is
+// part of it so that ?dryRun=true and a real run, or two baseline names,
+// can't share a response. It is encoded with sorted keys, so reordering
+// params doesn't count as a change.
+func requestHash(query url.Values, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(query.Encode()))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotencyPrincipal names the caller a key belongs to: the admin, the
+// X-API-Key name set by quota, or else the client IP. idempotent runs
+// before route-level auth, so without it a key reused by someone else would
+// replay the first caller's response.
+func idempotencyPrincipal(c echo.Context) string {
+	if isAdmin(c) {
+		return "admin"
+	}
+	if name, _ := c.Get("api_key").(string); name != "" {
+		return "key:" + name
+	}
+	return "ip:" + c.RealIP()
+}
+
+// idempotent replays the stored response of a POST, PUT, PATCH or DELETE
+// repeated by the same caller with the same Idempotency-Key, route, query
+// and body for IDEMPOTENCY_TTL. The same key with another query or body is
+// a 422, and a repeat while the first request is still running is a 409.
+// Errors, panics and 5xx responses release the key, so the client can retry
+// them.
+func idempotent() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			key := req.Header.Get("Idempotency-Key")
+			switch {
+			case key == "", req.Method == "GET", req.Method == "HEAD", req.Method == "OPTIONS":
+				return next(c)
+			case config.ReadOnly || !dbReady.Load():
+				return next(c)
+			case len(key) > 255:
+				return c.JSON(http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+			}
+
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, err.Error())
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			hash := requestHash(req.URL.Query(), body)
+			id := req.Method + " " + req.URL.Path + " " + idempotencyPrincipal(c) + " " + key
+
+			store := storeFor(c)
+			rec, err := store.ClaimIdempotencyKey(id, hash)
+			switch {
+			case err != nil:
+				return c.JSON(http.StatusInternalServerError, err.Error())
+			case rec != nil && rec.RequestHash != hash:
+				return c.JSON(http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different query or body")
+			case rec != nil && rec.Status == 0:
+				return c.JSON(http.StatusConflict, "a request with this Idempotency-Key is still in progress")
+			case rec != nil:
+				c.Response().Header().Set("Idempotent-Replayed", "true")
+				return c.Blob(rec.Status, rec.ContentType, rec.Body)
+			}
+
+			w := &recordingWriter{ResponseWriter: c.Response().Writer, status: http.StatusOK}
+			c.Response().Writer = w
+			completed := false
+			defer func() {
+				c.Response().Writer = w.ResponseWriter
+				if completed {
+					return
+				}
+				if err := store.ReleaseIdempotencyKey(id); err != nil {
+					logCtx(req.Context(), "idempotency, error on ReleaseIdempotencyKey", err)
+				}
+			}()
+
+			err = next(c)
+			if err != nil || w.status >= 500 {
+				return err
+			}
+			completed = true
+			contentType := c.Response().Header().Get(echo.HeaderContentType)
+			if err := store.CompleteIdempotencyKey(id, w.status, contentType, w.body.Bytes()); err != nil {
+				logCtx(req.Context(), "idempotency, error on CompleteIdempotencyKey", err)
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+)
+
+func TestRequestHash(t *testing.T) {
+	q := func(s string) url.Values {
+		v, _ := url.ParseQuery(s)
+		return v
+	}
+	body := []byte(`{"a":1}`)
+	base := requestHash(q("dryRun=true"), body)
+	tests := []struct {
+		name  string
+		query string
+		body  string
+		same  bool
+	}{
+		{"identical", "dryRun=true", `{"a":1}`, true},
+		{"no query", "", `{"a":1}`, false},
+		{"other query", "dryRun=false", `{"a":1}`, false},
+		{"other body", "dryRun=true", `{"a":2}`, false},
+	}
+	for _, tt := range tests {
+		if got := requestHash(q(tt.query), []byte(tt.body)) == base; got != tt.same {
+			t.Errorf("%s: same hash = %v, want %v", tt.name, got, tt.same)
+		}
+	}
+	if requestHash(q("name=a&x=1"), nil) != requestHash(q("x=1&name=a"), nil) {
+		t.Errorf("param order changed the hash")
+	}
+	if requestHash(q("name=a"), nil) == requestHash(q("name=b"), nil) {
+		t.Errorf("baseline names a and b share a hash")
+	}
+}
+
+func TestIdempotencyPrincipal(t *testing.T) {
+	defer func(prev string) { config.AdminAPIKey = prev }(config.AdminAPIKey)
+	config.AdminAPIKey = "s3cret"
+
+	tests := []struct {
+		name   string
+		auth   string
+		apiKey string
+		want   string
+	}{
+		{"admin", "Bearer s3cret", "", "admin"},
+		{"wrong admin key", "Bearer guess", "", "ip:192.0.2.1"},
+		{"partner", "", "acme", "key:acme"},
+		{"anonymous", "", "", "ip:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/rates/import", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if tt.auth != "" {
+			req.Header.Set(echo.HeaderAuthorization, tt.auth)
+		}
+		c := echo.New().NewContext(req, httptest.NewRecorder())
+		if tt.apiKey != "" {
+			c.Set("api_key", tt.apiKey)
+		}
+		if got := idempotencyPrincipal(c); got != tt.want {
+			t.Errorf("%s: principal = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	testStore(t)
+	defer func(prev string) { config.AdminAPIKey = prev }(config.AdminAPIKey)
+	config.AdminAPIKey = "s3cret"
+
+	calls := 0
+	e := echo.New()
+	e.Use(middleware.Recover())
+	e.Use(idempotent())
+	e.POST("/admin/thing", func(c echo.Context) error {
+		calls++
+		return c.JSON(http.StatusOK, "secret result")
+	}, adminAuth())
+	e.POST("/flaky", func(c echo.Context) error {
+		calls++
+		if calls == 1 {
+			panic("crash")
+		}
+		return c.JSON(http.StatusOK, "ok")
+	})
+
+	send := func(path, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.Header.Set("Idempotency-Key", "k1")
+		if auth != "" {
+			req.Header.Set(echo.HeaderAuthorization, auth)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := send("/admin/thing", "Bearer s3cret"); rec.Code != http.StatusOK {
+		t.Fatalf("admin request: status %d", rec.Code)
+	}
+	if rec := send("/admin/thing", "Bearer guess"); rec.Code != http.StatusUnauthorized || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("repeat with a wrong key: status %d, replayed %q", rec.Code, rec.Header().Get("Idempotent-Replayed"))
+	}
+	if rec := send("/admin/thing", "Bearer s3cret"); rec.Header().Get("Idempotent-Replayed") != "true" || calls != 1 {
+		t.Errorf("admin repeat: replayed %q after %d calls", rec.Header().Get("Idempotent-Replayed"), calls)
+	}
+
+	calls = 0
+	if rec := send("/flaky", ""); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("panicking request: status %d", rec.Code)
+	}
+	if rec := send("/flaky", ""); rec.Code != http.StatusOK || calls != 2 {
+		t.Errorf("retry after a panic: status %d after %d calls, want a fresh 200", rec.Code, calls)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type ImportRow struct {
+	Date  string             `json:"date"`
+	Rates map[string]float32 `json:"rates"`
+	// Timestamp marks an intraday point; it must fall on Date in UTC.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// UnmarshalJSON also reads the stored Rate shape /rates/export writes:
+// rateDate for date, ts for timestamp and rates as a list of
+// {currency, rate}. Overrides and annotations in that shape are not
+// imported, only the rates.
+func (r *ImportRow) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Date      string          `json:"date"`
+		RateDate  string          `json:"rateDate"`
+		Rates     json.RawMessage `json:"rates"`
+		Timestamp *time.Time      `json:"timestamp"`
+		TS        *time.Time      `json:"ts"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*r = ImportRow{Date: raw.Date, Timestamp: raw.Timestamp}
+	if r.Date == "" {
+		r.Date = raw.RateDate
+	}
+	if r.Timestamp == nil && raw.TS != nil && !raw.TS.IsZero() {
+		r.Timestamp = raw.TS
+	}
+	rates := bytes.TrimSpace(raw.Rates)
+	if len(rates) == 0 || rates[0] != '[' {
+		return json.Unmarshal(nonNull(rates), &r.Rates)
+	}
+	var items []*Item
+	if err := json.Unmarshal(rates, &items); err != nil {
+		return err
+	}
+	r.Rates = map[string]float32{}
+	for _, item := range items {
+		r.Rates[item.Currency] = item.Rate
+	}
+	return nil
+}
+
+// nonNull turns a missing JSON value into null so it decodes to nothing.
+func nonNull(b []byte) []byte {
+	if len(b) == 0 {
+		return []byte("null")
+	}
+	return b
+}
+
+// decodeImportRows reads an import body: a JSON array of rows, or one row
+// per line with Content-Type application/x-ndjson, optionally gzipped with
+// Content-Encoding: gzip. A /rates/export file can be posted back as is.
+func decodeImportRows(req *http.Request) ([]*ImportRow, error) {
+	var body io.Reader = req.Body
+	if req.Header.Get(echo.HeaderContentEncoding) == "gzip" {
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	rows := []*ImportRow{}
+	dec := json.NewDecoder(body)
+	if !strings.HasPrefix(req.Header.Get(echo.HeaderContentType), "application/x-ndjson") {
+		err := dec.Decode(&rows)
+		return rows, err
+	}
+	for {
+		row := &ImportRow{}
+		err := dec.Decode(row)
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %v", len(rows)+1, err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func (r *ImportRow) Validate() error {
+	if errs := r.Problems(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// Problems returns every validation error of the row rather than only the
+// first, sorted by currency for stable output.
+func (r *ImportRow) Problems() []error {
+	var errs []error
+	if _, err := time.Parse(DATE_LAYOUT, r.Date); err != nil {
+		errs = append(errs, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", r.Date))
+	} else if r.Timestamp != nil && r.Timestamp.UTC().Format(DATE_LAYOUT) != r.Date {
+		errs = append(errs, fmt.Errorf("timestamp %s is not on %s in UTC", r.Timestamp.Format(time.RFC3339), r.Date))
+	}
+	if len(r.Rates) == 0 {
+		errs = append(errs, fmt.Errorf("rates must not be empty"))
+	}
+	codes := make([]string, 0, len(r.Rates))
+	for code := range r.Rates {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if !currencyRe.MatchString(code) {
+			errs = append(errs, fmt.Errorf("invalid currency code %q", code))
+		}
+		if r.Rates[code] <= 0 {
+			errs = append(errs, fmt.Errorf("rate for %s must be positive", code))
+		}
+	}
+	return errs
+}
+
+// key identifies the document a row writes: the daily document of its date,
+// or one intraday point of it.
+func (r *ImportRow) key() string {
+	if r.Timestamp == nil {
+		return r.Date
+	}
+	return r.Date + " " + r.Timestamp.UTC().Format(time.RFC3339Nano)
+}
+
+func (r *ImportRow) toRate() *Rate {
+	items := []*Item{}
+	for code, v := range r.Rates {
+		items = append(items, &Item{Currency: code, Rate: v})
+	}
+	rate := &Rate{RateDate: r.Date, Rates: items, Source: "import"}
+	if r.Timestamp != nil {
+		rate.Timestamp = r.Timestamp.UTC()
+	}
+	return rate
+}
+
+// ImportDryRunRes is what an import would change: the diff of its valid
+// rows, and the rows that would be rejected.
+type ImportDryRunRes struct {
+	DryRun bool `json:"dry_run"`
+	*DiffReport
+	Invalid []*ItemResult `json:"invalid"`
+}
+
+func dryRunImport(store *DB, rows []*ImportRow, tolerance float64) (*ImportDryRunRes, error) {
+	res := &ImportDryRunRes{DryRun: true, Invalid: []*ItemResult{}}
+	rates := []*Rate{}
+	for i, row := range rows {
+		if err := row.Validate(); err != nil {
+			res.Invalid = append(res.Invalid, itemError(i, http.StatusUnprocessableEntity, err))
+			continue
+		}
+		rates = append(rates, row.toRate())
+	}
+	report, err := diffAgainstStore(store, rates, tolerance)
+	res.DiffReport = report
+	return res, err
+}
+
+func postImport(c echo.Context) error {
+	rows, err := decodeImportRows(c.Request())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if len(rows) == 0 {
+		return c.JSON(http.StatusBadRequest, "at least one row is required")
+	}
+
+	dryRun, tolerance, err := parseDryRun(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	store := storeFor(c)
+	if dryRun {
+		res, err := dryRunImport(store, rows, tolerance)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+		return c.JSON(http.StatusOK, res)
+	}
+	items := []*ItemResult{}
+	for i, row := range rows {
+		if err := row.Validate(); err != nil {
+			items = append(items, itemError(i, http.StatusUnprocessableEntity, err))
+			continue
+		}
+		save := store.Save
+		if row.Timestamp != nil {
+			save = store.SaveIntraday
+		}
+		result, err := save(row.toRate())
+		if err != nil {
+			items = append(items, itemError(i, http.StatusInternalServerError, err))
+			continue
+		}
+		items = append(items, &ItemResult{Index: i, Status: http.StatusOK, Result: result})
+	}
+	cache.Invalidate()
+
+	return c.JSON(newBatchRes(items))
+}
+
+type ImportIssue struct {
+	Row     int    `json:"row,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Date    string `json:"date,omitempty"`
+	Message string `json:"message"`
+}
+
+type ImportValidationRes struct {
+	Valid  bool           `json:"valid"`
+	Rows   int            `json:"rows"`
+	Issues []*ImportIssue `json:"issues"`
+}
+
+// validateImport decodes an import body row by row so it can report the
+// line each problem starts on. A syntax error ends the scan since nothing
+// after it can be located reliably. Rows are numbered from 1.
+func validateImport(body []byte) *ImportValidationRes {
+	res := &ImportValidationRes{Issues: []*ImportIssue{}}
+	// lineAt skips the separator after offset so a row is reported on the
+	// line it starts on.
+	lineAt := func(offset int64) int {
+		rest := body[offset:]
+		offset += int64(len(rest) - len(bytes.TrimLeft(rest, ", \t\r\n")))
+		return bytes.Count(body[:offset], []byte("\n")) + 1
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		res.Issues = append(res.Issues, &ImportIssue{Line: 1, Message: "body must be a JSON array of rows"})
+		return res
+	}
+
+	seen := map[string]int{}
+	for dec.More() {
+		res.Rows++
+		row := &ImportRow{}
+		line := lineAt(dec.InputOffset())
+		if err := dec.Decode(row); err != nil {
+			res.Issues = append(res.Issues, &ImportIssue{Row: res.Rows, Line: line, Message: err.Error()})
+			if _, ok := err.(*json.UnmarshalTypeError); !ok {
+				return res
+			}
+			continue
+		}
+		for _, err := range row.Problems() {
+			res.Issues = append(res.Issues, &ImportIssue{Row: res.Rows, Line: line, Date: row.Date, Message: err.Error()})
+		}
+		if first, ok := seen[row.key()]; ok && row.Date != "" {
+			res.Issues = append(res.Issues, &ImportIssue{
+				Row: res.Rows, Line: line, Date: row.Date,
+				Message: fmt.Sprintf("duplicate date, first seen in row %d", first),
+			})
+		} else {
+			seen[row.key()] = res.Rows
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		res.Issues = append(res.Issues, &ImportIssue{Line: lineAt(dec.InputOffset()), Message: err.Error()})
+	}
+	if res.Rows == 0 {
+		res.Issues = append(res.Issues, &ImportIssue{Message: "at least one row is required"})
+	}
+	res.Valid = len(res.Issues) == 0
+	return res
+}
+
+// postImportValidate lints an import body without writing anything.
+func postImportValidate(c echo.Context) error {
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, validateImport(body))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestImportRowUnmarshal(t *testing.T) {
+	ts := time.Date(2024, 1, 31, 14, 15, 0, 0, time.UTC)
+	stored := rateOf("2024-01-31", map[string]float32{"USD": 1.0837, "GBP": 0.85405})
+	stored.Source = "ecb"
+	exported, _ := json.Marshal(stored)
+	intraday := &Rate{RateDate: "2024-01-31", Rates: stored.Rates, Timestamp: ts}
+	exportedIntraday, _ := json.Marshal(intraday)
+
+	tests := []struct {
+		name string
+		body string
+		ts   *time.Time
+	}{
+		{"import shape", `{"date":"2024-01-31","rates":{"USD":1.0837,"GBP":0.85405}}`, nil},
+		{"import shape with timestamp", `{"date":"2024-01-31","rates":{"USD":1.0837,"GBP":0.85405},"timestamp":"2024-01-31T14:15:00Z"}`, &ts},
+		{"export shape", string(exported), nil},
+		{"export shape with ts", string(exportedIntraday), &ts},
+	}
+	for _, tt := range tests {
+		var row ImportRow
+		if err := json.Unmarshal([]byte(tt.body), &row); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if row.Date != "2024-01-31" || len(row.Rates) != 2 || row.Rates["USD"] != 1.0837 || row.Rates["GBP"] != 0.85405 {
+			t.Errorf("%s: decoded %+v", tt.name, row)
+		}
+		if (row.Timestamp == nil) != (tt.ts == nil) || (tt.ts != nil && !row.Timestamp.Equal(*tt.ts)) {
+			t.Errorf("%s: timestamp = %v, want %v", tt.name, row.Timestamp, tt.ts)
+		}
+		if err := row.Validate(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestDecodeImportRows(t *testing.T) {
+	const ndjson = `{"rateDate":"2024-01-30","rates":[{"currency":"USD","rate":1.0846}]}
+{"rateDate":"2024-01-31","rates":[{"currency":"USD","rate":1.0837}]}
+`
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(ndjson))
+	w.Close()
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		encoding    string
+		rows        int
+		wantErr     bool
+	}{
+		{"array", []byte(`[{"date":"2024-01-31","rates":{"USD":1.1}}]`), "application/json", "", 1, false},
+		{"ndjson", []byte(ndjson), "application/x-ndjson", "", 2, false},
+		{"gzipped ndjson", gz.Bytes(), "application/x-ndjson", "gzip", 2, false},
+		{"bad ndjson line", []byte(ndjson + "{oops\n"), "application/x-ndjson", "", 0, true},
+		{"not gzip", []byte(ndjson), "application/x-ndjson", "gzip", 0, true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/rates/import", bytes.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		if tt.encoding != "" {
+			req.Header.Set("Content-Encoding", tt.encoding)
+		}
+		rows, err := decodeImportRows(req)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && len(rows) != tt.rows {
+			t.Errorf("%s: %d rows, want %d", tt.name, len(rows), tt.rows)
+		}
+	}
+}
+
+func TestValidateImport(t *testing.T) {
+	type issue struct {
+		row, line int
+		msg       string
+	}
+	tests := []struct {
+		name   string
+		body   string
+		rows   int
+		issues []issue
+	}{
+		{
+			"several error types",
+			`[
+{"date":"2024-13-01","rates":{"USD":1.1}},
+{"date":"2024-01-02","rates":{}},
+{"date":"2024-01-03","rates":{"usd":1.1}},
+{"date":"2024-01-04","rates":{"USD":-1}},
+{"date":"2024-01-03","rates":{"USD":1.1}},
+{"date":"2024-01-05","rates":{"USD":1.1}}
+]`,
+			6,
+			[]issue{
+				{1, 2, "invalid date"},
+				{2, 3, "rates must not be empty"},
+				{3, 4, "invalid currency code"},
+				{4, 5, "must be positive"},
+				{5, 6, "duplicate date, first seen in row 3"},
+			},
+		},
+		{"valid", `[{"date":"2024-01-05","rates":{"USD":1.1}}]`, 1, nil},
+		{"not an array", `{"date":"2024-01-05"}`, 0, []issue{{0, 1, "JSON array"}}},
+		{"empty", `[]`, 0, []issue{{0, 0, "at least one row"}}},
+		{"truncated", "[\n{\"date\":\"2024-01-05\",", 1, []issue{{1, 2, "unexpected EOF"}}},
+	}
+	for _, tt := range tests {
+		res := validateImport([]byte(tt.body))
+		if res.Rows != tt.rows || res.Valid != (len(tt.issues) == 0) || len(res.Issues) != len(tt.issues) {
+			b, _ := json.Marshal(res)
+			t.Errorf("%s: got %s, want %d rows and %d issues", tt.name, b, tt.rows, len(tt.issues))
+			continue
+		}
+		for i, want := range tt.issues {
+			got := res.Issues[i]
+			if got.Row != want.row || got.Line != want.line || !bytes.Contains([]byte(got.Message), []byte(want.msg)) {
+				t.Errorf("%s: issue %d = %+v, want row %d line %d %q", tt.name, i, got, want.row, want.line, want.msg)
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type IndexPoint struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
+
+type IndexRes struct {
+	BaseDate          string                   `json:"base_date"`
+	RequestedBaseDate string                   `json:"requested_base_date,omitempty"`
+	Note              string                   `json:"note,omitempty"`
+	Range             *DateRange               `json:"range"`
+	Series            map[string][]*IndexPoint `json:"series"`
+	Missing           []string                 `json:"missing,omitempty"`
+}
+
+// rebase rescales each currency's series so its rate on rates[base] is 100.
+// Currencies not quoted on the base date are reported as missing.
+func rebase(rates []Rate, base int, symbols []string) (map[string][]*IndexPoint, []string) {
+	series := map[string][]*IndexPoint{}
+	missing := []string{}
+	baseRates := rates[base].RateMap()
+	for _, sym := range symbols {
+		b, ok := eurRate(baseRates, sym)
+		if !ok {
+			missing = append(missing, sym)
+			continue
+		}
+		points := []*IndexPoint{}
+		for i := range rates {
+			v, ok := eurRate(rates[i].RateMap(), sym)
+			if !ok {
+				continue
+			}
+			points = append(points, &IndexPoint{Date: rates[i].RateDate, Value: v / b * 100})
+		}
+		series[sym] = points
+	}
+	return series, missing
+}
+
+func getIndex(c echo.Context) error {
+	symbols, err := parseSymbols(c.Param("currency") + "," + c.QueryParam("symbols"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+	baseDate := c.QueryParam("baseDate")
+	if baseDate != "" {
+		if _, err := time.Parse(DATE_LAYOUT, baseDate); err != nil {
+			return c.JSON(http.StatusBadRequest, "baseDate must be YYYY-MM-DD")
+		}
+		if (r.Start != "" && baseDate < r.Start) || (r.End != "" && baseDate > r.End) {
+			return c.JSON(http.StatusBadRequest, "baseDate must fall within start and end")
+		}
+	}
+
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if len(rates) == 0 {
+		return c.JSON(http.StatusNotFound, "no rates in range")
+	}
+
+	// Fall back to the nearest following stored date.
+	base := 0
+	for base < len(rates) && rates[base].RateDate < baseDate {
+		base++
+	}
+	if base == len(rates) {
+		return c.JSON(http.StatusNotFound, "no rates on or after baseDate "+baseDate)
+	}
+
+	res := &IndexRes{
+		BaseDate: rates[base].RateDate,
+		Range:    &DateRange{Start: rates[0].RateDate, End: rates[len(rates)-1].RateDate},
+	}
+	if baseDate != "" && baseDate != res.BaseDate {
+		res.RequestedBaseDate = baseDate
+		res.Note = fmt.Sprintf("no rates stored for %s, rebased on %s", baseDate, res.BaseDate)
+	}
+	res.Series, res.Missing = rebase(rates, base, symbols)
+	if len(res.Missing) > 0 {
+		res.Note = strings.TrimSpace(res.Note + " " + strings.Join(res.Missing, ",") + " not quoted on the base date")
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	TriggerStartup  = "startup"
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+const (
+	RunRunning     = "running"
+	RunSuccess     = "success"
+	RunNotModified = "not_modified"
+	RunFailed      = "failed"
+	// RunQueued means the batch was fetched but Mongo was unavailable; see
+	// IngestQueue.
+	RunQueued = "queued"
+)
+
+var errRefreshRunning = errors.New("a refresh is already running")
+
+// refreshMu keeps the scheduler and the admin endpoint from ingesting at the
+// same time within one process.
+var refreshMu sync.Mutex
+
+var ecbBreaker *CircuitBreaker
+
+// fetchECBFull downloads a whole ECB file through ecbBreaker, so backfill,
+// verify and the dry run fail fast while the provider is down, as Refresh
+// does.
+func fetchECBFull(ctx context.Context, url string) ([]*Rate, error) {
+	var rates []*Rate
+	err := ecbBreaker.Call(func() error {
+		var err error
+		rates, _, err = fetchECBFile(ctx, url, nil)
+		return err
+	}, nil)
+	return rates, err
+}
+
+// RefreshStatus records the outcome of the latest refresh attempts.
+type RefreshStatus struct {
+	LastAttempt time.Time `json:"last_attempt"`
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// Failing reports whether the most recent attempt failed.
+func (s RefreshStatus) Failing() bool {
+	return s.LastError != ""
+}
+
+type refreshTracker struct {
+	mu     sync.RWMutex
+	status RefreshStatus
+}
+
+var refreshStatus = &refreshTracker{}
+
+func (t *refreshTracker) Record(at time.Time, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.LastAttempt = at
+	if err != nil {
+		t.status.LastError = err.Error()
+		return
+	}
+	t.status.LastSuccess = at
+	t.status.LastError = ""
+}
+
+func (t *refreshTracker) Get() RefreshStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.status
+}
+
+type IngestRun struct {
+	ID         bson.ObjectId       `bson:"_id" json:"id"`
+	Trigger    string              `bson:"trigger" json:"trigger"`
+	Provider   string              `bson:"provider" json:"provider"`
+	Status     string              `bson:"status" json:"status"`
+	StartedAt  time.Time           `bson:"started_at" json:"startedAt"`
+	FinishedAt time.Time           `bson:"finished_at,omitempty" json:"finishedAt,omitempty"`
+	DurationMs int64               `bson:"duration_ms" json:"durationMs"`
+	Inserted   []string            `bson:"inserted" json:"inserted"`
+	Updated    []string            `bson:"updated" json:"updated"`
+	Skipped    int                 `bson:"skipped" json:"skipped"`
+	Empty      []string            `bson:"empty" json:"empty"`
+	Rejected   []string            `bson:"rejected" json:"rejected"`
+	Suspicious int                 `bson:"suspicious" json:"suspicious"`
+	Coverage   []*CoverageChange   `bson:"coverage_changes" json:"coverageChanges"`
+	Conflicts  []*OverrideConflict `bson:"override_conflicts,omitempty" json:"overrideConflicts,omitempty"`
+	Error      string              `bson:"error,omitempty" json:"error,omitempty"`
+
+	// Backfill runs only; see backfill.
+	Range       *DateRange `bson:"range,omitempty" json:"range,omitempty"`
+	Force       bool       `bson:"force,omitempty" json:"force,omitempty"`
+	Checkpoint  string     `bson:"checkpoint,omitempty" json:"checkpoint,omitempty"`
+	Processed   int        `bson:"processed,omitempty" json:"processed,omitempty"`
+	Total       int        `bson:"total,omitempty" json:"total,omitempty"`
+	Unavailable []string   `bson:"unavailable,omitempty" json:"unavailable,omitempty"`
+	Resumed     int        `bson:"resumed,omitempty" json:"resumed,omitempty"`
+}
+
+func Refresh(trigger string) (run *IngestRun, err error) {
+	if !refreshMu.TryLock() {
+		return nil, errRefreshRunning
+	}
+	defer refreshMu.Unlock()
+
+	ctx, span := tracer.Start(context.Background(), "ingest")
+	span.SetAttributes(attribute.String("ingest.trigger", trigger))
+	store := p.WithContext(ctx)
+
+	release, err := holdLock(store, "ingest", config.LockTTL)
+	if isStoreDown(err) {
+		// Fetch anyway; the batch is queued below until Mongo is back.
+		logCtx(ctx, "ingest, store unavailable, running without the lock:", err)
+		release, err = func() {}, nil
+	}
+	if err != nil {
+		span.End()
+		return nil, err
+	}
+	defer release()
+
+	run = &IngestRun{
+		ID:        bson.NewObjectId(),
+		Trigger:   trigger,
+		Provider:  "ecb",
+		Status:    RunRunning,
+		StartedAt: time.Now().UTC(),
+		Inserted:  []string{},
+		Updated:   []string{},
+		Empty:     []string{},
+		Rejected:  []string{},
+		Coverage:  []*CoverageChange{},
+	}
+	if err := store.InsertIngestRun(run); err != nil {
+		logCtx(ctx, "ingest, error on InsertIngestRun", err)
+	}
+
+	// queued is why the batch was queued instead of saved. The refresh has
+	// not succeeded yet; drainOne records the outcome once it is persisted.
+	var queued error
+	defer func() {
+		run.FinishedAt = time.Now().UTC()
+		run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+		if queued != nil {
+			refreshStatus.Record(run.FinishedAt, fmt.Errorf("refresh queued: %v", queued))
+		} else {
+			refreshStatus.Record(run.FinishedAt, err)
+		}
+		if err != nil {
+			run.Status = RunFailed
+			run.Error = err.Error()
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		if err := store.UpdateIngestRun(run); err != nil {
+			logCtx(ctx, "ingest, error on UpdateIngestRun", err)
+		}
+		if err := store.PruneIngestRuns(config.IngestRunRetention); err != nil {
+			logCtx(ctx, "ingest, error on PruneIngestRuns", err)
+		}
+		span.End()
+	}()
+
+	prev, err := store.GetFetchState("ecb")
+	if isStoreDown(err) {
+		prev, err = nil, nil
+	}
+	if err != nil {
+		return run, err
+	}
+
+	var rates []*Rate
+	var state *FetchState
+	err = ecbBreaker.Call(func() error {
+		rates, state, err = fetchECB(ctx, prev)
+		return err
+	}, func(err error) bool { return err == errNotModified })
+	if err == errNotModified {
+		logCtx(ctx, "ingest skipped, ECB file not modified")
+		run.Status = RunNotModified
+		return run, nil
+	}
+	if err != nil {
+		return run, err
+	}
+	if config.CacheFile != "" {
+		if err := writeCacheFile(config.CacheFile, rates); err != nil {
+			logCtx(ctx, "ingest, error on writeCacheFile", err)
+		}
+	}
+
+	if ingestQueue.Len() > 0 {
+		// Older batches are still waiting for Mongo; this one goes after
+		// them so a newer file is never overwritten by an older one.
+		ingestQueue.Push(run, rates, state, errQueueNotEmpty)
+		run.Status, queued = RunQueued, errQueueNotEmpty
+		return run, nil
+	}
+	err = persistRates(ctx, store, run, rates, state)
+	if isStoreDown(err) {
+		logCtx(ctx, "ingest, store unavailable, queueing", len(rates), "dates:", err)
+		ingestQueue.Push(run, rates, state, err)
+		run.Status, queued = RunQueued, err
+		return run, nil
+	}
+	return run, err
+}
+
+// persistRates saves a parsed batch into store, recording the outcome on run,
+// and then the fetch state, unless state is nil because the batch isn't the
+// live file. Saving is idempotent so a batch that failed half-way can be
+// persisted again.
+func persistRates(ctx context.Context, store *DB, run *IngestRun, rates []*Rate, state *FetchState) error {
+	procs := buildPostProcessors(store, config.PostProcessors)
+	for _, rate := range rates {
+		if err := persistRate(ctx, store, run, procs, rate); err != nil {
+			return err
+		}
+	}
+	logCtx(ctx, "ingest done:", len(run.Inserted), "inserted,", len(run.Updated), "updated,", run.Skipped, "skipped")
+
+	if state != nil {
+		if err := store.SaveFetchState(state); err != nil {
+			return err
+		}
+	}
+	run.Status = RunSuccess
+
+	cache.Invalidate()
+	if config.WarmupCache {
+		warmup()
+	}
+
+	if len(run.Inserted) > 0 {
+		if latest, err := buildLatest(store); err != nil {
+			logCtx(ctx, "ingest, error on buildLatest", err)
+		} else {
+			broadcaster.Publish(latest)
+		}
+	}
+	return nil
+}
+
+// persistRate runs one parsed date through the post-processors and the
+// outlier check and saves it, recording the outcome on run. Empty and
+// rejected dates are recorded and not saved.
+func persistRate(ctx context.Context, store *DB, run *IngestRun, procs []PostProcessor, rate *Rate) error {
+	span := trace.SpanFromContext(ctx)
+
+	// The ECB occasionally publishes a holiday with no Cube children.
+	// Storing it would make GetLatest return an empty set.
+	if len(rate.Rates) == 0 {
+		logCtx(ctx, "ingest, skipping", rate.RateDate, "with no rates")
+		run.Empty = append(run.Empty, rate.RateDate)
+		return nil
+	}
+
+	if err := applyPostProcessors(procs, rate); errors.Is(err, errRejected) {
+		logCtx(ctx, "ingest,", rate.RateDate, err)
+		run.Rejected = append(run.Rejected, rate.RateDate)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	flagged, current, err := flagOutliers(store, rate)
+	if err != nil {
+		return err
+	}
+	for _, o := range flagged {
+		logCtx(ctx, "ingest, suspicious", o.Currency, "on", o.RateDate, "moved", o.ChangePct, "% since", o.PreviousDate)
+	}
+	run.Suspicious += len(flagged)
+	if config.QuarantineOutliers && len(flagged) > 0 {
+		if err := quarantine(store, rate, current, flagged); err != nil {
+			return err
+		}
+		if len(rate.Rates) == 0 {
+			return nil
+		}
+	}
+
+	result, err := store.Save(rate)
+	if err != nil {
+		return err
+	}
+	for _, c := range rate.Conflicts {
+		logCtx(ctx, "ingest, kept override of", c.Currency, "on", c.Date, "at", c.Override, "over incoming", c.Incoming)
+	}
+	run.Conflicts = append(run.Conflicts, rate.Conflicts...)
+	switch result {
+	case SaveInserted:
+		run.Inserted = append(run.Inserted, rate.RateDate)
+		if prev, err := store.FindBefore(rate.RateDate); err == nil {
+			if change := diffCoverage(prev, rate); change != nil {
+				logCtx(ctx, "ingest, coverage changed on", change.Date, "added", change.Added, "removed", change.Removed)
+				run.Coverage = append(run.Coverage, change)
+			}
+		}
+	case SaveUpdated:
+		run.Updated = append(run.Updated, rate.RateDate)
+	default:
+		run.Skipped++
+	}
+	span.AddEvent("rate saved", trace.WithAttributes(
+		attribute.String("rate_date", rate.RateDate),
+		attribute.String("result", result),
+		attribute.Int("items", len(rate.Rates)),
+	))
+	return nil
+}
+
+// startScheduler refreshes every REFRESH_INTERVAL; a zero interval keeps the
+// single startup fetch.
+func startScheduler() {
+	if config.RefreshInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(config.RefreshInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := Refresh(TriggerSchedule); err != nil {
+				log.Println("scheduler, error on Refresh", err)
+			}
+			if config.ArchiveAfterDays > 0 {
+				if _, err := archive(p, archiveCutoff(time.Now())); err != nil && err != errLockHeld {
+					log.Println("scheduler, error on archive", err)
+				}
+			}
+		}
+	}()
+}
+
+func (p *DB) InsertIngestRun(run *IngestRun) error {
+	span := p.startSpan("InsertIngestRun")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	return db.C(INGEST_RUNS_COLLECTION).Insert(run)
+}
+
+func (p *DB) UpdateIngestRun(run *IngestRun) error {
+	span := p.startSpan("UpdateIngestRun")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	// Upsert, since a run that started during an outage was never inserted.
+	_, err := db.C(INGEST_RUNS_COLLECTION).UpsertId(run.ID, run)
+	return err
+}
+
+// PruneIngestRuns keeps the newest keep runs and deletes the rest.
+func (p *DB) PruneIngestRuns(keep int) error {
+	span := p.startSpan("PruneIngestRuns")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	var oldest IngestRun
+	err := db.C(INGEST_RUNS_COLLECTION).Find(nil).Sort("-started_at").Skip(keep - 1).One(&oldest)
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = db.C(INGEST_RUNS_COLLECTION).RemoveAll(bson.M{"started_at": bson.M{"$lt": oldest.StartedAt}})
+	return err
+}
+
+func (p *DB) FindIngestRuns(limit int) ([]IngestRun, error) {
+	span := p.startSpan("FindIngestRuns")
+	defer span.End()
+
+	runs := []IngestRun{}
+	err := db.C(INGEST_RUNS_COLLECTION).Find(nil).Sort("-started_at").Limit(limit).All(&runs)
+	return runs, err
+}
+
+func (p *DB) FindIngestRun(id string) (*IngestRun, error) {
+	span := p.startSpan("FindIngestRun")
+	defer span.End()
+
+	var run IngestRun
+	err := db.C(INGEST_RUNS_COLLECTION).FindId(bson.ObjectIdHex(id)).One(&run)
+	return &run, err
+}
+
+func postAdminRefresh(c echo.Context) error {
+	dryRun, tolerance, err := parseDryRun(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if dryRun {
+		report, err := DryRunRefresh(c.Request().Context(), tolerance)
+		if err != nil {
+			return c.JSON(http.StatusBadGateway, err.Error())
+		}
+		return c.JSON(http.StatusOK, report)
+	}
+
+	run, err := Refresh(TriggerManual)
+	if err == errRefreshRunning || err == errLockHeld {
+		return c.JSON(http.StatusConflict, err.Error())
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadGateway, run)
+	}
+	return c.JSON(http.StatusOK, run)
+}
+
+func getIngestions(c echo.Context) error {
+	limit := 20
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 500 {
+			return c.JSON(http.StatusBadRequest, "limit must be between 1 and 500")
+		}
+		limit = n
+	}
+
+	runs, err := storeFor(c).FindIngestRuns(limit)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, runs)
+}
+
+func getIngestion(c echo.Context) error {
+	id := c.Param("id")
+	if !bson.IsObjectIdHex(id) {
+		return c.JSON(http.StatusBadRequest, "invalid ingestion id")
+	}
+
+	run, err := storeFor(c).FindIngestRun(id)
+	if err == mgo.ErrNotFound {
+		return c.JSON(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, run)
+}
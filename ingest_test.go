@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// ecbHolidayFile publishes 2024-04-01 with no rates, after a normal day.
+const ecbHolidayFile = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<Cube>
+		<Cube time="2024-04-01"></Cube>
+		<Cube time="2024-03-28"><Cube currency="USD" rate="1.0811"/></Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+func TestFetchECBFileEmptyDay(t *testing.T) {
+	testECB(t, ecbHolidayFile)
+	rates, _, err := fetchECBFile(context.Background(), ECB_URL, nil)
+	if err != nil {
+		t.Fatalf("an empty day is not a parse error: %v", err)
+	}
+	items := map[string]int{}
+	for _, r := range rates {
+		items[r.RateDate] = len(r.Rates)
+	}
+	if n, ok := items["2024-04-01"]; !ok || n != 0 || items["2024-03-28"] != 1 {
+		t.Errorf("parsed %v, want 2024-04-01 with no rates and 2024-03-28 with one", items)
+	}
+
+	testECB(t, "<gesmes:Envelope><Cube>")
+	if _, _, err := fetchECBFile(context.Background(), ECB_URL, nil); err == nil {
+		t.Error("a truncated file parsed without error")
+	}
+}
+
+func TestRefreshSkipsEmptyDay(t *testing.T) {
+	store := testStore(t)
+	testECB(t, ecbHolidayFile)
+
+	run, err := Refresh(TriggerManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run.Empty) != 1 || run.Empty[0] != "2024-04-01" {
+		t.Errorf("run.Empty = %v, want [2024-04-01]", run.Empty)
+	}
+	if _, err := store.FindByDate("2024-04-01"); err == nil {
+		t.Error("the empty 2024-04-01 was stored")
+	}
+	latest, err := store.GetLatest()
+	if err != nil || latest.RateDate != "2024-03-28" || len(latest.Rates) == 0 {
+		t.Errorf("GetLatest = %+v, %v; want the populated 2024-03-28", latest, err)
+	}
+}
+
+func TestRefreshTracker(t *testing.T) {
+	t0 := time.Date(2024, 1, 31, 16, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		errs        []error
+		failing     bool
+		lastSuccess time.Time
+	}{
+		{"never run", nil, false, time.Time{}},
+		{"succeeded", []error{nil}, false, t0},
+		{"failed after a success", []error{nil, errors.New("ECB down")}, true, t0},
+		{"recovered", []error{nil, errors.New("ECB down"), nil}, false, t0.Add(2 * time.Hour)},
+	}
+	for _, tt := range tests {
+		tracker := &refreshTracker{}
+		for i, err := range tt.errs {
+			tracker.Record(t0.Add(time.Duration(i)*time.Hour), err)
+		}
+		if s := tracker.Get(); s.Failing() != tt.failing || !s.LastSuccess.Equal(tt.lastSuccess) {
+			t.Errorf("%s: failing %v, last success %v; want %v, %v", tt.name, s.Failing(), s.LastSuccess, tt.failing, tt.lastSuccess)
+		}
+	}
+}
+
+func TestLatestStaleAfterFailedRefresh(t *testing.T) {
+	store := testStore(t)
+	defer func(prev *refreshTracker) { refreshStatus = prev }(refreshStatus)
+	refreshStatus = &refreshTracker{}
+	defer cache.Invalidate()
+
+	e := echo.New()
+	e.GET("/rates/latest", getLatest)
+	latest := func() LatestRes {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rates/latest", nil))
+		var res LatestRes
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("/rates/latest: %d %s", rec.Code, rec.Body.String())
+		}
+		return res
+	}
+
+	testECB(t, ecbTestFile)
+	if _, err := Refresh(TriggerManual); err != nil {
+		t.Fatal(err)
+	}
+	if res := latest(); res.Stale || res.LastSuccessfulRefresh == nil {
+		t.Fatalf("after a good refresh: stale %v, last success %v", res.Stale, res.LastSuccessfulRefresh)
+	}
+
+	testECB(t, "<gesmes:Envelope><Cube>")
+	if _, err := Refresh(TriggerManual); err == nil {
+		t.Fatal("refresh of a truncated file succeeded")
+	}
+	res := latest()
+	if !res.Stale || res.LastSuccessfulRefresh == nil || res.Date != "2024-01-31" {
+		t.Errorf("after a failed refresh: stale %v, last success %v, date %q; want the last good data flagged stale",
+			res.Stale, res.LastSuccessfulRefresh, res.Date)
+	}
+	if _, err := store.FindByDate("2024-01-31"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	FillLinear  = "linear"
+	FillForward = "forward"
+)
+
+// SERIES_PAD_DAYS is how far outside the window known points are looked up,
+// so days at either edge can still be filled. It covers Easter, the longest
+// regular ECB closure.
+const SERIES_PAD_DAYS = 10
+
+type SeriesPoint struct {
+	Date         string  `json:"date"`
+	Rate         float32 `json:"rate"`
+	Interpolated bool    `json:"interpolated"`
+}
+
+type SeriesRes struct {
+	Currency string         `json:"currency"`
+	Fill     string         `json:"fill"`
+	Points   []*SeriesPoint `json:"points"`
+}
+
+// fillSeries returns a point for every calendar day from start to end. Days
+// without a quote of currency in rates (sorted by date ascending) are
+// interpolated between their neighbours, or carry the previous value with
+// FillForward. Days that can't be filled, before the first known point or
+// after the last one for FillLinear, are left out.
+func fillSeries(rates []Rate, currency, fill string, start, end time.Time) []*SeriesPoint {
+	type known struct {
+		t    time.Time
+		rate float32
+	}
+	var points []known
+	for i := range rates {
+		v, ok := rates[i].RateMap()[currency]
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(DATE_LAYOUT, rates[i].RateDate)
+		if err != nil {
+			continue
+		}
+		points = append(points, known{t, v})
+	}
+
+	res := []*SeriesPoint{}
+	next := 0
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		for next < len(points) && points[next].t.Before(d) {
+			next++
+		}
+		if next < len(points) && points[next].t.Equal(d) {
+			res = append(res, &SeriesPoint{Date: d.Format(DATE_LAYOUT), Rate: points[next].rate})
+			continue
+		}
+		if next == 0 {
+			continue
+		}
+		prev := points[next-1]
+		rate := prev.rate
+		if fill == FillLinear {
+			if next == len(points) {
+				continue
+			}
+			after := points[next]
+			frac := d.Sub(prev.t).Hours() / after.t.Sub(prev.t).Hours()
+			rate = float32(float64(prev.rate) + frac*float64(after.rate-prev.rate))
+		}
+		res = append(res, &SeriesPoint{Date: d.Format(DATE_LAYOUT), Rate: rate, Interpolated: true})
+	}
+	return res
+}
+
+func getSeries(c echo.Context) error {
+	currency := strings.ToUpper(c.Param("currency"))
+	if !currencyRe.MatchString(currency) {
+		return c.JSON(http.StatusBadRequest, "invalid currency")
+	}
+	fill := c.QueryParam("fill")
+	if fill == "" {
+		fill = FillLinear
+	}
+	if fill != FillLinear && fill != FillForward {
+		return c.JSON(http.StatusBadRequest, "fill must be linear or forward")
+	}
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+	if r.Start == "" || r.End == "" {
+		return c.JSON(http.StatusBadRequest, "start and end are required")
+	}
+	page, err := parsePage(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	start, _ := time.Parse(DATE_LAYOUT, page.Start(r.Start))
+	end, _ := time.Parse(DATE_LAYOUT, r.End)
+
+	rates, err := storeFor(c).FindRange(
+		start.AddDate(0, 0, -SERIES_PAD_DAYS).Format(DATE_LAYOUT),
+		end.AddDate(0, 0, SERIES_PAD_DAYS).Format(DATE_LAYOUT),
+	)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	points := fillSeries(rates, currency, fill, start, end)
+	return c.JSON(http.StatusOK, &SeriesRes{
+		Currency: currency,
+		Fill:     fill,
+		Points:   points[:page.Cut(c, len(points), func(i int) string { return points[i].Date })],
+	})
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// INTRADAY_COLLECTION holds timestamped points, any number per date, apart
+// from the single daily document per date in the rates collection.
+const INTRADAY_COLLECTION = "intraday_rates"
+
+// SaveIntraday inserts or updates the point of rate.RateDate at
+// rate.Timestamp and reports which of the two happened, like Save.
+func (p *DB) SaveIntraday(rate *Rate) (string, error) {
+	span := p.startSpan("SaveIntraday")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return "", err
+	}
+
+	c := db.C(INTRADAY_COLLECTION)
+	now := time.Now().UTC()
+	old, err := p.FindIntraday(rate.RateDate, rate.Timestamp)
+	if err == mgo.ErrNotFound {
+		rate.ID = bson.NewObjectId()
+		rate.CreatedAt, rate.UpdatedAt = now, now
+		upgrade(rate)
+		return SaveInserted, c.Insert(rate)
+	}
+	if err != nil {
+		return "", err
+	}
+	if sameRates(old, rate) {
+		return SaveUnchanged, nil
+	}
+	rate.ID = old.ID
+	rate.CreatedAt, rate.UpdatedAt = old.CreatedAt, now
+	upgrade(rate)
+	return SaveUpdated, c.UpdateId(old.ID, rate)
+}
+
+// FindIntraday returns the point of date at ts.
+func (p *DB) FindIntraday(date string, ts time.Time) (*Rate, error) {
+	span := p.startSpan("FindIntraday")
+	defer span.End()
+
+	var rate Rate
+	err := db.C(INTRADAY_COLLECTION).Find(bson.M{"rate_date": date, "ts": ts.UTC()}).One(&rate)
+	return &rate, err
+}
+
+// FindIntradayAt returns the latest intraday point at or before ts.
+func (p *DB) FindIntradayAt(ts time.Time) (*Rate, error) {
+	span := p.startSpan("FindIntradayAt")
+	defer span.End()
+
+	ts = ts.UTC()
+	query := p.bySource(bson.M{
+		"rate_date": bson.M{"$lte": ts.Format(DATE_LAYOUT)},
+		"ts":        bson.M{"$lte": ts},
+	})
+	var rate Rate
+	err := db.C(INTRADAY_COLLECTION).Find(query).Sort("-rate_date", "-ts").One(&rate)
+	return &rate, err
+}
+
+// pickAt chooses between the daily document and the intraday point in
+// effect at the same moment, either of which may be nil. An intraday point
+// is more precise than the daily document of its own date or an earlier
+// one, but older than a daily document of a later date.
+func pickAt(daily, intraday *Rate) *Rate {
+	if intraday == nil {
+		return daily
+	}
+	if daily == nil || intraday.RateDate >= daily.RateDate {
+		return intraday
+	}
+	return daily
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestPickAt(t *testing.T) {
+	daily := func(date string) *Rate { return &Rate{RateDate: date} }
+	tests := []struct {
+		name            string
+		daily, intraday *Rate
+		want            string
+	}{
+		{"nothing", nil, nil, ""},
+		{"daily only", daily("2020-01-14"), nil, "2020-01-14"},
+		{"intraday only", nil, daily("2020-01-15"), "2020-01-15"},
+		{"intraday same day", daily("2020-01-15"), daily("2020-01-15"), "intraday"},
+		{"intraday later", daily("2020-01-14"), daily("2020-01-15"), "intraday"},
+		{"daily later", daily("2020-01-15"), daily("2020-01-14"), "2020-01-15"},
+	}
+	for _, tt := range tests {
+		got := pickAt(tt.daily, tt.intraday)
+		switch {
+		case tt.want == "":
+			if got != nil {
+				t.Errorf("%s: got %v, want nil", tt.name, got.RateDate)
+			}
+		case tt.want == "intraday":
+			if got != tt.intraday {
+				t.Errorf("%s: did not pick the intraday point", tt.name)
+			}
+		case got == nil || got.RateDate != tt.want:
+			t.Errorf("%s: got %v, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateImportIntraday(t *testing.T) {
+	body := `[
+{"date":"2020-01-15","timestamp":"2020-01-15T09:00:00Z","rates":{"USD":1.11}},
+{"date":"2020-01-15","timestamp":"2020-01-15T14:00:00Z","rates":{"USD":1.12}},
+{"date":"2020-01-15","rates":{"USD":1.115}},
+{"date":"2020-01-15","timestamp":"2020-01-15T14:00:00Z","rates":{"USD":1.13}},
+{"date":"2020-01-15","timestamp":"2020-01-16T00:30:00Z","rates":{"USD":1.14}}
+]`
+	res := validateImport([]byte(body))
+	rows := []int{}
+	for _, issue := range res.Issues {
+		rows = append(rows, issue.Row)
+	}
+	// Several points a day are fine; only the repeated 14:00 point and the
+	// timestamp off its date are not.
+	if len(rows) != 2 || rows[0] != 4 || rows[1] != 5 {
+		t.Errorf("issues on rows %v, want [4 5]: %+v", rows, res.Issues)
+	}
+}
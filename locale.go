@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NumberFormat holds a locale's decimal and digit-grouping separators.
+type NumberFormat struct {
+	Decimal string
+	Group   string
+}
+
+var numberFormats = map[string]NumberFormat{
+	"en": {Decimal: ".", Group: ","},
+	"de": {Decimal: ",", Group: "."},
+	"fr": {Decimal: ",", Group: " "},
+}
+
+// formatNumber renders x with the shortest exact representation, then swaps
+// in the locale's separators and groups the integer part by thousands.
+func formatNumber(x float64, f NumberFormat) string {
+	s := strconv.FormatFloat(x, 'f', -1, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, d := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(f.Group)
+		}
+		b.WriteRune(d)
+	}
+	if frac != "" {
+		b.WriteString(f.Decimal)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+// stringifyRates replaces the numbers of every "rates" object or ?as=list
+// array in a decoded response with locale-formatted strings.
+func stringifyRates(v interface{}, f NumberFormat) {
+	switch t := v.(type) {
+	case []interface{}:
+		for i := range t {
+			stringifyRates(t[i], f)
+		}
+	case map[string]interface{}:
+		for key, val := range t {
+			if list, ok := val.([]interface{}); ok && key == "rates" {
+				for _, entry := range list {
+					if e, ok := entry.(map[string]interface{}); ok {
+						if x, ok := e["rate"].(float64); ok {
+							e["rate"] = formatNumber(x, f)
+						}
+					}
+				}
+				continue
+			}
+			rates, ok := val.(map[string]interface{})
+			if key != "rates" || !ok {
+				stringifyRates(val, f)
+				continue
+			}
+			for code, n := range rates {
+				switch x := n.(type) {
+				case float64:
+					rates[code] = formatNumber(x, f)
+				case map[string]interface{}, []interface{}:
+					// Date-keyed rates, as served for /rates/2020-01.
+					stringifyRates(map[string]interface{}{"rates": x}, f)
+				}
+			}
+		}
+	}
+}
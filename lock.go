@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const LOCKS_COLLECTION = "locks"
+
+var errLockHeld = errors.New("another instance is ingesting")
+
+// instanceID identifies this process as a lock holder.
+var instanceID = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), bson.NewObjectId().Hex())
+}()
+
+type Lock struct {
+	ID        string    `bson:"_id" json:"id"`
+	Holder    string    `bson:"holder" json:"holder"`
+	ExpiresAt time.Time `bson:"expires_at" json:"expiresAt"`
+}
+
+// AcquireLock takes or renews the named lease for ttl. It succeeds when the
+// lock is free, expired, or already held by this instance; an expired lease
+// left by a crashed holder is simply taken over.
+func (p *DB) AcquireLock(name string, ttl time.Duration) (bool, error) {
+	span := p.startSpan("AcquireLock")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return false, err
+	}
+
+	now := time.Now().UTC()
+	change := mgo.Change{
+		Update: bson.M{"$set": bson.M{
+			"holder":     instanceID,
+			"expires_at": now.Add(ttl),
+		}},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+	query := bson.M{
+		"_id": name,
+		"$or": []bson.M{
+			{"expires_at": bson.M{"$lt": now}},
+			{"holder": instanceID},
+		},
+	}
+
+	var lock Lock
+	_, err := db.C(LOCKS_COLLECTION).Find(query).Apply(change, &lock)
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return lock.Holder == instanceID, nil
+}
+
+func (p *DB) ReleaseLock(name string) error {
+	span := p.startSpan("ReleaseLock")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	err := db.C(LOCKS_COLLECTION).Remove(bson.M{"_id": name, "holder": instanceID})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// holdLock acquires the lease and keeps renewing it until the returned
+// release func is called.
+func holdLock(store *DB, name string, ttl time.Duration) (func(), error) {
+	ok, err := store.AcquireLock(name, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errLockHeld
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if ok, err := store.AcquireLock(name, ttl); err != nil || !ok {
+					log.Println("lock, failed to renew", name, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		if err := store.ReleaseLock(name); err != nil {
+			log.Println("lock, error on ReleaseLock", name, err)
+		}
+	}, nil
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
-	"encoding/xml"
-	"io/ioutil"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/labstack/echo"
 	"github.com/labstack/echo/middleware"
@@ -15,16 +22,62 @@ import (
 const SERVER = "localhost"
 const DBNAME = "currencydb"
 const COLLECTION = "rates"
+const STATE_COLLECTION = "state"
+const INGEST_RUNS_COLLECTION = "ingest_runs"
 
 type Item struct {
 	Currency string  `bson:"currency" json:"currency"`
 	Rate     float32 `bson:"rate" json:"rate"`
+	// Manual overrides only; see overrides.go. Original is the provider
+	// value the override replaced.
+	Source       string    `bson:"source,omitempty" json:"source,omitempty"`
+	Locked       bool      `bson:"locked,omitempty" json:"locked,omitempty"`
+	Original     float32   `bson:"original,omitempty" json:"original,omitempty"`
+	OverriddenAt time.Time `bson:"overridden_at,omitempty" json:"overriddenAt,omitempty"`
 }
 
 type Rate struct {
-	ID       bson.ObjectId `bson:"_id" json:"id"`
-	RateDate string        `bson:"rate_date" json:"rateDate"`
-	Rates    []*Item       `bson:"rates" json:"rates"`
+	ID        bson.ObjectId `bson:"_id" json:"id"`
+	RateDate  string        `bson:"rate_date" json:"rateDate"`
+	Rates     []*Item       `bson:"rates" json:"rates"`
+	DeletedAt *time.Time    `bson:"deleted_at,omitempty" json:"deletedAt,omitempty"`
+	// Documents written before these fields existed decode as zero times.
+	CreatedAt time.Time `bson:"created_at,omitempty" json:"createdAt,omitempty"`
+	UpdatedAt time.Time `bson:"updated_at,omitempty" json:"updatedAt,omitempty"`
+	// Source names where the rates came from: ecb, import or seed.
+	Source        string `bson:"source,omitempty" json:"source,omitempty"`
+	SchemaVersion int    `bson:"schema_version" json:"schemaVersion"`
+	// Timestamp is when an intraday point was observed. Only documents in
+	// intraday_rates carry it; daily documents count for their whole day.
+	Timestamp time.Time `bson:"ts,omitempty" json:"ts,omitempty"`
+	// Conflicts is set by Save: incoming values that locked overrides kept
+	// out. It is never stored.
+	Conflicts []*OverrideConflict `bson:"-" json:"-"`
+	// Annotations are appended by AddAnnotation. Update leaves the stored
+	// list alone when this is nil, so re-ingesting a date keeps them.
+	Annotations []*Annotation `bson:"annotations,omitempty" json:"annotations,omitempty"`
+}
+
+func (r *Rate) RateMap() map[string]float32 {
+	rates := map[string]float32{}
+	for _, item := range r.Rates {
+		rates[item.Currency] = item.Rate
+	}
+	return rates
+}
+
+// ModifiedAt is when the document last changed: UpdatedAt, else CreatedAt,
+// else the insert time in its ObjectId for documents older than both.
+func (r *Rate) ModifiedAt() time.Time {
+	switch {
+	case !r.UpdatedAt.IsZero():
+		return r.UpdatedAt
+	case !r.CreatedAt.IsZero():
+		return r.CreatedAt
+	case r.ID.Valid():
+		return r.ID.Time()
+	}
+	return time.Time{}
 }
 
 type AnalyzeRes struct {
@@ -32,11 +85,20 @@ type AnalyzeRes struct {
 	Max      float32 `bson:"max" json:"max"`
 	Min      float32 `bson:"min" json:"min"`
 	Avg      float32 `bson:"avg" json:"avg"`
+	Stddev   float64 `bson:"stddev" json:"stddev"`
+	Count    int     `bson:"count" json:"count"`
 }
 
 type DailyRate struct {
-	Base  string             `json:"base"`
-	Rates map[string]float32 `json:"rates"`
+	Base        string             `json:"base"`
+	Date        string             `json:"date,omitempty"`
+	NextUpdate  string             `json:"next_update,omitempty"`
+	Rates       map[string]float32 `json:"rates"`
+	Annotations []*Annotation      `json:"annotations,omitempty"`
+	// Timestamp is set for intraday points served by /rates/at.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+	// LastModified is sent as the Last-Modified header.
+	LastModified time.Time `json:"-"`
 }
 
 type RateAnalysisRes struct {
@@ -45,50 +107,242 @@ type RateAnalysisRes struct {
 }
 
 type AnalysisData struct {
-	Min float32 `json:"min"`
-	Max float32 `json:"max"`
-	Avg float32 `json:"avg"`
+	Min   float32 `json:"min"`
+	Max   float32 `json:"max"`
+	Avg   float32 `json:"avg"`
+	Count int     `json:"count"`
 }
 
-type DB struct{}
+type DB struct {
+	ctx    context.Context
+	source string
+}
 
 var db *mgo.Database
 var p = &DB{}
 
-func (p *DB) Connect() {
-	session, err := mgo.Dial(SERVER)
+// dbReady is set once db is assigned. serveFallback dials from a goroutine
+// while requests are already served, so anything that may run before then
+// checks dbReady instead of reading db.
+var dbReady atomic.Bool
+
+func (p *DB) WithContext(ctx context.Context) *DB {
+	return &DB{ctx: ctx, source: p.source}
+}
+
+func (p *DB) Open() error {
+	if err := p.Dial(); err != nil {
+		return err
+	}
+	return p.EnsureIndexes()
+}
+
+// Dial connects to config.MongoHost and checks the server answers, without
+// touching the database.
+func (p *DB) Dial() error {
+	session, err := mgo.Dial(config.MongoHost)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	if err := session.Ping(); err != nil {
+		session.Close()
+		return err
+	}
+	db = session.DB(config.DBName)
+	dbReady.Store(true)
+	return nil
+}
+
+// EnsureIndexes makes rate_date unique and expires idempotency keys.
+// Databases written before the unique index may hold duplicate dates; they
+// keep a plain index until deduped.
+func (p *DB) EnsureIndexes() error {
+	span := p.startSpan("EnsureIndexes")
+	defer span.End()
+
+	if config.ReadOnly {
+		return nil
+	}
+
+	unique := mgo.Index{Key: []string{"rate_date"}, Unique: true, Background: true}
+	if err := db.C(ARCHIVE_COLLECTION).EnsureIndex(unique); err != nil {
+		return err
+	}
+	intraday := mgo.Index{Key: []string{"rate_date", "ts"}, Unique: true, Background: true}
+	if err := db.C(INTRADAY_COLLECTION).EnsureIndex(intraday); err != nil {
+		return err
+	}
+	// ExpireAfter 0 would not make a TTL index in mgo.
+	expiry := mgo.Index{Key: []string{"expires_at"}, ExpireAfter: time.Second, Background: true}
+	if err := db.C(IDEMPOTENCY_COLLECTION).EnsureIndex(expiry); err != nil {
+		return err
+	}
+
+	c := db.C(config.Collection)
+	err := c.EnsureIndex(unique)
+	if qerr, ok := err.(*mgo.QueryError); ok && (qerr.Code == 85 || qerr.Code == 86) {
+		// The older non-unique index has the same key.
+		if err := c.DropIndex("rate_date"); err != nil {
+			return err
+		}
+		err = c.EnsureIndex(unique)
 	}
-	db = session.DB(DBNAME)
+	if mgo.IsDup(err) {
+		log.Println("EnsureIndexes, duplicate rate_date documents prevent the unique index; run `go run . dedupe` to merge them")
+		return c.EnsureIndex(mgo.Index{Key: []string{"rate_date"}, Background: true})
+	}
+	return err
+}
+
+// live restricts a rates query to documents that are not soft-deleted.
+// Every read of the rates collection goes through it.
+func live(query bson.M) bson.M {
+	if query == nil {
+		query = bson.M{}
+	}
+	query["deleted_at"] = bson.M{"$exists": false}
+	return query
 }
 
 func (p *DB) FindAll() ([]Rate, error) {
+	span := p.startSpan("FindAll")
+	defer span.End()
+
 	var rates []Rate
-	err := db.C(COLLECTION).Find(nil).All(&rates)
+	err := db.C(config.Collection).Find(live(nil)).All(&rates)
 	return rates, err
 }
 
 func (p *DB) FindById(id string) (Rate, error) {
+	span := p.startSpan("FindById")
+	defer span.End()
+
 	var rate Rate
-	err := db.C(COLLECTION).FindId(bson.ObjectIdHex(id)).One(&rate)
+	err := db.C(config.Collection).Find(live(bson.M{"_id": bson.ObjectIdHex(id)})).One(&rate)
 	return rate, err
 }
 
 func (p *DB) GetLatest() (Rate, error) {
+	span := p.startSpan("GetLatest")
+	defer span.End()
+
+	query := p.bySource(live(bson.M{"rates.0": bson.M{"$exists": true}}))
 	var rate Rate
-	err := db.C(COLLECTION).Find(nil).Sort("-rate_date").One(&rate)
+	err := db.C(config.Collection).Find(query).Sort("-rate_date").One(&rate)
 	return rate, err
 }
 
+func (p *DB) FindRecent(n int) ([]Rate, error) {
+	span := p.startSpan("FindRecent")
+	defer span.End()
+
+	var rates []Rate
+	err := db.C(config.Collection).Find(p.bySource(live(nil))).Sort("-rate_date").Limit(n).All(&rates)
+	return rates, err
+}
+
 func (p *DB) FindByDate(date string) (*Rate, error) {
+	span := p.startSpan("FindByDate")
+	defer span.End()
+
+	raws := []bson.Raw{}
+	err := db.C(config.Collection).Find(p.bySource(live(bson.M{"rate_date": date}))).All(&raws)
+	if err != nil {
+		return &Rate{}, err
+	}
+	rates, err := decodeRates(date, raws)
+	if err != nil {
+		return &Rate{}, err
+	}
+	if len(rates) == 0 && p.crossesArchive(date) {
+		if rates, err = p.findArchived(p.bySource(bson.M{"rate_date": date})); err != nil {
+			return &Rate{}, err
+		}
+	}
+	if len(rates) == 0 {
+		return &Rate{}, mgo.ErrNotFound
+	}
+	return &rates[0], nil
+}
+
+// DecodeError is a stored document that no longer decodes into a Rate.
+type DecodeError struct {
+	Date string
+	Err  error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("document for %s is corrupt: %v", e.Date, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// decodeRates decodes the documents of date one by one so a corrupt one is
+// reported as a DecodeError rather than a bare bson error.
+func decodeRates(date string, raws []bson.Raw) ([]Rate, error) {
+	rates := make([]Rate, len(raws))
+	for i := range raws {
+		if err := raws[i].Unmarshal(&rates[i]); err != nil {
+			return nil, &DecodeError{Date: date, Err: err}
+		}
+	}
+	return rates, nil
+}
+
+// findByDateAny is FindByDate including soft-deleted documents.
+func (p *DB) findByDateAny(date string) (*Rate, error) {
+	span := p.startSpan("findByDateAny")
+	defer span.End()
+
 	var rate Rate
-	err := db.C(COLLECTION).Find(bson.M{"rate_date": date}).One(&rate)
+	err := db.C(config.Collection).Find(bson.M{"rate_date": date}).One(&rate)
 	return &rate, err
 }
 
 func (p *DB) Analyze() ([]*AnalyzeRes, error) {
-	pipe := db.C(COLLECTION).Pipe([]bson.M{
+	return p.AnalyzeRange("", "")
+}
+
+var errResultLimit = errors.New("aggregation result exceeds max_aggregation_results")
+
+// AnalyzeRange computes the per-currency statistics over the documents
+// between start and end inclusive. Empty bounds leave that side open.
+// The pipeline stops after config.MaxAggregationResults+1 groups; hitting
+// the limit returns errResultLimit instead of a partial result. Windows that
+// reach the archive are aggregated in Go over FindRange, which merges both
+// collections.
+func (p *DB) AnalyzeRange(start, end string) ([]*AnalyzeRes, error) {
+	span := p.startSpan("AnalyzeRange")
+	defer span.End()
+
+	if p.crossesArchive(start) {
+		rates, err := p.FindRange(start, end)
+		if err != nil {
+			return nil, err
+		}
+		res := analyzeRates(rates)
+		if len(res) > config.MaxAggregationResults {
+			return nil, errResultLimit
+		}
+		return res, nil
+	}
+
+	match := bson.M{}
+	if start != "" {
+		match["$gte"] = start
+	}
+	if end != "" {
+		match["$lte"] = end
+	}
+	filter := live(nil)
+	if len(match) > 0 {
+		filter["rate_date"] = match
+	}
+	pipeline := []bson.M{{"$match": filter}}
+
+	pipe := db.C(config.Collection).Pipe(append(pipeline, []bson.M{
 		{"$unwind": "$rates"},
 		{"$project": bson.M{
 			"_id":       1,
@@ -97,184 +351,445 @@ func (p *DB) Analyze() ([]*AnalyzeRes, error) {
 			"rate":      "$rates.rate",
 		}},
 		{"$group": bson.M{
-			"_id": "$currency",
-			"max": bson.M{"$max": "$rate"},
-			"min": bson.M{"$min": "$rate"},
-			"sum": bson.M{"$sum": "$rate"},
-			"avg": bson.M{"$avg": "$rate"},
+			"_id":    "$currency",
+			"max":    bson.M{"$max": "$rate"},
+			"min":    bson.M{"$min": "$rate"},
+			"sum":    bson.M{"$sum": "$rate"},
+			"avg":    bson.M{"$avg": "$rate"},
+			"stddev": bson.M{"$stdDevSamp": "$rate"},
+			"count":  bson.M{"$sum": 1},
 		}},
 		{
 			"$sort": bson.M{"_id": 1},
 		},
-	})
+		{"$limit": config.MaxAggregationResults + 1},
+	}...))
 	res := []*AnalyzeRes{}
 	err := pipe.All(&res)
 	if err != nil {
 		return nil, err
 	}
+	if len(res) > config.MaxAggregationResults {
+		return nil, errResultLimit
+	}
 	return res, nil
 }
 
-func (p *DB) Save(rate *Rate) error {
-	oldRate, err := p.FindByDate(rate.RateDate)
+const (
+	SaveInserted  = "inserted"
+	SaveUpdated   = "updated"
+	SaveUnchanged = "unchanged"
+	// SaveDeleted means the date was soft-deleted and is left alone.
+	SaveDeleted = "deleted"
+	// SaveOutranked means the stored document comes from a source of higher
+	// SOURCE_PRECEDENCE and is kept.
+	SaveOutranked = "outranked"
+)
+
+// Save inserts or updates the document for rate.RateDate and reports which
+// of the two happened. Identical documents are left untouched, a document
+// from a source of higher precedence is never replaced, and neither are
+// locked overrides of the stored document.
+func (p *DB) Save(rate *Rate) (string, error) {
+	span := p.startSpan("Save")
+	defer span.End()
+
+	oldRate, err := p.findByDateAny(rate.RateDate)
 	if err != nil || oldRate == nil {
 		rate.ID = bson.NewObjectId()
-		err = p.Insert(rate)
-	} else {
-		rate.ID = oldRate.ID
-		err = p.Update(rate)
+		return SaveInserted, p.Insert(rate)
+	}
+	if oldRate.DeletedAt != nil {
+		return SaveDeleted, nil
+	}
+	if rate.Source != "" && !sourceWins(rate.Source, oldRate.Source, config.SourcePrecedence) {
+		return SaveOutranked, nil
 	}
-	return err
-}
 
-func (p *DB) Insert(rate *Rate) error {
-	err := db.C(COLLECTION).Insert(rate)
-	return err
+	rate.ID = oldRate.ID
+	rate.CreatedAt = oldRate.CreatedAt
+	rate.Conflicts = keepOverrides(oldRate, rate)
+	if rate.Source == "" {
+		rate.Source = oldRate.Source
+	}
+	if sameRates(oldRate, rate) {
+		return SaveUnchanged, nil
+	}
+	return SaveUpdated, p.Update(rate)
 }
 
-func (p *DB) Update(rate *Rate) error {
-	err := db.C(COLLECTION).UpdateId(rate.ID, rate)
-	return err
+func sameRates(a, b *Rate) bool {
+	if len(a.Rates) != len(b.Rates) {
+		return false
+	}
+	am := a.RateMap()
+	for _, item := range b.Rates {
+		if v, ok := am[item.Currency]; !ok || v != item.Rate {
+			return false
+		}
+	}
+	return true
 }
 
-func initServer() {
-	client := http.Client{}
+// Insert stamps CreatedAt and UpdatedAt unless they are already set and
+// writes the document at SCHEMA_VERSION.
+func (p *DB) Insert(rate *Rate) error {
+	span := p.startSpan("Insert")
+	defer span.End()
 
-	req, err := http.NewRequest("GET", "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist-90d.xml", nil)
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
 
-	if err != nil {
-		log.Fatal(err)
+	now := time.Now().UTC()
+	if rate.CreatedAt.IsZero() {
+		rate.CreatedAt = now
+	}
+	if rate.UpdatedAt.IsZero() {
+		rate.UpdatedAt = now
 	}
+	upgrade(rate)
+	err := db.C(config.Collection).Insert(rate)
+	return err
+}
 
-	resp, err := client.Do(req)
+// Update stamps UpdatedAt and $sets the document's fields so a refresh of
+// the date keeps its annotations. A document that predates CreatedAt takes
+// it from its ObjectId, which records when it was inserted.
+func (p *DB) Update(rate *Rate) error {
+	span := p.startSpan("Update")
+	defer span.End()
 
-	if err != nil {
-		log.Fatal(err)
+	if err := p.checkWritable(); err != nil {
+		return err
 	}
 
-	respBody, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatal(err)
+	if rate.CreatedAt.IsZero() {
+		rate.CreatedAt = rate.ID.Time().UTC()
 	}
-
-	type Cube struct {
-		Currency string  `xml:"currency,attr"`
-		Rate     float32 `xml:"rate,attr"`
+	rate.UpdatedAt = time.Now().UTC()
+	upgrade(rate)
+	set := bson.M{
+		"rate_date":      rate.RateDate,
+		"rates":          rate.Rates,
+		"created_at":     rate.CreatedAt,
+		"updated_at":     rate.UpdatedAt,
+		"source":         rate.Source,
+		"schema_version": rate.SchemaVersion,
+	}
+	if rate.Annotations != nil {
+		set["annotations"] = rate.Annotations
 	}
+	err := db.C(config.Collection).UpdateId(rate.ID, bson.M{"$set": set})
+	if err == mgo.ErrNotFound {
+		// Archived documents keep their _id; see MoveToArchive.
+		err = db.C(ARCHIVE_COLLECTION).UpdateId(rate.ID, bson.M{"$set": set})
+	}
+	return err
+}
 
-	type CubeDate struct {
-		Time  string  `xml:"time,attr"`
-		Cubes []*Cube `xml:"Cube"`
+func buildLatest(store *DB) (*DailyRate, error) {
+	r, err := store.GetLatest()
+	if err != nil {
+		return nil, err
 	}
+	return latestFrom(&r), nil
+}
 
-	type Response struct {
-		CubeDates []*CubeDate `xml:"Cube>Cube"`
+func latestFrom(r *Rate) *DailyRate {
+	res := &DailyRate{
+		Base:         "EUR",
+		Date:         r.RateDate,
+		Rates:        r.RateMap(),
+		LastModified: r.ModifiedAt(),
+	}
+	if next, err := nextECBUpdate(r.RateDate, config.Location()); err == nil {
+		res.NextUpdate = next.Format(time.RFC3339)
 	}
+	return res
+}
 
-	var response Response
-	err = xml.Unmarshal(respBody, &response)
+func buildAnalysis(store *DB) (*RateAnalysisRes, error) {
+	analyze, err := store.Analyze()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	res := &RateAnalysisRes{
+		Base:  "EUR",
+		Rates: map[string]*AnalysisData{},
 	}
 
-	for _, cube := range response.CubeDates {
-		items := []*Item{}
-		for _, c := range cube.Cubes {
-			items = append(items, &Item{
-				Currency: c.Currency,
-				Rate:     c.Rate,
-			})
+	for _, rate := range analyze {
+		data := &AnalysisData{
+			Min:   rate.Min,
+			Max:   rate.Max,
+			Avg:   rate.Avg,
+			Count: rate.Count,
 		}
+		res.Rates[rate.Currency] = data
+	}
+	return res, nil
+}
 
-		rate := &Rate{
-			RateDate: cube.Time,
-			Rates:    items,
-		}
+// LatestRes adds the refresh status to the cached latest rates so clients
+// can tell when they are served old data during a provider outage.
+type LatestRes struct {
+	*DailyRate
+	Stale                 bool       `json:"stale"`
+	LastSuccessfulRefresh *time.Time `json:"last_successful_refresh,omitempty"`
+}
 
-		if err := p.Save(rate); err != nil {
-			log.Fatal(err)
-		}
+// cachedLatest serves the latest rates from the cache, filling it on a
+// miss. Requests for a single source bypass it.
+func cachedLatest(store *DB) (*DailyRate, error) {
+	if res := cache.Latest(); res != nil && store.source == "" {
+		return res, nil
+	}
+	res, err := buildLatest(store)
+	if err != nil {
+		return nil, err
+	}
+	if store.source == "" {
+		cache.SetLatest(res)
 	}
+	return res, nil
 }
 
 func getLatest(c echo.Context) error {
-	r, err := p.GetLatest()
+	res, err := cachedLatest(storeFor(c))
 	if err != nil {
 		log.Println("LatestRateEndPoint, error on GetLatest", err)
 		return c.JSON(http.StatusBadRequest, nil)
 	}
 
-	rates := map[string]float32{}
-	for _, item := range r.Rates {
-		rates[item.Currency] = item.Rate
+	if res.NextUpdate != "" {
+		c.Response().Header().Set("X-Next-Update", res.NextUpdate)
 	}
-
-	res := &DailyRate{
-		Base:  "EUR",
-		Rates: rates,
+	setLastModified(c, res.LastModified)
+	status := refreshStatus.Get()
+	out := &LatestRes{DailyRate: res, Stale: status.Failing()}
+	if !status.LastSuccess.IsZero() {
+		out.LastSuccessfulRefresh = &status.LastSuccess
 	}
-
-	return c.JSON(http.StatusOK, res)
+	return jsonFields(c, http.StatusOK, out)
 }
 
 func getAnalyze(c echo.Context) error {
-	analyze, err := p.Analyze()
-	if err != nil {
-		return c.JSON(http.StatusBadRequest, err.Error())
-	}
-	res := &RateAnalysisRes{
-		Base:  "EUR",
-		Rates: map[string]*AnalysisData{},
+	if res := cache.Analysis(); res != nil {
+		return c.JSON(http.StatusOK, res)
 	}
 
-	for _, rate := range analyze {
-		data := &AnalysisData{
-			Min: rate.Min,
-			Max: rate.Max,
-			Avg: rate.Avg,
-		}
-		res.Rates[rate.Currency] = data
+	res, err := buildAnalysis(storeFor(c))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
 	}
+	cache.SetAnalysis(res)
 
 	return c.JSON(http.StatusOK, res)
 }
 
+// getDateRate serves one day for YYYY-MM-DD and every day of the period for
+// YYYY or YYYY-MM.
 func getDateRate(c echo.Context) error {
 	date := c.Param("date")
-	rate, err := p.FindByDate(date)
+	if r, ok := periodRange(date); ok {
+		return getPeriodRates(c, r)
+	}
+	rate, err := storeFor(c).FindByDate(date)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, err.Error())
 	}
 
-	rates := map[string]float32{}
-	for _, item := range rate.Rates {
-		rates[item.Currency] = item.Rate
-	}
-
 	res := &DailyRate{
 		Base:  "EUR",
-		Rates: rates,
+		Date:  rate.RateDate,
+		Rates: rate.RateMap(),
+	}
+	setLastModified(c, rate.ModifiedAt())
+	if include, _ := strconv.ParseBool(c.QueryParam("includeAnnotations")); include {
+		res.Annotations = rate.Annotations
 	}
 
-	return c.JSON(http.StatusOK, res)
+	return jsonFields(c, http.StatusOK, res)
 }
 
 func main() {
-	p.Connect()
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	flags, err := parseFlags(args)
+	if err != nil {
+		os.Exit(2)
+	}
+	cfg, errs := loadConfig(flags)
+	if len(errs) > 0 {
+		for _, err := range errs {
+			log.Println("config:", err)
+		}
+		os.Exit(1)
+	}
+	config = cfg
+	if ecbClient, err = newECBClient(config); err != nil {
+		log.Fatal(err)
+	}
+	if flags.PrintConfig {
+		if err := printConfig(config); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	initServer()
+	switch command {
+	case "serve":
+	case "check":
+		os.Exit(runChecks(os.Stdout))
+	case "dedupe":
+		os.Exit(runDedupe(os.Stdout, flags.DryRun))
+	case "purge":
+		os.Exit(runPurge(os.Stdout, daysOr(flags.Days, 30)))
+	case "seed":
+		os.Exit(runSeed(os.Stdout, flags))
+	case "backfill":
+		os.Exit(runBackfill(os.Stdout, flags))
+	case "migrate":
+		os.Exit(runMigrate(os.Stdout))
+	default:
+		log.Fatalf("unknown command %q", command)
+	}
+
+	ecbBreaker = NewCircuitBreaker("ecb", config.BreakerFailures, config.BreakerCooldown)
+	usage.Start()
+
+	shutdownTracing := initTracing()
+	defer shutdownTracing(context.Background())
+
+	fallback := loadCacheFile(config.CacheFile)
+	if err := p.Open(); err != nil && (fallback == nil || flags.DryRun) {
+		log.Fatal(err)
+	} else if err != nil {
+		serveFallback(fallback, err)
+	} else if !flags.DryRun {
+		startIngest(fallback)
+	}
+
+	if flags.DryRun {
+		report, err := DryRunRefresh(context.Background(), 0)
+		if err != nil {
+			log.Fatal(err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	e := echo.New()
+	e.HTTPErrorHandler = prettyErrors(e.DefaultHTTPErrorHandler)
 
 	// Middleware
-	e.Use(middleware.Logger())
+	e.Use(tracingMiddleware())
+	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{Format: logFormat}))
 	e.Use(middleware.Recover())
+	e.Use(prettyJSON())
+	e.Use(allowedMethods(e))
+	e.Use(requireStore())
+	e.Use(quota())
+	e.Use(idempotent())
 
 	// Routes
-	e.GET("/rates/latest", getLatest)
-	e.GET("/rates/analyze", getAnalyze)
-	e.GET("/rates/:date", getDateRate)
+	e.GET("/healthz", getHealthz)
+	e.GET("/healthz/freshness", getFreshness)
+	e.GET("/account/usage", getAccountUsage)
+	// HEAD runs the GET handler; net/http drops the body but keeps the
+	// headers, Content-Length included.
+	e.GET("/rates/latest", getLatest, sourceParam(), feature("latest"))
+	e.HEAD("/rates/latest", getLatest, sourceParam(), feature("latest"))
+	e.GET("/rates/latest/sorted", getLatestSorted, sourceParam(), feature("latest"))
+	e.GET("/rates/summary", getSummary, feature("summary"))
+	e.GET("/rates/analyze", getAnalyze, feature("analyze"))
+	e.GET("/rates/export", getExport, adminAuth(), feature("export"))
+	e.GET("/rates/common-latest", getCommonLatest, feature("common-latest"))
+	e.GET("/rates/beta", getBeta, feature("beta"))
+	e.GET("/rates/dispersion", getDispersion, feature("dispersion"))
+	e.GET("/rates/rank", getRank, feature("rank"))
+	e.GET("/rates/zscore", getZScore, feature("zscore"))
+	e.GET("/rates/percentile-rank", getPercentileRank, feature("percentile-rank"))
+	e.GET("/rates/half-life", getHalfLife, feature("half-life"))
+	e.GET("/rates/sharpe", getSharpe, feature("sharpe"))
+	e.GET("/rates/adr", getADR, feature("adr"))
+	e.GET("/rates/ema", getEMA, feature("ema"))
+	e.GET("/rates/index/:currency", getIndex, feature("index"))
+	e.GET("/rates/pegs", getPegs, feature("pegs"))
+	e.GET("/rates/rolling-correlation", getRollingCorrelation, feature("rolling-correlation"))
+	e.GET("/rates/range", getRange, sourceParam(), feature("range"))
+	e.GET("/rates/recent", getRecent, sourceParam(), feature("recent"))
+	e.GET("/rates/weekly", getWeekly, sourceParam(), feature("weekly"))
+	e.GET("/rates/at", getRatesAt, sourceParam(), feature("at"))
+	e.GET("/rates/series/:currency", getSeries, sourceParam(), feature("series"))
+	e.GET("/rates/sse", getSSE, feature("sse"))
+	e.GET("/rates/derived/:currency", getDerivedRate, feature("derived"))
+	e.GET("/rates/pair/:from/:to/history", getPairHistory, sourceParam(), feature("pair-history"))
+	e.GET("/rates/pair/:from/:to/analyze", getPairAnalyze, feature("pair-analyze"))
+	e.GET("/rates/dates", getDates, feature("dates"))
+	e.GET("/rates/coverage-changes", getCoverageChanges, feature("coverage-changes"))
+	e.GET("/rates/gaps", getGaps, feature("gaps"))
+	e.POST("/rates/backfill", postBackfill, adminAuth(), writes(), feature("backfill"))
+	e.GET("/rates/refresh-log", getIngestions, adminAuth())
+	e.GET("/rates/duplicates", getDuplicates, adminAuth())
+	e.POST("/rates/dedup", postAdminDedupe, adminAuth(), writes())
+	e.POST("/rates/import", postImport, adminAuth(), writes(), feature("import"))
+	e.POST("/rates/import/validate", postImportValidate, feature("import"))
+	e.POST("/rates/aggregate", postAggregate, adminAuth(), feature("aggregate"))
+	e.GET("/convert", getConvert, feature("convert"))
+	e.POST("/convert/batch", postConvertBatch, feature("convert-batch"))
+	e.GET("/convert/average", getConvertAverage, feature("convert-average"))
+	e.POST("/convert/csv", postConvertCSV, feature("convert-csv"))
+	e.GET("/convert/best", getConvertBest, feature("convert-best"))
+	e.GET("/convert/all", getConvertAll, feature("convert-all"))
+	e.POST("/convert/total", postConvertTotal, feature("convert-total"))
+	e.POST("/baskets/value", postBasketValue, feature("baskets"))
+	e.POST("/rates/tracking-error", postTrackingError, feature("tracking-error"))
+	e.POST("/rates/ic", postIC, feature("ic"))
+	e.POST("/rates/baseline", postBaseline, adminAuth(), writes(), feature("baseline"))
+	e.GET("/rates/baseline/:name/diff", getBaselineDiff, feature("baseline"))
+	e.DELETE("/rates/:date", deleteRate, adminAuth(), writes())
+	e.PUT("/rates/:date", putDateRate, adminAuth(), writes())
+	e.PUT("/rates/:date/annotation", putAnnotation, adminAuth(), writes())
+	e.PUT("/rates/:date/:currency/override", putOverride, adminAuth(), writes())
+	e.DELETE("/rates/:date/:currency/override", deleteOverride, adminAuth(), writes())
+	e.GET("/rates/:date", getDateRate, sourceParam(), feature("date"))
+	e.HEAD("/rates/:date", getDateRate, sourceParam(), feature("date"))
+	e.POST("/rates/alerts/check", postAlertsCheck, feature("alerts"))
+
+	e.GET("/debug/config", getDebugConfig, adminAuth())
+
+	admin := e.Group("/admin", adminAuth())
+	admin.POST("/refresh", postAdminRefresh, writes())
+	admin.POST("/refresh/:date", postAdminRefreshDate, writes())
+	admin.POST("/backfill", postAdminBackfill, writes())
+	admin.POST("/dedupe", postAdminDedupe, writes())
+	admin.GET("/archive", getArchive)
+	admin.POST("/archive", postArchive, writes())
+	admin.POST("/verify", postAdminVerify)
+	admin.GET("/ingestions", getIngestions)
+	admin.GET("/ingestions/queue", getIngestQueue)
+	admin.GET("/ingestions/:id", getIngestion)
+	admin.GET("/completeness", getCompleteness)
+	admin.GET("/rates/:date", getAdminDateRate)
+	admin.GET("/deleted", getDeleted)
+	admin.POST("/restore/:date", postRestore, writes())
+	admin.GET("/pending-review", getPendingReview)
+	admin.POST("/pending-review/:id/approve", postReviewDecision(ReviewApproved), writes())
+	admin.POST("/pending-review/:id/reject", postReviewDecision(ReviewRejected), writes())
+	admin.GET("/features", getFeatures)
+	admin.PUT("/features/:name", putFeature, writes())
+
+	registerPprof(e)
 
 	// Start server
-	e.Logger.Fatal(e.Start(":3000"))
+	e.Logger.Fatal(e.Start(":" + config.Port))
 }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo"
+)
+
+// routeMethods is a registered path pattern and the methods it serves.
+type routeMethods struct {
+	segments []string
+	methods  map[string]bool
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// matchRoute reports whether pattern matches path and, if so, how: per
+// segment 2 for static, 1 for :param and 0 for *, which takes the rest.
+func matchRoute(pattern, path []string) ([]int, bool) {
+	score := []int{}
+	for i, seg := range pattern {
+		switch {
+		case seg == "*":
+			return append(score, 0), true
+		case i >= len(path):
+			return nil, false
+		case strings.HasPrefix(seg, ":"):
+			score = append(score, 1)
+		case seg == path[i]:
+			score = append(score, 2)
+		default:
+			return nil, false
+		}
+	}
+	return score, len(pattern) == len(path)
+}
+
+// moreSpecific orders scores the way echo's router prefers routes: static
+// over :param over * at the first segment where they differ.
+func moreSpecific(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return len(a) > len(b)
+}
+
+// allowedMethods answers requests echo would route to the wrong handler or
+// a bare 404: a method the path doesn't serve gets a 405, and OPTIONS a 204,
+// both with an Allow header. The route table is read on the first request,
+// once every route is registered.
+func allowedMethods(e *echo.Echo) echo.MiddlewareFunc {
+	var once sync.Once
+	var routes []*routeMethods
+	load := func() {
+		byPath := map[string]*routeMethods{}
+		for _, r := range e.Routes() {
+			rm, ok := byPath[r.Path]
+			if !ok {
+				rm = &routeMethods{segments: splitPath(r.Path), methods: map[string]bool{}}
+				byPath[r.Path] = rm
+				routes = append(routes, rm)
+			}
+			rm.methods[r.Method] = true
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			once.Do(load)
+
+			path := splitPath(c.Request().URL.Path)
+			var best *routeMethods
+			var bestScore []int
+			for _, rm := range routes {
+				if score, ok := matchRoute(rm.segments, path); ok && (best == nil || moreSpecific(score, bestScore)) {
+					best, bestScore = rm, score
+				}
+			}
+			if best == nil {
+				return next(c)
+			}
+
+			method := c.Request().Method
+			if best.methods[method] {
+				return next(c)
+			}
+			allow := []string{http.MethodOptions}
+			for m := range best.methods {
+				allow = append(allow, m)
+			}
+			sort.Strings(allow)
+			c.Response().Header().Set("Allow", strings.Join(allow, ", "))
+			if method == http.MethodOptions {
+				return c.NoContent(http.StatusNoContent)
+			}
+			return c.JSON(http.StatusMethodNotAllowed, "method "+method+" not allowed, use "+strings.Join(allow, ", "))
+		}
+	}
+}
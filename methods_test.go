@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func TestAllowedMethods(t *testing.T) {
+	e := echo.New()
+	e.Use(allowedMethods(e))
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	// The same shapes main registers: static paths next to :param siblings,
+	// several methods on one path and nested params.
+	e.GET("/rates/latest", ok)
+	e.GET("/rates/latest/sorted", ok)
+	e.POST("/rates/import", ok)
+	e.GET("/rates/:date", ok)
+	e.HEAD("/rates/:date", ok)
+	e.PUT("/rates/:date", ok)
+	e.DELETE("/rates/:date", ok)
+	e.PUT("/rates/:date/annotation", ok)
+	e.PUT("/rates/:date/:currency/override", ok)
+	e.DELETE("/rates/:date/:currency/override", ok)
+	e.GET("/rates/pair/:from/:to/history", ok)
+
+	allowed := map[string]map[string]bool{}
+	for _, r := range e.Routes() {
+		if allowed[r.Path] == nil {
+			allowed[r.Path] = map[string]bool{}
+		}
+		allowed[r.Path][r.Method] = true
+	}
+
+	verbs := []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions,
+	}
+	for path, methods := range allowed {
+		target := path
+		for _, seg := range splitPath(path) {
+			if strings.HasPrefix(seg, ":") {
+				target = strings.Replace(target, seg, "2024-01-31", 1)
+			}
+		}
+		for _, verb := range verbs {
+			want := http.StatusMethodNotAllowed
+			switch {
+			case methods[verb]:
+				want = http.StatusOK
+			case verb == http.MethodOptions:
+				want = http.StatusNoContent
+			}
+
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, httptest.NewRequest(verb, target, nil))
+			if rec.Code != want {
+				t.Errorf("%s %s: status %d, want %d", verb, target, rec.Code, want)
+			}
+			allow := rec.Header().Get("Allow")
+			if want == http.StatusOK {
+				if allow != "" {
+					t.Errorf("%s %s: unexpected Allow %q", verb, target, allow)
+				}
+				continue
+			}
+			for m := range methods {
+				if !strings.Contains(allow, m) {
+					t.Errorf("%s %s: Allow %q is missing %s", verb, target, allow, m)
+				}
+			}
+		}
+	}
+}
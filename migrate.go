@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MIGRATE_BATCH is how many documents migrate reads per query.
+const MIGRATE_BATCH = 500
+
+type migration struct {
+	Version int
+	Name    string
+	Apply   func(rate *Rate)
+}
+
+// migrations upgrade a Rate document one schema version at a time, in order.
+// Each step must be idempotent since a document can be written by a newer
+// Insert or Update while a migrate run is still walking the collection.
+var migrations = []migration{
+	{1, "add source", func(rate *Rate) {
+		if rate.Source == "" {
+			rate.Source = "ecb"
+		}
+	}},
+	{2, "stamp timestamps", func(rate *Rate) {
+		if rate.CreatedAt.IsZero() {
+			rate.CreatedAt = rate.ID.Time().UTC()
+		}
+		if rate.UpdatedAt.IsZero() {
+			rate.UpdatedAt = rate.CreatedAt
+		}
+	}},
+}
+
+// SCHEMA_VERSION is the document version this build writes.
+var SCHEMA_VERSION = migrations[len(migrations)-1].Version
+
+// upgrade applies the migrations newer than rate.SchemaVersion and returns
+// whether anything was pending.
+func upgrade(rate *Rate) bool {
+	from := rate.SchemaVersion
+	for _, m := range migrations {
+		if m.Version > rate.SchemaVersion {
+			m.Apply(rate)
+			rate.SchemaVersion = m.Version
+		}
+	}
+	return rate.SchemaVersion != from
+}
+
+func olderThan(version int) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"schema_version": bson.M{"$exists": false}},
+		{"schema_version": bson.M{"$lt": version}},
+	}}
+}
+
+// MigrateState is the progress of the last migrate run, kept in the state
+// collection so an interrupted run continues after LastID.
+type MigrateState struct {
+	ID        string        `bson:"_id" json:"id"`
+	Version   int           `bson:"version" json:"version"`
+	LastID    bson.ObjectId `bson:"last_id,omitempty" json:"lastId,omitempty"`
+	Migrated  int           `bson:"migrated" json:"migrated"`
+	Done      bool          `bson:"done" json:"done"`
+	UpdatedAt time.Time     `bson:"updated_at" json:"updatedAt"`
+}
+
+func (p *DB) GetMigrateState() (*MigrateState, error) {
+	span := p.startSpan("GetMigrateState")
+	defer span.End()
+
+	var state MigrateState
+	err := db.C(STATE_COLLECTION).FindId("migrate").One(&state)
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func (p *DB) SaveMigrateState(state *MigrateState) error {
+	span := p.startSpan("SaveMigrateState")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	state.UpdatedAt = time.Now().UTC()
+	_, err := db.C(STATE_COLLECTION).UpsertId(state.ID, state)
+	return err
+}
+
+// FindOutdated returns up to limit documents, soft-deleted ones included,
+// below version with an id after after, in id order.
+func (p *DB) FindOutdated(version int, after bson.ObjectId, limit int) ([]Rate, error) {
+	span := p.startSpan("FindOutdated")
+	defer span.End()
+
+	query := olderThan(version)
+	if after != "" {
+		query["_id"] = bson.M{"$gt": after}
+	}
+	rates := []Rate{}
+	err := db.C(config.Collection).Find(query).Sort("_id").Limit(limit).All(&rates)
+	return rates, err
+}
+
+// ReplaceVersion writes rate over the stored document only while that still
+// is below version from+1, so a document another writer already upgraded
+// is left alone. It reports whether the document was replaced.
+func (p *DB) ReplaceVersion(rate *Rate, from int) (bool, error) {
+	span := p.startSpan("ReplaceVersion")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return false, err
+	}
+
+	selector := olderThan(from + 1)
+	selector["_id"] = rate.ID
+	err := db.C(config.Collection).Update(selector, rate)
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CountNewer counts documents written by a build with a newer schema.
+func (p *DB) CountNewer(version int) (int, error) {
+	span := p.startSpan("CountNewer")
+	defer span.End()
+
+	return db.C(config.Collection).Find(bson.M{"schema_version": bson.M{"$gt": version}}).Count()
+}
+
+// migrate upgrades every document below SCHEMA_VERSION in batches of
+// MIGRATE_BATCH, saving its position after each batch.
+func migrate(store *DB, w io.Writer) (*MigrateState, error) {
+	state, err := store.GetMigrateState()
+	if err != nil {
+		return nil, err
+	}
+	if state == nil || state.Done || state.Version != SCHEMA_VERSION {
+		state = &MigrateState{ID: "migrate", Version: SCHEMA_VERSION}
+	} else {
+		fmt.Fprintf(w, "resuming after %s, %d documents migrated so far\n", state.LastID.Hex(), state.Migrated)
+	}
+
+	for {
+		rates, err := store.FindOutdated(SCHEMA_VERSION, state.LastID, MIGRATE_BATCH)
+		if err != nil {
+			return state, err
+		}
+		if len(rates) == 0 {
+			break
+		}
+		for i := range rates {
+			rate := &rates[i]
+			from := rate.SchemaVersion
+			upgrade(rate)
+			ok, err := store.ReplaceVersion(rate, from)
+			if err != nil {
+				return state, err
+			}
+			if ok {
+				state.Migrated++
+			}
+			state.LastID = rate.ID
+		}
+		if err := store.SaveMigrateState(state); err != nil {
+			return state, err
+		}
+		fmt.Fprintf(w, "%d documents migrated, through %s\n", state.Migrated, state.LastID.Hex())
+	}
+
+	state.Done = true
+	return state, store.SaveMigrateState(state)
+}
+
+func runMigrate(w io.Writer) int {
+	if err := p.Open(); err != nil {
+		fmt.Fprintln(w, "migrate:", err)
+		return 1
+	}
+	n, err := p.CountNewer(SCHEMA_VERSION)
+	if err != nil {
+		fmt.Fprintln(w, "migrate:", err)
+		return 1
+	}
+	if n > 0 {
+		fmt.Fprintf(w, "migrate: %d documents are newer than schema version %d\n", n, SCHEMA_VERSION)
+		return 1
+	}
+	state, err := migrate(p, w)
+	if err != nil {
+		fmt.Fprintln(w, "migrate:", err)
+		return 1
+	}
+	fmt.Fprintf(w, "schema version %d, %d documents migrated\n", state.Version, state.Migrated)
+	return 0
+}
+
+// checkSchema refuses to serve documents from a newer build unless
+// schema_check is "warn": an older build would silently drop their new
+// fields on the next Save.
+func checkSchema(store *DB) error {
+	n, err := store.CountNewer(SCHEMA_VERSION)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	err = fmt.Errorf("%d documents are newer than schema version %d", n, SCHEMA_VERSION)
+	if config.SchemaCheck == "warn" {
+		log.Println("WARNING:", err)
+		return nil
+	}
+	return err
+}
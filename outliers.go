@@ -0,0 +1,235 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const PENDING_REVIEW_COLLECTION = "pending_review"
+
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// Outlier is an incoming rate that moved more than config.OutlierMaxPct
+// from the previous stored date. Quarantined outliers are kept in
+// pending_review, keyed by date and currency, until an admin decides.
+type Outlier struct {
+	ID           string    `bson:"_id" json:"id"`
+	RateDate     string    `bson:"rate_date" json:"rateDate"`
+	Currency     string    `bson:"currency" json:"currency"`
+	Rate         float32   `bson:"rate" json:"rate"`
+	Previous     float32   `bson:"previous" json:"previous"`
+	PreviousDate string    `bson:"previous_date" json:"previousDate"`
+	ChangePct    float64   `bson:"change_pct" json:"changePct"`
+	Status       string    `bson:"status" json:"status"`
+	CreatedAt    time.Time `bson:"created_at" json:"createdAt"`
+	ReviewedAt   time.Time `bson:"reviewed_at,omitempty" json:"reviewedAt,omitempty"`
+}
+
+// findOutliers compares rate with prev. Currencies prev doesn't quote are
+// first observations and never flagged.
+func findOutliers(prev, rate *Rate, maxPct float64) []*Outlier {
+	outliers := []*Outlier{}
+	prevRates := prev.RateMap()
+	for _, item := range rate.Rates {
+		old, ok := prevRates[item.Currency]
+		if !ok {
+			continue
+		}
+		change := percentChange(old, item.Rate)
+		if math.Abs(change) <= maxPct {
+			continue
+		}
+		outliers = append(outliers, &Outlier{
+			ID:           rate.RateDate + ":" + item.Currency,
+			RateDate:     rate.RateDate,
+			Currency:     item.Currency,
+			Rate:         item.Rate,
+			Previous:     old,
+			PreviousDate: prev.RateDate,
+			ChangePct:    change,
+			Status:       ReviewPending,
+		})
+	}
+	return outliers
+}
+
+// flagOutliers returns the suspicious items of an incoming document. Values
+// already stored for the same date were accepted before, whether ingested or
+// approved, and are not flagged again.
+func flagOutliers(store *DB, rate *Rate) ([]*Outlier, *Rate, error) {
+	prev, err := store.FindBefore(rate.RateDate)
+	if err == mgo.ErrNotFound {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	current, err := store.FindByDate(rate.RateDate)
+	if err == mgo.ErrNotFound {
+		current, err = nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	flagged := []*Outlier{}
+	for _, o := range findOutliers(prev, rate, config.OutlierMaxPct) {
+		if current != nil {
+			if v, ok := current.RateMap()[o.Currency]; ok && v == o.Rate {
+				continue
+			}
+		}
+		flagged = append(flagged, o)
+	}
+	return flagged, current, nil
+}
+
+// quarantine moves the flagged items out of rate into pending_review. The
+// stored value of a quarantined currency, if any, is kept instead.
+func quarantine(store *DB, rate, current *Rate, flagged []*Outlier) error {
+	held := map[string]bool{}
+	for _, o := range flagged {
+		if err := store.UpsertOutlier(o); err != nil {
+			return err
+		}
+		held[o.Currency] = true
+	}
+	stored := map[string]float32{}
+	if current != nil {
+		stored = current.RateMap()
+	}
+	items := []*Item{}
+	for _, item := range rate.Rates {
+		if !held[item.Currency] {
+			items = append(items, item)
+		} else if v, ok := stored[item.Currency]; ok {
+			items = append(items, &Item{Currency: item.Currency, Rate: v})
+		}
+	}
+	rate.Rates = items
+	return nil
+}
+
+// UpsertOutlier records o unless it is already under review or decided.
+func (p *DB) UpsertOutlier(o *Outlier) error {
+	span := p.startSpan("UpsertOutlier")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	o.CreatedAt = time.Now().UTC()
+	_, err := db.C(PENDING_REVIEW_COLLECTION).UpsertId(o.ID, bson.M{"$setOnInsert": o})
+	return err
+}
+
+func (p *DB) FindOutliers(status string) ([]Outlier, error) {
+	span := p.startSpan("FindOutliers")
+	defer span.End()
+
+	query := bson.M{}
+	if status != "" {
+		query["status"] = status
+	}
+	outliers := []Outlier{}
+	err := db.C(PENDING_REVIEW_COLLECTION).Find(query).Sort("rate_date", "currency").All(&outliers)
+	return outliers, err
+}
+
+func (p *DB) FindOutlier(id string) (*Outlier, error) {
+	span := p.startSpan("FindOutlier")
+	defer span.End()
+
+	var o Outlier
+	err := db.C(PENDING_REVIEW_COLLECTION).FindId(id).One(&o)
+	return &o, err
+}
+
+func (p *DB) SetOutlierStatus(id, status string) error {
+	span := p.startSpan("SetOutlierStatus")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	return db.C(PENDING_REVIEW_COLLECTION).UpdateId(id, bson.M{"$set": bson.M{
+		"status":      status,
+		"reviewed_at": time.Now().UTC(),
+	}})
+}
+
+// approveOutlier writes the quarantined value into its date's document.
+func approveOutlier(store *DB, o *Outlier) error {
+	rate, err := store.FindByDate(o.RateDate)
+	if err == mgo.ErrNotFound {
+		rate, err = &Rate{RateDate: o.RateDate, Source: "ecb"}, nil
+	}
+	if err != nil {
+		return err
+	}
+	items := []*Item{}
+	for _, item := range rate.Rates {
+		if item.Currency != o.Currency {
+			items = append(items, item)
+		}
+	}
+	rate.Rates = append(items, &Item{Currency: o.Currency, Rate: o.Rate})
+	if _, err := store.Save(rate); err != nil {
+		return err
+	}
+	cache.Invalidate()
+	return store.SetOutlierStatus(o.ID, ReviewApproved)
+}
+
+func getPendingReview(c echo.Context) error {
+	status := c.QueryParam("status")
+	if status == "" {
+		status = ReviewPending
+	}
+	if status == "all" {
+		status = ""
+	}
+	outliers, err := storeFor(c).FindOutliers(status)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, outliers)
+}
+
+func postReviewDecision(decision string) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		store := storeFor(c)
+		o, err := store.FindOutlier(c.Param("id"))
+		if err == mgo.ErrNotFound {
+			return c.JSON(http.StatusNotFound, err.Error())
+		}
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, err.Error())
+		}
+		if o.Status != ReviewPending {
+			return c.JSON(http.StatusConflict, "outlier already "+o.Status)
+		}
+
+		if decision == ReviewApproved {
+			err = approveOutlier(store, o)
+		} else {
+			err = store.SetOutlierStatus(o.ID, ReviewRejected)
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+		o.Status = decision
+		return c.JSON(http.StatusOK, o)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+)
+
+const SourceManual = "manual"
+
+// OverrideConflict is an incoming value that ingestion did not write
+// because the currency holds a locked manual override.
+type OverrideConflict struct {
+	Date     string  `bson:"date" json:"date"`
+	Currency string  `bson:"currency" json:"currency"`
+	Override float32 `bson:"override" json:"override"`
+	Incoming float32 `bson:"incoming" json:"incoming"`
+}
+
+// keepOverrides carries the locked items of old over into rate, replacing
+// or adding to what rate brings, and returns the currencies whose incoming
+// value differed from the override. The incoming value is kept as the
+// item's Original so removing the override restores it.
+func keepOverrides(old, rate *Rate) []*OverrideConflict {
+	conflicts := []*OverrideConflict{}
+	for _, locked := range old.Rates {
+		if !locked.Locked {
+			continue
+		}
+		kept := *locked
+		replaced := false
+		for i, item := range rate.Rates {
+			if item.Currency != locked.Currency {
+				continue
+			}
+			if item.Rate != locked.Rate {
+				conflicts = append(conflicts, &OverrideConflict{
+					Date:     rate.RateDate,
+					Currency: item.Currency,
+					Override: locked.Rate,
+					Incoming: item.Rate,
+				})
+			}
+			kept.Original = item.Rate
+			rate.Rates[i] = &kept
+			replaced = true
+		}
+		if !replaced {
+			rate.Rates = append(rate.Rates, &kept)
+		}
+	}
+	return conflicts
+}
+
+// SetOverride locks currency on date to value. The value it replaces is
+// kept as Original unless an override already holds it.
+func SetOverride(store *DB, date, currency string, value float32) (*Rate, error) {
+	rate, err := store.FindByDate(date)
+	if err != nil {
+		return nil, err
+	}
+	override := &Item{Currency: currency, Rate: value, Source: SourceManual, Locked: true, OverriddenAt: time.Now().UTC()}
+	found := false
+	for i, item := range rate.Rates {
+		if item.Currency != currency {
+			continue
+		}
+		override.Original = item.Rate
+		if item.Locked {
+			override.Original = item.Original
+		}
+		rate.Rates[i] = override
+		found = true
+	}
+	if !found {
+		rate.Rates = append(rate.Rates, override)
+	}
+	return rate, store.Update(rate)
+}
+
+// RemoveOverride unlocks currency on date and puts back the provider value,
+// or drops the currency when the override added it.
+func RemoveOverride(store *DB, date, currency string) (*Rate, error) {
+	rate, err := store.FindByDate(date)
+	if err != nil {
+		return nil, err
+	}
+	items := []*Item{}
+	found := false
+	for _, item := range rate.Rates {
+		if item.Currency != currency || !item.Locked {
+			items = append(items, item)
+			continue
+		}
+		found = true
+		if item.Original != 0 {
+			items = append(items, &Item{Currency: currency, Rate: item.Original})
+		}
+	}
+	if !found {
+		return nil, mgo.ErrNotFound
+	}
+	rate.Rates = items
+	return rate, store.Update(rate)
+}
+
+func parseOverrideTarget(c echo.Context) (string, string, error) {
+	date := c.Param("date")
+	if _, err := time.Parse(DATE_LAYOUT, date); err != nil {
+		return "", "", errors.New("date must be YYYY-MM-DD")
+	}
+	currency := strings.ToUpper(c.Param("currency"))
+	if !currencyRe.MatchString(currency) {
+		return "", "", errors.New("invalid currency")
+	}
+	return date, currency, nil
+}
+
+type OverrideReq struct {
+	Rate float32 `json:"rate"`
+}
+
+func putOverride(c echo.Context) error {
+	date, currency, err := parseOverrideTarget(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	var req OverrideReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if req.Rate <= 0 {
+		return c.JSON(http.StatusBadRequest, "rate must be positive")
+	}
+
+	rate, err := SetOverride(storeFor(c), date, currency, req.Rate)
+	if err == mgo.ErrNotFound {
+		return c.JSON(http.StatusNotFound, "no rates for "+date)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	cache.Invalidate()
+	return c.JSON(http.StatusOK, rate)
+}
+
+func deleteOverride(c echo.Context) error {
+	date, currency, err := parseOverrideTarget(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	rate, err := RemoveOverride(storeFor(c), date, currency)
+	if err == mgo.ErrNotFound {
+		return c.JSON(http.StatusNotFound, "no override for "+currency+" on "+date)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	cache.Invalidate()
+	return c.JSON(http.StatusOK, rate)
+}
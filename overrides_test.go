@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestKeepOverrides(t *testing.T) {
+	tests := []struct {
+		name      string
+		old       []*Item
+		incoming  map[string]float32
+		want      map[string]float32
+		conflicts int
+	}{
+		{
+			"locked value wins",
+			[]*Item{{Currency: "USD", Rate: 1.5, Locked: true}},
+			map[string]float32{"USD": 1.1},
+			map[string]float32{"USD": 1.5}, 1,
+		},
+		{
+			"same value is no conflict",
+			[]*Item{{Currency: "USD", Rate: 1.1, Locked: true}},
+			map[string]float32{"USD": 1.1},
+			map[string]float32{"USD": 1.1}, 0,
+		},
+		{
+			"locked currency missing from incoming is kept",
+			[]*Item{{Currency: "USD", Rate: 1.5, Locked: true}},
+			map[string]float32{"GBP": 0.9},
+			map[string]float32{"USD": 1.5, "GBP": 0.9}, 0,
+		},
+		{
+			"unlocked value is replaced",
+			[]*Item{{Currency: "USD", Rate: 1.5}},
+			map[string]float32{"USD": 1.1},
+			map[string]float32{"USD": 1.1}, 0,
+		},
+	}
+	for _, tt := range tests {
+		old := &Rate{RateDate: "2020-01-02", Rates: tt.old}
+		rate := rateOf("2020-01-02", tt.incoming)
+		conflicts := keepOverrides(old, rate)
+		if len(conflicts) != tt.conflicts {
+			t.Errorf("%s: %d conflicts, want %d", tt.name, len(conflicts), tt.conflicts)
+		}
+		got := rate.RateMap()
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: rates %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for code, v := range tt.want {
+			if got[code] != v {
+				t.Errorf("%s: %s = %v, want %v", tt.name, code, got[code], v)
+			}
+		}
+	}
+}
+
+func TestKeepOverridesOriginal(t *testing.T) {
+	old := &Rate{Rates: []*Item{{Currency: "USD", Rate: 1.5, Locked: true}}}
+	rate := rateOf("2020-01-02", map[string]float32{"USD": 1.1})
+	keepOverrides(old, rate)
+	if rate.Rates[0].Original != 1.1 {
+		t.Errorf("Original = %v, want the incoming 1.1", rate.Rates[0].Original)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	PAGE_DEFAULT = 1000
+	PAGE_MAX     = 5000
+)
+
+// cursorPrefix versions the cursor format so it can change without old
+// cursors being misread.
+const cursorPrefix = "v1:"
+
+// Page is a ?limit= and ?cursor= pair. After is the last date the previous
+// page returned; the page starts the day after it.
+type Page struct {
+	Limit int
+	After string
+}
+
+func encodeCursor(date string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + date))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(b), cursorPrefix) {
+		return "", fmt.Errorf("invalid cursor")
+	}
+	date := strings.TrimPrefix(string(b), cursorPrefix)
+	if _, err := time.Parse(DATE_LAYOUT, date); err != nil {
+		return "", fmt.Errorf("invalid cursor")
+	}
+	return date, nil
+}
+
+// parsePage reads the page params. A cursor that doesn't decode is an error
+// rather than ignored, so a client can't silently restart from the start.
+func parsePage(c echo.Context) (*Page, error) {
+	page := &Page{Limit: PAGE_DEFAULT}
+	if v := c.QueryParam("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > PAGE_MAX {
+			return nil, fmt.Errorf("limit must be between 1 and %d", PAGE_MAX)
+		}
+		page.Limit = n
+	}
+	if v := c.QueryParam("cursor"); v != "" {
+		after, err := decodeCursor(v)
+		if err != nil {
+			return nil, err
+		}
+		page.After = after
+	}
+	return page, nil
+}
+
+// Start returns the first date of the page within a range starting at
+// start, which may be empty.
+func (p *Page) Start(start string) string {
+	if p.After == "" {
+		return start
+	}
+	t, _ := time.Parse(DATE_LAYOUT, p.After)
+	if next := t.AddDate(0, 0, 1).Format(DATE_LAYOUT); next > start {
+		return next
+	}
+	return start
+}
+
+// Cut returns how many of n items, sorted by date from Start on, belong on
+// the page. When some are left it sets a Link header to the next page,
+// whose cursor is the date of the last item kept.
+func (p *Page) Cut(c echo.Context, n int, date func(i int) string) int {
+	if n <= p.Limit {
+		return n
+	}
+	u := *c.Request().URL
+	q := u.Query()
+	q.Set("cursor", encodeCursor(date(p.Limit-1)))
+	u.RawQuery = q.Encode()
+	u.Scheme = c.Scheme()
+	u.Host = c.Request().Host
+	c.Response().Header().Set("Link", "<"+u.String()+`>; rel="next"`)
+	return p.Limit
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	date, err := decodeCursor(encodeCursor("2020-01-31"))
+	if err != nil || date != "2020-01-31" {
+		t.Errorf("decodeCursor = %q, %v", date, err)
+	}
+	for _, bad := range []string{"!!", "MjAyMC0wMS0zMQ", encodeCursor("yesterday")} {
+		if _, err := decodeCursor(bad); err == nil {
+			t.Errorf("decodeCursor(%q) accepted an invalid cursor", bad)
+		}
+	}
+}
+
+func pageContext(query string) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodGet, "/rates/range?"+query, nil)
+	rec := httptest.NewRecorder()
+	return echo.New().NewContext(req, rec), rec
+}
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		query string
+		limit int
+		after string
+		err   bool
+	}{
+		{"", PAGE_DEFAULT, "", false},
+		{"limit=10", 10, "", false},
+		{"limit=0", 0, "", true},
+		{"limit=5001", 0, "", true},
+		{"cursor=" + encodeCursor("2020-01-31"), PAGE_DEFAULT, "2020-01-31", false},
+		{"cursor=nope", 0, "", true},
+	}
+	for _, tt := range tests {
+		c, _ := pageContext(tt.query)
+		page, err := parsePage(c)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: err = %v, want error %v", tt.query, err, tt.err)
+			continue
+		}
+		if err == nil && (page.Limit != tt.limit || page.After != tt.after) {
+			t.Errorf("%q: page = %+v, want limit %d after %q", tt.query, page, tt.limit, tt.after)
+		}
+	}
+}
+
+func TestPageStart(t *testing.T) {
+	tests := []struct {
+		after, start, want string
+	}{
+		{"", "2020-01-01", "2020-01-01"},
+		{"2020-01-31", "2020-01-01", "2020-02-01"},
+		{"2019-12-01", "2020-01-01", "2020-01-01"},
+		{"2020-01-31", "", "2020-02-01"},
+	}
+	for _, tt := range tests {
+		p := &Page{Limit: 10, After: tt.after}
+		if got := p.Start(tt.start); got != tt.want {
+			t.Errorf("Start(%q) after %q = %q, want %q", tt.start, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestPageCut(t *testing.T) {
+	dates := []string{"2020-01-01", "2020-01-02", "2020-01-03"}
+	date := func(i int) string { return dates[i] }
+
+	c, rec := pageContext("start=2020-01-01&limit=2")
+	p := &Page{Limit: 2}
+	if n := p.Cut(c, len(dates), date); n != 2 {
+		t.Errorf("Cut = %d, want 2", n)
+	}
+	link := rec.Header().Get("Link")
+	if !strings.Contains(link, "cursor="+encodeCursor("2020-01-02")) || !strings.HasSuffix(link, `rel="next"`) {
+		t.Errorf("Link = %q", link)
+	}
+
+	c, rec = pageContext("limit=3")
+	p = &Page{Limit: 3}
+	if n := p.Cut(c, len(dates), date); n != 3 || rec.Header().Get("Link") != "" {
+		t.Errorf("last page: Cut = %d, Link = %q", n, rec.Header().Get("Link"))
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type PairPoint struct {
+	Date string  `json:"date"`
+	Rate float64 `json:"rate"`
+}
+
+type PairHistoryRes struct {
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Range   *DateRange   `json:"range"`
+	Skipped int          `json:"skipped"`
+	Series  []*PairPoint `json:"series"`
+}
+
+// pairHistory derives the to-per-from rate for each date from its EUR legs.
+// Dates that lack either leg are counted in Skipped.
+func pairHistory(rates []Rate, from, to string) ([]*PairPoint, int) {
+	series := []*PairPoint{}
+	skipped := 0
+	for i := range rates {
+		rate, err := crossRate(rates[i].RateMap(), from, to)
+		if err != nil {
+			skipped++
+			continue
+		}
+		series = append(series, &PairPoint{Date: rates[i].RateDate, Rate: rate})
+	}
+	return series, skipped
+}
+
+// PairAnalysisRes mirrors AnalysisData for a derived cross rate, kept in
+// float64 since the quotient of two large legs loses digits in float32.
+type PairAnalysisRes struct {
+	From         string     `json:"from"`
+	To           string     `json:"to"`
+	Min          float64    `json:"min"`
+	Max          float64    `json:"max"`
+	Avg          float64    `json:"avg"`
+	Stddev       float64    `json:"stddev"`
+	Observations int        `json:"observations"`
+	Skipped      int        `json:"skipped"`
+	Range        *DateRange `json:"range"`
+}
+
+// analyzePair aggregates the per-day cross rates; it can't be derived from
+// the EUR-based aggregates of Analyze.
+func analyzePair(series []*PairPoint) *PairAnalysisRes {
+	res := &PairAnalysisRes{Observations: len(series)}
+	if len(series) == 0 {
+		return res
+	}
+	values := make([]float64, len(series))
+	res.Min, res.Max = series[0].Rate, series[0].Rate
+	for i, pt := range series {
+		values[i] = pt.Rate
+		if pt.Rate < res.Min {
+			res.Min = pt.Rate
+		}
+		if pt.Rate > res.Max {
+			res.Max = pt.Rate
+		}
+	}
+	res.Avg = mean(values)
+	res.Stddev = stddev(values)
+	res.Range = &DateRange{Start: series[0].Date, End: series[len(series)-1].Date}
+	return res
+}
+
+func parsePair(c echo.Context) (string, string, bool) {
+	from := strings.ToUpper(c.Param("from"))
+	to := strings.ToUpper(c.Param("to"))
+	return from, to, currencyRe.MatchString(from) && currencyRe.MatchString(to)
+}
+
+func getPairHistory(c echo.Context) error {
+	from, to, ok := parsePair(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, "invalid currency pair")
+	}
+	r, err := parseHistoryRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+	page, err := parsePage(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	rates, err := storeFor(c).FindRange(page.Start(r.Start), r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	res := &PairHistoryRes{From: from, To: to, Range: r}
+	res.Series, res.Skipped = pairHistory(rates, from, to)
+	if n := page.Cut(c, len(res.Series), func(i int) string { return res.Series[i].Date }); n < len(res.Series) {
+		// Only count the skipped dates up to the end of this page.
+		last := res.Series[n-1].Date
+		kept := sort.Search(len(rates), func(i int) bool { return rates[i].RateDate > last })
+		res.Series, res.Skipped = pairHistory(rates[:kept], from, to)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func getPairAnalyze(c echo.Context) error {
+	from, to, ok := parsePair(c)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, "invalid currency pair")
+	}
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	series, skipped := pairHistory(rates, from, to)
+	if len(series) == 0 {
+		return c.JSON(http.StatusNotFound, "no rates for "+from+"/"+to+" in range")
+	}
+	res := analyzePair(series)
+	res.From, res.To, res.Skipped = from, to, skipped
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+var currencyRe = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// parseSymbols splits a comma separated list of currency codes, upper-casing
+// and de-duplicating them.
+func parseSymbols(s string) ([]string, error) {
+	symbols := []string{}
+	seen := map[string]bool{}
+	for _, sym := range parseList(s) {
+		sym = strings.ToUpper(sym)
+		if !currencyRe.MatchString(sym) {
+			return nil, fmt.Errorf("invalid currency code %q", sym)
+		}
+		if !seen[sym] {
+			seen[sym] = true
+			symbols = append(symbols, sym)
+		}
+	}
+	return symbols, nil
+}
+
+// setLastModified sends t as Last-Modified unless it is unknown.
+func setLastModified(c echo.Context, t time.Time) {
+	if !t.IsZero() {
+		c.Response().Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+}
+
+// parseUnit reads ?unit=, pct (the default) or bps.
+func parseUnit(c echo.Context) (string, error) {
+	switch unit := c.QueryParam("unit"); unit {
+	case "", UnitPct:
+		return UnitPct, nil
+	case UnitBps:
+		return UnitBps, nil
+	default:
+		return "", fmt.Errorf("unit must be pct or bps")
+	}
+}
+
+type DateRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// parseDryRun reads ?dryRun= and the ?tolerance= of its diff. A dryRun that
+// isn't a boolean is an error rather than false, so a typo can't turn a
+// preview into a real write.
+func parseDryRun(c echo.Context) (bool, float64, error) {
+	dryRun := false
+	if v := c.QueryParam("dryRun"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, 0, fmt.Errorf("dryRun must be a boolean")
+		}
+		dryRun = b
+	}
+	tolerance := 0.0
+	if v := c.QueryParam("tolerance"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 {
+			return false, 0, fmt.Errorf("tolerance must be a non-negative number")
+		}
+		tolerance = t
+	}
+	return dryRun, tolerance, nil
+}
+
+// SpanError is a range longer than MAX_RANGE_DAYS.
+type SpanError struct {
+	Max int
+}
+
+func (e *SpanError) Error() string {
+	return fmt.Sprintf("date range is longer than the maximum of %d days", e.Max)
+}
+
+// dateRangeError answers a range that failed to parse: 422 when it is too
+// long, 400 when it is malformed.
+func dateRangeError(c echo.Context, err error) error {
+	if _, ok := err.(*SpanError); ok {
+		return c.JSON(http.StatusUnprocessableEntity, err.Error())
+	}
+	return c.JSON(http.StatusBadRequest, err.Error())
+}
+
+// parseDateRange reads the optional start and end query params. A missing end
+// leaves the range open up to today; a missing start is filled in by
+// newDateRange.
+func parseDateRange(c echo.Context) (*DateRange, error) {
+	return newDateRange(c.QueryParam("start"), c.QueryParam("end"))
+}
+
+// parseHistoryRange is parseDateRange for the endpoints that default to the
+// whole history: a missing start leaves the range open, and only a range
+// with a start is capped.
+func parseHistoryRange(c echo.Context) (*DateRange, error) {
+	start, end := c.QueryParam("start"), c.QueryParam("end")
+	if start == "" {
+		return newDateRangeMax(start, end, 0)
+	}
+	return newDateRange(start, end)
+}
+
+// newDateRange validates a range and caps it at MAX_RANGE_DAYS. A missing
+// start defaults to MAX_RANGE_DAYS before end, or before today when end is
+// missing too, so no public range is ever longer than the cap.
+func newDateRange(start, end string) (*DateRange, error) {
+	return newDateRangeMax(start, end, config.MaxRangeDays)
+}
+
+// newDateRangeMax is newDateRange with its own cap, 0 for none; admin
+// routes use it to backfill or verify the whole history.
+func newDateRangeMax(start, end string, maxDays int) (*DateRange, error) {
+	r := &DateRange{Start: start, End: end}
+	for _, d := range []string{r.Start, r.End} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse(DATE_LAYOUT, d); err != nil {
+			return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", d)
+		}
+	}
+	if r.Start != "" && r.End != "" && r.Start > r.End {
+		return nil, fmt.Errorf("start %s is after end %s", r.Start, r.End)
+	}
+	if maxDays == 0 {
+		return r, nil
+	}
+	to, _ := time.Parse(DATE_LAYOUT, time.Now().UTC().Format(DATE_LAYOUT))
+	if r.End != "" {
+		to, _ = time.Parse(DATE_LAYOUT, r.End)
+	}
+	if r.Start == "" {
+		r.Start = to.AddDate(0, 0, -maxDays).Format(DATE_LAYOUT)
+	}
+	from, _ := time.Parse(DATE_LAYOUT, r.Start)
+	if to.Sub(from) > time.Duration(maxDays)*24*time.Hour {
+		return nil, &SpanError{Max: maxDays}
+	}
+	return r, nil
+}
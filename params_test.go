@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+func TestNewDateRangeMax(t *testing.T) {
+	tests := []struct {
+		name       string
+		start, end string
+		maxDays    int
+		err        bool
+		span       bool
+	}{
+		{"open", "", "", 366, false, false},
+		{"open start", "", "2020-01-31", 366, false, false},
+		{"within", "2019-01-01", "2019-12-31", 366, false, false},
+		{"exactly max", "2019-01-01", "2020-01-02", 366, false, false},
+		{"too long", "2019-01-01", "2020-01-03", 366, true, true},
+		{"no cap", "2000-01-01", "2020-01-01", 0, false, false},
+		{"reversed", "2020-01-02", "2020-01-01", 366, true, false},
+		{"malformed", "2020-13-01", "", 366, true, false},
+	}
+	for _, tt := range tests {
+		_, err := newDateRangeMax(tt.start, tt.end, tt.maxDays)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if _, ok := err.(*SpanError); ok != tt.span {
+			t.Errorf("%s: err = %v, want SpanError %v", tt.name, err, tt.span)
+		}
+	}
+}
+
+func TestNewDateRangeMaxDefaultStart(t *testing.T) {
+	tests := []struct {
+		start, end string
+		maxDays    int
+		want       string
+	}{
+		{"", "2020-12-31", 366, "2019-12-31"},
+		{"", "2020-01-31", 30, "2020-01-01"},
+		{"", "", 0, ""},
+		{"2020-06-01", "2020-12-31", 366, "2020-06-01"},
+	}
+	for _, tt := range tests {
+		r, err := newDateRangeMax(tt.start, tt.end, tt.maxDays)
+		if err != nil {
+			t.Errorf("%q..%q: %v", tt.start, tt.end, err)
+			continue
+		}
+		if r.Start != tt.want {
+			t.Errorf("%q..%q max %d: start = %q, want %q", tt.start, tt.end, tt.maxDays, r.Start, tt.want)
+		}
+	}
+
+	today := time.Now().UTC()
+	r, err := newDateRangeMax("", "", 10)
+	if err != nil || r.Start != today.AddDate(0, 0, -10).Format(DATE_LAYOUT) || r.End != "" {
+		t.Errorf("open range = %+v, %v; want 10 days back from today", r, err)
+	}
+}
+
+func TestParseHistoryRange(t *testing.T) {
+	defer func(prev int) { config.MaxRangeDays = prev }(config.MaxRangeDays)
+	config.MaxRangeDays = 366
+
+	tests := []struct {
+		query     string
+		wantStart string
+		span      bool
+	}{
+		{"", "", false},
+		{"end=2020-01-31", "", false},
+		{"start=2019-06-01&end=2019-12-31", "2019-06-01", false},
+		{"start=2000-01-01&end=2020-01-01", "", true},
+	}
+	e := echo.New()
+	for _, tt := range tests {
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/rates/rank?"+tt.query, nil), httptest.NewRecorder())
+		r, err := parseHistoryRange(c)
+		if _, ok := err.(*SpanError); ok != tt.span {
+			t.Errorf("%q: err = %v, want SpanError %v", tt.query, err, tt.span)
+			continue
+		}
+		if err == nil && r.Start != tt.wantStart {
+			t.Errorf("%q: start = %q, want %q", tt.query, r.Start, tt.wantStart)
+		}
+	}
+}
+
+func TestParseUnit(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"", UnitPct, false},
+		{"unit=pct", UnitPct, false},
+		{"unit=bps", UnitBps, false},
+		{"unit=BPS", "", true},
+		{"unit=bp", "", true},
+	}
+	e := echo.New()
+	for _, tt := range tests {
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/rates/baselines/q1/diff?"+tt.query, nil), httptest.NewRecorder())
+		got, err := parseUnit(c)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%q: parseUnit() = %q, %v; want %q, error %v", tt.query, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/labstack/echo"
+)
+
+type PegStatus struct {
+	Currency     string  `json:"currency"`
+	Observations int     `json:"observations"`
+	Mean         float64 `json:"mean,omitempty"`
+	Low          float64 `json:"low,omitempty"`
+	High         float64 `json:"high,omitempty"`
+	MaxDeviation float64 `json:"max_deviation,omitempty"`
+	Pegged       *bool   `json:"pegged"`
+	Note         string  `json:"note,omitempty"`
+}
+
+type PegsRes struct {
+	Window     int          `json:"window"`
+	Tolerance  float64      `json:"tolerance"`
+	Range      *DateRange   `json:"range,omitempty"`
+	Currencies []*PegStatus `json:"currencies"`
+}
+
+// detectPegs reports, per currency, whether every rate stayed within
+// tolerance (relative) of the window mean. A currency quoted on fewer than
+// half of the dates, or fewer than MIN_OBSERVATIONS, gets no verdict.
+func detectPegs(rates []Rate, tolerance float64) []*PegStatus {
+	series := map[string][]float64{}
+	for i := range rates {
+		for _, item := range rates[i].Rates {
+			series[item.Currency] = append(series[item.Currency], float64(item.Rate))
+		}
+	}
+
+	res := []*PegStatus{}
+	for code, values := range series {
+		s := &PegStatus{Currency: code, Observations: len(values)}
+		res = append(res, s)
+		if len(values) < MIN_OBSERVATIONS || len(values)*2 < len(rates) {
+			s.Note = "insufficient data"
+			continue
+		}
+		s.Mean = mean(values)
+		s.Low, s.High = values[0], values[0]
+		for _, v := range values {
+			s.Low = math.Min(s.Low, v)
+			s.High = math.Max(s.High, v)
+		}
+		if s.Mean != 0 {
+			s.MaxDeviation = math.Max(s.High-s.Mean, s.Mean-s.Low) / s.Mean
+		}
+		pegged := s.MaxDeviation <= tolerance
+		s.Pegged = &pegged
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Currency < res[j].Currency })
+	return res
+}
+
+func getPegs(c echo.Context) error {
+	window := 365
+	if v := c.QueryParam("window"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < MIN_OBSERVATIONS || n > 5000 {
+			return c.JSON(http.StatusBadRequest, "window must be between "+strconv.Itoa(MIN_OBSERVATIONS)+" and 5000")
+		}
+		window = n
+	}
+	tolerance := 0.005
+	if v := c.QueryParam("tolerance"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t <= 0 || t >= 1 {
+			return c.JSON(http.StatusBadRequest, "tolerance must be between 0 and 1")
+		}
+		tolerance = t
+	}
+
+	rates, err := storeFor(c).FindRecent(window)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	res := &PegsRes{Window: window, Tolerance: tolerance, Currencies: detectPegs(rates, tolerance)}
+	if len(rates) > 0 {
+		// FindRecent returns the newest date first.
+		res.Range = &DateRange{Start: rates[len(rates)-1].RateDate, End: rates[0].RateDate}
+	}
+	return c.JSON(http.StatusOK, res)
+}
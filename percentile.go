@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type PercentileRankRes struct {
+	Currency   string  `json:"currency"`
+	Date       string  `json:"date"`
+	Latest     float64 `json:"latest"`
+	Percentile float64 `json:"percentile"`
+	Below      int     `json:"below"`
+	Equal      int     `json:"equal"`
+	Samples    int     `json:"samples"`
+	Window     int     `json:"window,omitempty"`
+}
+
+// percentileRank places the most recent rate of currency in rates (sorted by
+// date ascending) within the distribution of all its values. Ties count
+// half, so a series of identical values ranks at 50 and the maximum of n
+// distinct values at 100 - 50/n. Nil means the currency has no values.
+func percentileRank(rates []Rate, currency string) *PercentileRankRes {
+	res := &PercentileRankRes{Currency: currency}
+	values := []float64{}
+	for i := range rates {
+		if v, ok := rates[i].RateMap()[currency]; ok {
+			values = append(values, float64(v))
+			res.Date = rates[i].RateDate
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	res.Latest = values[len(values)-1]
+	for _, v := range values {
+		switch {
+		case v < res.Latest:
+			res.Below++
+		case v == res.Latest:
+			res.Equal++
+		}
+	}
+	res.Samples = len(values)
+	res.Percentile = (float64(res.Below) + float64(res.Equal)/2) / float64(res.Samples) * 100
+	return res
+}
+
+func getPercentileRank(c echo.Context) error {
+	currency := strings.ToUpper(c.QueryParam("currency"))
+	if !currencyRe.MatchString(currency) {
+		return c.JSON(http.StatusBadRequest, "invalid currency")
+	}
+	window := 0
+	if v := c.QueryParam("window"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 5000 {
+			return c.JSON(http.StatusBadRequest, "window must be between 1 and 5000")
+		}
+		window = n
+	}
+
+	rates, err := storeFor(c).FindWindow(window)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	res := percentileRank(rates, currency)
+	if res == nil {
+		return c.JSON(http.StatusNotFound, "no data for "+currency)
+	}
+	res.Window = window
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+var periodRe = regexp.MustCompile(`^\d{4}(-(0[1-9]|1[0-2]))?$`)
+
+type PeriodRates struct {
+	Base   string                        `json:"base"`
+	Period string                        `json:"period"`
+	Rates  map[string]map[string]float32 `json:"rates"`
+}
+
+// periodRange turns a YYYY or YYYY-MM period into the date range it covers.
+// Day 31 bounds every month since dates compare as strings.
+func periodRange(period string) (*DateRange, bool) {
+	if !periodRe.MatchString(period) {
+		return nil, false
+	}
+	if len(period) == 4 {
+		return &DateRange{Start: period + "-01-01", End: period + "-12-31"}, true
+	}
+	return &DateRange{Start: period + "-01", End: period + "-31"}, true
+}
+
+// getPeriodRates serves /rates/:date for a year or month: every stored date
+// in the period keyed by date.
+func getPeriodRates(c echo.Context, r *DateRange) error {
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	period := c.Param("date")
+	if len(rates) == 0 {
+		return c.JSON(http.StatusNotFound, "no rates for "+period)
+	}
+
+	res := &PeriodRates{Base: "EUR", Period: period, Rates: map[string]map[string]float32{}}
+	var modified time.Time
+	for i := range rates {
+		res.Rates[rates[i].RateDate] = rates[i].RateMap()
+		if t := rates[i].ModifiedAt(); t.After(modified) {
+			modified = t
+		}
+	}
+	setLastModified(c, modified)
+	return jsonFields(c, http.StatusOK, res)
+}
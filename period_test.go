@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func TestPeriodRange(t *testing.T) {
+	tests := []struct {
+		period     string
+		start, end string
+		ok         bool
+	}{
+		{"2020", "2020-01-01", "2020-12-31", true},
+		{"2020-02", "2020-02-01", "2020-02-31", true},
+		{"2020-12", "2020-12-01", "2020-12-31", true},
+		{"2020-01-15", "", "", false},
+		{"2020-13", "", "", false},
+		{"2020-00", "", "", false},
+		{"20", "", "", false},
+		{"2020-1", "", "", false},
+		{"abcd", "", "", false},
+	}
+	for _, tt := range tests {
+		r, ok := periodRange(tt.period)
+		if ok != tt.ok {
+			t.Errorf("%q: ok = %v, want %v", tt.period, ok, tt.ok)
+			continue
+		}
+		if ok && (r.Start != tt.start || r.End != tt.end) {
+			t.Errorf("%q: %s..%s, want %s..%s", tt.period, r.Start, r.End, tt.start, tt.end)
+		}
+	}
+}
+
+func TestGetDateRatePeriods(t *testing.T) {
+	store := testStore(t)
+	for _, date := range []string{"2019-12-31", "2020-01-02", "2020-01-31", "2020-02-03"} {
+		if _, err := store.Save(rateOf(date, map[string]float32{"USD": 1.1})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := echo.New()
+	e.GET("/rates/:date", getDateRate)
+	tests := []struct {
+		date   string
+		status int
+		dates  []string
+	}{
+		{"2020", http.StatusOK, []string{"2020-01-02", "2020-01-31", "2020-02-03"}},
+		{"2020-01", http.StatusOK, []string{"2020-01-02", "2020-01-31"}},
+		{"2020-01-31", http.StatusOK, []string{"2020-01-31"}},
+		{"2021-01", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rates/"+tt.date, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.date, rec.Code, tt.status)
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+		var got []string
+		if len(tt.date) == len(DATE_LAYOUT) {
+			var res DailyRate
+			json.Unmarshal(rec.Body.Bytes(), &res)
+			got = []string{res.Date}
+		} else {
+			var res PeriodRates
+			json.Unmarshal(rec.Body.Bytes(), &res)
+			for d := range res.Rates {
+				got = append(got, d)
+			}
+			sort.Strings(got)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.dates, ",") {
+			t.Errorf("%s: got %v, want %v", tt.date, got, tt.dates)
+		}
+	}
+}
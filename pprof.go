@@ -0,0 +1,50 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/labstack/echo"
+)
+
+// registerPprof mounts the net/http/pprof handlers when ENABLE_PPROF is set.
+// With PPROF_ADDR they get their own loopback-only listener, otherwise they
+// live on the main listener behind the admin key.
+func registerPprof(e *echo.Echo) {
+	if !config.EnablePprof {
+		return
+	}
+
+	if config.PprofAddr != "" {
+		host, _, err := net.SplitHostPort(config.PprofAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			log.Fatalf("PPROF_ADDR must be a loopback address, got %q", config.PprofAddr)
+		}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+		go func() {
+			log.Println("pprof listening on", config.PprofAddr)
+			log.Println(http.ListenAndServe(config.PprofAddr, mux))
+		}()
+		return
+	}
+
+	g := e.Group("/debug/pprof", adminAuth())
+	g.GET("/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	g.GET("/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+	g.GET("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	g.POST("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	g.GET("/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+	g.GET("/*", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+}
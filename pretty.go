@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo"
+)
+
+// prettyContext indents every JSON response. Handlers and helpers such as
+// jsonFields keep calling c.JSON and get indented output for free.
+type prettyContext struct {
+	echo.Context
+}
+
+func (c *prettyContext) JSON(code int, i interface{}) error {
+	return c.Context.JSONPretty(code, i, "  ")
+}
+
+// compactContext never indents. echo's own Context.JSON indents whenever a
+// pretty parameter is present, whatever its value, so ?pretty=false needs it.
+type compactContext struct {
+	echo.Context
+}
+
+func (c *compactContext) JSON(code int, i interface{}) error {
+	b, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	return c.Context.JSONBlob(code, b)
+}
+
+// withPretty wraps c according to its pretty query parameter.
+func withPretty(c echo.Context) (echo.Context, error) {
+	v := c.QueryParam("pretty")
+	if v == "" {
+		return c, nil
+	}
+	pretty, err := strconv.ParseBool(v)
+	if err != nil {
+		return &compactContext{c}, err
+	}
+	if pretty {
+		return &prettyContext{c}, nil
+	}
+	return &compactContext{c}, nil
+}
+
+// prettyJSON serves ?pretty requests through a prettyContext or a
+// compactContext. It runs before the other middleware so their error
+// responses follow the parameter too.
+func prettyJSON() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			wrapped, err := withPretty(c)
+			if err != nil {
+				return wrapped.JSON(http.StatusBadRequest, "pretty must be a boolean")
+			}
+			return next(wrapped)
+		}
+	}
+}
+
+// prettyErrors makes handler honour ?pretty as well. Errors returned up the
+// chain (echo.HTTPError from auth, routing or Recover) reach the error
+// handler with the unwrapped context, so prettyJSON alone misses them.
+func prettyErrors(handler echo.HTTPErrorHandler) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		switch c.(type) {
+		case *prettyContext, *compactContext:
+		default:
+			c, _ = withPretty(c)
+		}
+		handler(err, c)
+	}
+}
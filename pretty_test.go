@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func TestPrettyErrors(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = prettyErrors(e.DefaultHTTPErrorHandler)
+	e.Use(prettyJSON())
+	e.GET("/ok", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+	})
+	e.GET("/denied", func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusUnauthorized, "denied")
+	})
+
+	tests := []struct {
+		target string
+		code   int
+		pretty bool
+	}{
+		{"/ok?pretty=true", http.StatusOK, true},
+		{"/ok?pretty=false", http.StatusOK, false},
+		{"/ok", http.StatusOK, false},
+		{"/ok?pretty=maybe", http.StatusBadRequest, false},
+		{"/denied?pretty=true", http.StatusUnauthorized, true},
+		{"/denied?pretty=false", http.StatusUnauthorized, false},
+		{"/missing?pretty=true", http.StatusNotFound, true},
+		{"/missing", http.StatusNotFound, false},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%s: status %d, want %d", tt.target, rec.Code, tt.code)
+		}
+		if got := strings.Contains(rec.Body.String(), "\n  "); got != tt.pretty {
+			t.Errorf("%s: indented = %v, want %v (body %q)", tt.target, got, tt.pretty, rec.Body.String())
+		}
+	}
+}
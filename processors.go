@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+// PostProcessor adjusts a document fetched from the provider before Refresh
+// saves it. Returning an error wrapping errRejected drops the document; any
+// other error fails the run.
+type PostProcessor func(*Rate) error
+
+var errRejected = errors.New("rejected")
+
+// postProcessorFactories builds the named processors for one ingest run.
+// config.PostProcessors selects which ones run and in what order.
+var postProcessorFactories = map[string]func(store *DB) PostProcessor{
+	"precision": func(*DB) PostProcessor { return normalizePrecision },
+	"outliers":  rejectOutliers,
+}
+
+func buildPostProcessors(store *DB, names []string) []PostProcessor {
+	procs := []PostProcessor{}
+	for _, name := range names {
+		if factory, ok := postProcessorFactories[name]; ok {
+			procs = append(procs, factory(store))
+		}
+	}
+	return procs
+}
+
+// applyPostProcessors runs procs in order and stops at the first error.
+func applyPostProcessors(procs []PostProcessor, rate *Rate) error {
+	for _, proc := range procs {
+		if err := proc(rate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizePrecision rounds every rate to config.RatePrecision decimals so
+// the same quote always compares equal in Save.
+func normalizePrecision(rate *Rate) error {
+	scale := math.Pow(10, float64(config.RatePrecision))
+	for i := range rate.Rates {
+		rate.Rates[i].Rate = float32(math.Round(float64(rate.Rates[i].Rate)*scale) / scale)
+	}
+	return nil
+}
+
+// rejectOutliers drops a document when any currency moved more than
+// config.OutlierMaxPct percent from the previous stored date.
+func rejectOutliers(store *DB) PostProcessor {
+	return func(rate *Rate) error {
+		prev, err := store.FindBefore(rate.RateDate)
+		if err == mgo.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if outliers := findOutliers(prev, rate, config.OutlierMaxPct); len(outliers) > 0 {
+			o := outliers[0]
+			return fmt.Errorf("%w: %s moved %.2f%% since %s", errRejected, o.Currency, o.ChangePct, prev.RateDate)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestApplyPostProcessors(t *testing.T) {
+	var order []string
+	record := func(name string, err error) PostProcessor {
+		return func(*Rate) error {
+			order = append(order, name)
+			return err
+		}
+	}
+	tests := []struct {
+		name     string
+		procs    []PostProcessor
+		order    []string
+		rejected bool
+	}{
+		{"in order", []PostProcessor{record("a", nil), record("b", nil), record("c", nil)}, []string{"a", "b", "c"}, false},
+		{"reject stops the rest", []PostProcessor{record("a", nil), record("b", fmt.Errorf("%w: too far", errRejected)), record("c", nil)}, []string{"a", "b"}, true},
+		{"none", nil, nil, false},
+	}
+	for _, tt := range tests {
+		order = nil
+		err := applyPostProcessors(tt.procs, rateOf("2024-01-31", nil))
+		if !reflect.DeepEqual(order, tt.order) {
+			t.Errorf("%s: ran %v, want %v", tt.name, order, tt.order)
+		}
+		if errors.Is(err, errRejected) != tt.rejected {
+			t.Errorf("%s: err = %v, want rejected %v", tt.name, err, tt.rejected)
+		}
+	}
+}
+
+func TestBuildPostProcessors(t *testing.T) {
+	if got := len(buildPostProcessors(p, []string{"precision", "unknown", "outliers"})); got != 2 {
+		t.Errorf("built %d processors, want the 2 known ones", got)
+	}
+}
+
+func TestNormalizePrecision(t *testing.T) {
+	defer func(prev int) { config.RatePrecision = prev }(config.RatePrecision)
+	tests := []struct {
+		precision int
+		in, want  float32
+	}{
+		{4, 1.08374, 1.0837},
+		{4, 1.08375, 1.0838},
+		{2, 161.456, 161.46},
+		{0, 161.456, 161},
+	}
+	for _, tt := range tests {
+		config.RatePrecision = tt.precision
+		rate := rateOf("2024-01-31", map[string]float32{"USD": tt.in})
+		if err := normalizePrecision(rate); err != nil {
+			t.Fatal(err)
+		}
+		if got := rate.Rates[0].Rate; got != tt.want {
+			t.Errorf("precision %d: %v -> %v, want %v", tt.precision, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRejectOutliers(t *testing.T) {
+	store := testStore(t)
+	defer func(prev float64) { config.OutlierMaxPct = prev }(config.OutlierMaxPct)
+	config.OutlierMaxPct = 5
+	if _, err := store.Save(rateOf("2024-01-30", map[string]float32{"USD": 1.00})); err != nil {
+		t.Fatal(err)
+	}
+
+	reject := rejectOutliers(store)
+	tests := []struct {
+		rate     *Rate
+		rejected bool
+	}{
+		{rateOf("2024-01-31", map[string]float32{"USD": 1.04}), false},
+		{rateOf("2024-01-31", map[string]float32{"USD": 1.10}), true},
+		{rateOf("2024-01-29", map[string]float32{"USD": 9}), false},
+	}
+	for _, tt := range tests {
+		if err := reject(tt.rate); errors.Is(err, errRejected) != tt.rejected {
+			t.Errorf("%s USD %v: err = %v, want rejected %v", tt.rate.RateDate, tt.rate.Rates[0].Rate, err, tt.rejected)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+const (
+	QUEUE_RETRY_MIN = 5 * time.Second
+	QUEUE_RETRY_MAX = 5 * time.Minute
+)
+
+var errQueueNotEmpty = errors.New("queued behind older batches")
+
+// isStoreDown reports whether err means Mongo could not be reached, as
+// opposed to Mongo rejecting the operation.
+func isStoreDown(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	if err == io.EOF || errors.As(err, &netErr) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "no reachable servers") || strings.Contains(msg, "Closed explicitly")
+}
+
+// QueuedBatch is a fetched batch that could not be persisted yet.
+type QueuedBatch struct {
+	Run       *IngestRun  `json:"run"`
+	Rates     []*Rate     `json:"rates"`
+	State     *FetchState `json:"state"`
+	QueuedAt  time.Time   `json:"queued_at"`
+	Attempts  int         `json:"attempts"`
+	LastError string      `json:"last_error"`
+}
+
+// IngestQueue holds batches that failed to persist because Mongo was down
+// and retries them oldest first with backoff. It keeps at most
+// INGEST_QUEUE_SIZE batches, dropping the oldest, and mirrors itself to
+// INGEST_SPOOL_FILE when set so a restart picks up where it left off.
+type IngestQueue struct {
+	mu      sync.Mutex
+	batches []*QueuedBatch
+	kick    chan struct{}
+}
+
+var ingestQueue = &IngestQueue{kick: make(chan struct{}, 1)}
+
+func (q *IngestQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.batches)
+}
+
+func (q *IngestQueue) Push(run *IngestRun, rates []*Rate, state *FetchState, reason error) {
+	q.mu.Lock()
+	q.batches = append(q.batches, &QueuedBatch{
+		Run:       run,
+		Rates:     rates,
+		State:     state,
+		QueuedAt:  time.Now().UTC(),
+		LastError: reason.Error(),
+	})
+	if n := len(q.batches) - config.IngestQueueSize; n > 0 {
+		for _, b := range q.batches[:n] {
+			log.Println("ingest queue full, dropping run", b.Run.ID.Hex(), "queued at", b.QueuedAt)
+		}
+		q.batches = q.batches[n:]
+	}
+	q.spool()
+	q.mu.Unlock()
+	q.wake()
+}
+
+func (q *IngestQueue) wake() {
+	select {
+	case q.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (q *IngestQueue) head() *QueuedBatch {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.batches) == 0 {
+		return nil
+	}
+	return q.batches[0]
+}
+
+// pop removes b, which must still be the head of the queue.
+func (q *IngestQueue) pop(b *QueuedBatch) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.batches) > 0 && q.batches[0] == b {
+		q.batches = q.batches[1:]
+	}
+	q.spool()
+}
+
+func (q *IngestQueue) failed(b *QueuedBatch, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	b.Attempts++
+	b.LastError = err.Error()
+	q.spool()
+}
+
+// spool writes the queue to the spool file, removing it once the queue is
+// empty. The caller holds q.mu.
+func (q *IngestQueue) spool() {
+	path := config.IngestSpoolFile
+	if path == "" {
+		return
+	}
+	if len(q.batches) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Println("ingest queue, error on Remove", err)
+		}
+		return
+	}
+	b, err := json.Marshal(q.batches)
+	if err == nil {
+		err = writeFileAtomic(path, b)
+	}
+	if err != nil {
+		log.Println("ingest queue, error writing spool file", err)
+	}
+}
+
+// Load reads the batches a previous process left in the spool file.
+func (q *IngestQueue) Load() {
+	path := config.IngestSpoolFile
+	if path == "" {
+		return
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		log.Println("ingest queue, error on ReadFile", err)
+		return
+	}
+	var batches []*QueuedBatch
+	if err := json.Unmarshal(b, &batches); err != nil {
+		log.Println("ingest queue,", path, "is corrupt, ignoring it:", err)
+		return
+	}
+	q.mu.Lock()
+	q.batches = append(batches, q.batches...)
+	q.mu.Unlock()
+	log.Println("ingest queue, loaded", len(batches), "batches from", path)
+	q.wake()
+}
+
+// Start drains the queue in the background whenever it has batches.
+func (q *IngestQueue) Start() {
+	go func() {
+		for range q.kick {
+			backoff := QUEUE_RETRY_MIN
+			for q.head() != nil {
+				if err := q.drainOne(); err != nil {
+					log.Println("ingest queue, retrying in", backoff, "after", err)
+					time.Sleep(backoff)
+					if backoff *= 2; backoff > QUEUE_RETRY_MAX {
+						backoff = QUEUE_RETRY_MAX
+					}
+					continue
+				}
+				backoff = QUEUE_RETRY_MIN
+			}
+		}
+	}()
+}
+
+// drainOne persists the oldest batch and completes its run record. A batch
+// Mongo rejects outright is marked failed and dropped rather than retried.
+func (q *IngestQueue) drainOne() error {
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+
+	b := q.head()
+	if b == nil {
+		return nil
+	}
+
+	ctx, span := tracer.Start(context.Background(), "ingest.drain")
+	defer span.End()
+	store := p.WithContext(ctx)
+
+	// A session that lost its socket keeps failing until refreshed.
+	db.Session.Refresh()
+	release, err := holdLock(store, "ingest", config.LockTTL)
+	if err != nil {
+		q.failed(b, err)
+		return err
+	}
+	defer release()
+
+	err = persistRates(ctx, store, b.Run, b.Rates, b.State)
+	if isStoreDown(err) {
+		q.failed(b, err)
+		return err
+	}
+	b.Run.FinishedAt = time.Now().UTC()
+	b.Run.DurationMs = b.Run.FinishedAt.Sub(b.Run.StartedAt).Milliseconds()
+	b.Run.Error = ""
+	if err != nil {
+		b.Run.Status = RunFailed
+		b.Run.Error = err.Error()
+	}
+	refreshStatus.Record(b.Run.FinishedAt, err)
+	if err := store.UpdateIngestRun(b.Run); err != nil {
+		logCtx(ctx, "ingest queue, error on UpdateIngestRun", err)
+	}
+	logCtx(ctx, "ingest queue, run", b.Run.ID.Hex(), b.Run.Status, "after", b.Attempts+1, "attempts")
+	q.pop(b)
+	return nil
+}
+
+// QueueStatus summarizes a queued batch without its rates.
+type QueueStatus struct {
+	RunID     string    `json:"run_id"`
+	Trigger   string    `json:"trigger"`
+	QueuedAt  time.Time `json:"queued_at"`
+	Dates     int       `json:"dates"`
+	First     string    `json:"first,omitempty"`
+	Last      string    `json:"last,omitempty"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+}
+
+func getIngestQueue(c echo.Context) error {
+	ingestQueue.mu.Lock()
+	defer ingestQueue.mu.Unlock()
+
+	res := []*QueueStatus{}
+	for _, b := range ingestQueue.batches {
+		s := &QueueStatus{
+			RunID:     b.Run.ID.Hex(),
+			Trigger:   b.Run.Trigger,
+			QueuedAt:  b.QueuedAt,
+			Dates:     len(b.Rates),
+			Attempts:  b.Attempts,
+			LastError: b.LastError,
+		}
+		for _, rate := range b.Rates {
+			if s.First == "" || rate.RateDate < s.First {
+				s.First = rate.RateDate
+			}
+			if rate.RateDate > s.Last {
+				s.Last = rate.RateDate
+			}
+		}
+		res = append(res, s)
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const API_USAGE_COLLECTION = "api_usage"
+
+// APIKey is a partner key sent in X-API-Key. A DailyQuota of 0 is unlimited.
+type APIKey struct {
+	Key        string `yaml:"key"`
+	DailyQuota int    `yaml:"daily_quota"`
+}
+
+// parseAPIKeys reads API_KEYS, "name:key:quota" entries separated by commas.
+func parseAPIKeys(s string) (map[string]APIKey, error) {
+	res := map[string]APIKey{}
+	for _, part := range parseList(s) {
+		fields := strings.Split(part, ":")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("entries must be NAME:KEY:QUOTA")
+		}
+		quota, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("%s has an invalid quota", fields[0])
+		}
+		res[fields[0]] = APIKey{Key: fields[1], DailyQuota: quota}
+	}
+	return res, nil
+}
+
+// lookupAPIKey returns the name of the configured key matching key.
+func lookupAPIKey(key string) (string, APIKey, bool) {
+	for name, k := range config.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k.Key)) == 1 {
+			return name, k, true
+		}
+	}
+	return "", APIKey{}, false
+}
+
+// IncrUsage adds n to the day's counter of name and returns the new total,
+// which includes the requests every other instance has flushed.
+func (p *DB) IncrUsage(name, day string, n int) (int, error) {
+	span := p.startSpan("IncrUsage")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	var doc struct {
+		Count int `bson:"count"`
+	}
+	change := mgo.Change{
+		Update: bson.M{
+			"$inc":         bson.M{"count": n},
+			"$setOnInsert": bson.M{"name": name, "day": day},
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+	_, err := db.C(API_USAGE_COLLECTION).FindId(name+":"+day).Apply(change, &doc)
+	return doc.Count, err
+}
+
+type keyUsage struct {
+	flushed int // total in the store after the last flush
+	pending int // counted here since then
+}
+
+// UsageCounter counts requests per key in memory so the hot path never
+// waits on Mongo. Every USAGE_FLUSH_INTERVAL the pending counts are added
+// to api_usage and the totals read back, which is how instances see each
+// other's traffic; between flushes a key can overshoot its quota by what
+// the other instances served in that interval. Counters reset at UTC
+// midnight.
+type UsageCounter struct {
+	mu   sync.Mutex
+	day  string
+	keys map[string]*keyUsage
+}
+
+var usage = &UsageCounter{keys: map[string]*keyUsage{}}
+
+func usageDay(t time.Time) string {
+	return t.UTC().Format(DATE_LAYOUT)
+}
+
+// nextReset is the UTC midnight after t.
+func nextReset(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+// rollover resets the counters when the UTC day changes. The caller holds
+// u.mu.
+func (u *UsageCounter) rollover(now time.Time) {
+	if day := usageDay(now); day != u.day {
+		u.day = day
+		u.keys = map[string]*keyUsage{}
+	}
+}
+
+func (u *UsageCounter) get(name string) *keyUsage {
+	k, ok := u.keys[name]
+	if !ok {
+		k = &keyUsage{}
+		u.keys[name] = k
+	}
+	return k
+}
+
+// Take counts one request for name unless that would exceed quota. It
+// returns the requests left after this one.
+func (u *UsageCounter) Take(name string, quota int, now time.Time) (int, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rollover(now)
+	k := u.get(name)
+	used := k.flushed + k.pending
+	if quota > 0 && used >= quota {
+		return 0, false
+	}
+	k.pending++
+	return quota - used - 1, true
+}
+
+func (u *UsageCounter) Used(name string, now time.Time) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.rollover(now)
+	k := u.get(name)
+	return k.flushed + k.pending
+}
+
+// Flush writes the pending counts of every configured key and picks up the
+// totals other instances wrote.
+func (u *UsageCounter) Flush(store *DB) {
+	u.mu.Lock()
+	u.rollover(time.Now())
+	day := u.day
+	deltas := map[string]int{}
+	for name := range config.APIKeys {
+		deltas[name] = u.get(name).pending
+	}
+	u.mu.Unlock()
+
+	for name, n := range deltas {
+		total, err := store.IncrUsage(name, day, n)
+		if err != nil {
+			log.Println("usage, error on IncrUsage", name, err)
+			continue
+		}
+		u.mu.Lock()
+		if u.day == day {
+			k := u.get(name)
+			k.pending -= n
+			k.flushed = total
+		}
+		u.mu.Unlock()
+	}
+}
+
+// Start flushes the counters in the background. Read-only instances and
+// instances without Mongo keep counting locally only.
+func (u *UsageCounter) Start() {
+	if len(config.APIKeys) == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(config.UsageFlushInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if !dbReady.Load() || config.ReadOnly {
+				continue
+			}
+			u.Flush(p)
+		}
+	}()
+}
+
+// quota enforces the daily quota of requests sent with X-API-Key; an
+// unknown key is a 401. Requests without a key count against
+// ANONYMOUS_DAILY_QUOTA per client IP, in memory only, so each instance
+// allows an IP the full quota.
+func quota() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get("X-API-Key")
+			if key == "" {
+				if config.AnonymousQuota == 0 {
+					return next(c)
+				}
+				return takeQuota(c, next, "ip:"+c.RealIP(), config.AnonymousQuota)
+			}
+			if len(config.APIKeys) == 0 {
+				return next(c)
+			}
+			name, k, ok := lookupAPIKey(key)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, "unknown API key")
+			}
+			c.Set("api_key", name)
+			return takeQuota(c, next, name, k.DailyQuota)
+		}
+	}
+}
+
+// takeQuota counts the request against name and answers 429 once the
+// daily quota is used up. A quota of 0 counts without limiting.
+func takeQuota(c echo.Context, next echo.HandlerFunc, name string, quota int) error {
+	now := time.Now()
+	remaining, ok := usage.Take(name, quota, now)
+	if quota == 0 {
+		return next(c)
+	}
+	h := c.Response().Header()
+	h.Set("X-RateLimit-Limit", strconv.Itoa(quota))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(nextReset(now).Unix(), 10))
+	if !ok {
+		return c.JSON(http.StatusTooManyRequests, "daily quota of "+strconv.Itoa(quota)+" requests exceeded")
+	}
+	return next(c)
+}
+
+type UsageRes struct {
+	Name      string    `json:"name"`
+	Day       string    `json:"day"`
+	Used      int       `json:"used"`
+	Quota     int       `json:"quota"`
+	Remaining *int      `json:"remaining,omitempty"`
+	Reset     time.Time `json:"reset"`
+}
+
+func getAccountUsage(c echo.Context) error {
+	name, _ := c.Get("api_key").(string)
+	if name == "" {
+		return c.JSON(http.StatusUnauthorized, "send an X-API-Key header")
+	}
+	k := config.APIKeys[name]
+	now := time.Now()
+	res := &UsageRes{
+		Name:  name,
+		Day:   usageDay(now),
+		Used:  usage.Used(name, now),
+		Quota: k.DailyQuota,
+		Reset: nextReset(now),
+	}
+	if k.DailyQuota > 0 {
+		remaining := k.DailyQuota - res.Used
+		if remaining < 0 {
+			remaining = 0
+		}
+		res.Remaining = &remaining
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+)
+
+func TestQuota(t *testing.T) {
+	defer func(prev Config) { *config = prev }(*config)
+	defer func(prev *UsageCounter) { usage = prev }(usage)
+
+	type req struct {
+		key, ip string
+		want    int
+	}
+	tests := []struct {
+		name      string
+		keys      map[string]APIKey
+		anonymous int
+		reqs      []req
+	}{
+		{
+			"unkeyed unlimited by default",
+			map[string]APIKey{"acme": {Key: "k3y", DailyQuota: 1}},
+			0,
+			[]req{{"", "10.0.0.1", 200}, {"", "10.0.0.1", 200}, {"", "10.0.0.1", 200}},
+		},
+		{
+			"unkeyed limited per IP",
+			nil,
+			2,
+			[]req{{"", "10.0.0.1", 200}, {"", "10.0.0.1", 200}, {"", "10.0.0.1", 429}, {"", "10.0.0.2", 200}},
+		},
+		{
+			"keyed and unkeyed counted apart",
+			map[string]APIKey{"acme": {Key: "k3y", DailyQuota: 1}},
+			1,
+			[]req{{"k3y", "10.0.0.1", 200}, {"", "10.0.0.1", 200}, {"k3y", "10.0.0.1", 429}, {"", "10.0.0.1", 429}},
+		},
+		{
+			"unknown key",
+			map[string]APIKey{"acme": {Key: "k3y"}},
+			0,
+			[]req{{"guess", "10.0.0.1", 401}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.APIKeys, config.AnonymousQuota = tt.keys, tt.anonymous
+			usage = &UsageCounter{keys: map[string]*keyUsage{}}
+			e := echo.New()
+			e.Use(quota())
+			e.GET("/rates/latest", func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+
+			for i, r := range tt.reqs {
+				req := httptest.NewRequest(http.MethodGet, "/rates/latest", nil)
+				req.Header.Set("X-Real-IP", r.ip)
+				if r.key != "" {
+					req.Header.Set("X-API-Key", r.key)
+				}
+				rec := httptest.NewRecorder()
+				e.ServeHTTP(rec, req)
+				if rec.Code != r.want {
+					t.Errorf("request %d (key %q, ip %s): status %d, want %d", i, r.key, r.ip, rec.Code, r.want)
+				}
+			}
+		})
+	}
+}
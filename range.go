@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// isBusinessDay reports whether date is a weekday that is not in the
+// configured holiday list.
+func isBusinessDay(date string) bool {
+	t, err := time.Parse(DATE_LAYOUT, date)
+	if err != nil {
+		return false
+	}
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	for _, h := range config.Holidays {
+		if h == date {
+			return false
+		}
+	}
+	return true
+}
+
+func toDailyRates(c echo.Context, rates []Rate) ([]*DailyRate, error) {
+	businessOnly := false
+	if v := c.QueryParam("business_only"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, err
+		}
+		businessOnly = b
+	}
+
+	res := []*DailyRate{}
+	for i := range rates {
+		if businessOnly && !isBusinessDay(rates[i].RateDate) {
+			continue
+		}
+		res = append(res, &DailyRate{
+			Base:  "EUR",
+			Date:  rates[i].RateDate,
+			Rates: rates[i].RateMap(),
+		})
+	}
+	return res, nil
+}
+
+func getRange(c echo.Context) error {
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+	if r.Start == "" || r.End == "" {
+		return c.JSON(http.StatusBadRequest, "start and end are required")
+	}
+	page, err := parsePage(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	rates, err := storeFor(c).FindRange(page.Start(r.Start), r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	res, err := toDailyRates(c, rates)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "business_only must be a boolean")
+	}
+	res = res[:page.Cut(c, len(res), func(i int) string { return res[i].Date })]
+	return jsonFields(c, http.StatusOK, res)
+}
+
+func getRecent(c echo.Context) error {
+	days := 10
+	if v := c.QueryParam("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 366 {
+			return c.JSON(http.StatusBadRequest, "days must be between 1 and 366")
+		}
+		days = n
+	}
+
+	rates, err := storeFor(c).FindRecent(days)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	res, err := toDailyRates(c, rates)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, "business_only must be a boolean")
+	}
+	return jsonFields(c, http.StatusOK, res)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// rankMetrics maps the accepted metric names to their per-currency value.
+var rankMetrics = map[string]func(*AnalyzeRes) float64{
+	"stddev": func(a *AnalyzeRes) float64 { return a.Stddev },
+	"spread": func(a *AnalyzeRes) float64 { return float64(a.Max - a.Min) },
+	"avg":    func(a *AnalyzeRes) float64 { return float64(a.Avg) },
+}
+
+type RankRes struct {
+	Currency   string     `json:"currency"`
+	Metric     string     `json:"metric"`
+	Value      float64    `json:"value"`
+	Rank       int        `json:"rank"`
+	Total      int        `json:"total"`
+	Percentile float64    `json:"percentile"`
+	Range      *DateRange `json:"range"`
+}
+
+// rankCurrency ranks currency among stats by metric, highest first. The
+// percentile is the share of other currencies with a lower value.
+func rankCurrency(stats []*AnalyzeRes, currency, metric string) *RankRes {
+	value := rankMetrics[metric]
+	sorted := make([]*AnalyzeRes, len(stats))
+	copy(sorted, stats)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		vi, vj := value(sorted[i]), value(sorted[j])
+		if vi != vj {
+			return vi > vj
+		}
+		return sorted[i].Currency < sorted[j].Currency
+	})
+
+	for i, s := range sorted {
+		if s.Currency != currency {
+			continue
+		}
+		res := &RankRes{
+			Currency: currency,
+			Metric:   metric,
+			Value:    value(s),
+			Rank:     i + 1,
+			Total:    len(sorted),
+		}
+		if res.Total > 1 {
+			res.Percentile = float64(res.Total-res.Rank) / float64(res.Total-1) * 100
+		} else {
+			res.Percentile = 100
+		}
+		return res
+	}
+	return nil
+}
+
+func getRank(c echo.Context) error {
+	currency := strings.ToUpper(c.QueryParam("currency"))
+	if !currencyRe.MatchString(currency) {
+		return c.JSON(http.StatusBadRequest, "invalid currency")
+	}
+	metric := c.QueryParam("metric")
+	if metric == "" {
+		metric = "stddev"
+	}
+	if _, ok := rankMetrics[metric]; !ok {
+		return c.JSON(http.StatusBadRequest, "metric must be one of stddev, spread, avg")
+	}
+	r, err := parseHistoryRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+
+	stats, err := storeFor(c).AnalyzeRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	res := rankCurrency(stats, currency, metric)
+	if res == nil {
+		return c.JSON(http.StatusNotFound, "no data for "+currency+" in range")
+	}
+	res.Range = r
+	return c.JSON(http.StatusOK, res)
+}
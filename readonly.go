@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+var errReadOnly = errors.New("read-only mode, writes are disabled")
+
+// writes marks a route that changes stored data. In read-only mode it
+// answers 403 before the handler runs.
+func writes() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.ReadOnly {
+				return c.JSON(http.StatusForbidden, errReadOnly.Error())
+			}
+			return next(c)
+		}
+	}
+}
+
+// checkWritable backs up writes(): every DB method that writes calls it
+// first, so a write path the routes missed still fails in read-only mode.
+func (p *DB) checkWritable() error {
+	if config.ReadOnly {
+		return errReadOnly
+	}
+	return nil
+}
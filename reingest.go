@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const TriggerDate = "date"
+
+var errNoProviderData = errors.New("the provider has no rates for this date")
+
+// ProviderError is a failure to fetch from the provider, as opposed to one
+// of the store.
+type ProviderError struct {
+	Err error
+}
+
+func (e *ProviderError) Error() string {
+	return e.Err.Error()
+}
+
+const (
+	// ReingestRejected means a post-processor rejected the date.
+	ReingestRejected = "rejected"
+	// ReingestSkipped means nothing was written: the stored document was
+	// unchanged, soft-deleted, outranked or quarantined.
+	ReingestSkipped = "skipped"
+)
+
+type ReingestRes struct {
+	Date   string    `json:"date"`
+	Result string    `json:"result"`
+	Diff   *DateDiff `json:"diff"`
+	RunID  string    `json:"run_id"`
+}
+
+// reingestDate replaces one stored day with the provider's copy from the
+// history file and reports what changed. It holds the same locks as a
+// refresh and saves through persistRates, so post-processors, outlier
+// checks and the SSE broadcast apply. It is recorded as an ingest run with
+// trigger "date".
+func reingestDate(ctx context.Context, store *DB, date string) (res *ReingestRes, err error) {
+	if !refreshMu.TryLock() {
+		return nil, errRefreshRunning
+	}
+	defer refreshMu.Unlock()
+
+	release, err := holdLock(store, "ingest", config.LockTTL)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	run := &IngestRun{
+		ID:        bson.NewObjectId(),
+		Trigger:   TriggerDate,
+		Provider:  "ecb",
+		Status:    RunRunning,
+		StartedAt: time.Now().UTC(),
+		Inserted:  []string{},
+		Updated:   []string{},
+		Empty:     []string{},
+		Rejected:  []string{},
+		Coverage:  []*CoverageChange{},
+	}
+	if err := store.InsertIngestRun(run); err != nil {
+		logCtx(ctx, "reingest, error on InsertIngestRun", err)
+	}
+	defer func() {
+		run.FinishedAt = time.Now().UTC()
+		run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+		if err != nil {
+			run.Status = RunFailed
+			run.Error = err.Error()
+		}
+		if err := store.UpdateIngestRun(run); err != nil {
+			logCtx(ctx, "reingest, error on UpdateIngestRun", err)
+		}
+	}()
+
+	rates, err := fetchECBFull(ctx, ECB_HIST_URL)
+	if err != nil {
+		return nil, &ProviderError{Err: err}
+	}
+
+	var rate *Rate
+	for _, r := range rates {
+		if r.RateDate == date && len(r.Rates) > 0 {
+			rate = r
+		}
+	}
+	if rate == nil {
+		return nil, errNoProviderData
+	}
+	row := &ImportRow{Date: rate.RateDate, Rates: rate.RateMap()}
+	if err := row.Validate(); err != nil {
+		return nil, err
+	}
+
+	old, err := store.FindByDate(date)
+	if err == mgo.ErrNotFound {
+		old, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := persistRates(ctx, store, run, []*Rate{rate}, nil); err != nil {
+		return nil, err
+	}
+
+	res = &ReingestRes{Date: date, Result: ReingestSkipped, RunID: run.ID.Hex()}
+	switch {
+	case len(run.Inserted) > 0:
+		res.Result = SaveInserted
+	case len(run.Updated) > 0:
+		res.Result = SaveUpdated
+	case len(run.Rejected) > 0:
+		res.Result = ReingestRejected
+	}
+	// Diff what is stored now, after Save kept any locked overrides, rather
+	// than what was fetched.
+	stored, err := store.FindByDate(date)
+	switch {
+	case err == mgo.ErrNotFound:
+		// Nothing is stored for the date, so there is nothing to diff.
+	case err != nil:
+		return nil, err
+	default:
+		res.Diff = diffRates(old, stored, 0)
+	}
+	return res, nil
+}
+
+func postAdminRefreshDate(c echo.Context) error {
+	date := c.Param("date")
+	if _, err := time.Parse(DATE_LAYOUT, date); err != nil {
+		return c.JSON(http.StatusBadRequest, "date must be YYYY-MM-DD")
+	}
+
+	res, err := reingestDate(c.Request().Context(), storeFor(c), date)
+	var perr *ProviderError
+	switch {
+	case err == errRefreshRunning, err == errLockHeld:
+		return c.JSON(http.StatusConflict, err.Error())
+	case err == errNoProviderData:
+		return c.JSON(http.StatusNotFound, err.Error())
+	case errors.As(err, &perr):
+		return c.JSON(http.StatusBadGateway, err.Error())
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, res)
+}
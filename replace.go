@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+)
+
+type ReplaceReq struct {
+	Rates map[string]float32 `json:"rates"`
+}
+
+type ReplaceRes struct {
+	Date string    `json:"date"`
+	Diff *DateDiff `json:"diff"`
+}
+
+// missingCurrencies lists the currencies of old that rates does not carry.
+func missingCurrencies(old *Rate, rates map[string]float32) []string {
+	missing := []string{}
+	for _, item := range old.Rates {
+		if _, ok := rates[item.Currency]; !ok {
+			missing = append(missing, item.Currency)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// putDateRate replaces every rate of an existing date in one update. _id,
+// CreatedAt and annotations are kept; locked overrides are replaced like
+// any other value. A body without every stored currency is refused, since
+// a truncated payload would otherwise delete rates.
+func putDateRate(c echo.Context) error {
+	date := c.Param("date")
+	if _, err := time.Parse(DATE_LAYOUT, date); err != nil {
+		return c.JSON(http.StatusBadRequest, "date must be YYYY-MM-DD")
+	}
+	var req ReplaceReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	row := &ImportRow{Date: date, Rates: map[string]float32{}}
+	for code, v := range req.Rates {
+		row.Rates[strings.ToUpper(code)] = v
+	}
+	if errs := row.Problems(); len(errs) > 0 {
+		msgs := []string{}
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		return c.JSON(http.StatusUnprocessableEntity, msgs)
+	}
+
+	store := storeFor(c)
+	old, err := store.FindByDate(date)
+	if err == mgo.ErrNotFound {
+		return c.JSON(http.StatusNotFound, "no rates for "+date+", use POST /rates/import to add a date")
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	if missing := missingCurrencies(old, row.Rates); len(missing) > 0 {
+		return c.JSON(http.StatusUnprocessableEntity, "body lacks "+strings.Join(missing, ", ")+
+			"; send every currency, or use PUT /rates/:date/:currency/override to change one")
+	}
+
+	rate := *old
+	rate.Rates = row.toRate().Rates
+	sort.Slice(rate.Rates, func(i, j int) bool { return rate.Rates[i].Currency < rate.Rates[j].Currency })
+	// nil leaves the stored annotations to Update.
+	rate.Annotations = nil
+	if err := store.Update(&rate); err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	cache.Invalidate()
+	return c.JSON(http.StatusOK, &ReplaceRes{Date: date, Diff: diffRates(old, &rate, 0)})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+const (
+	RoundHalfEven = "half-even"
+	RoundHalfUp   = "half-up"
+	RoundDown     = "down"
+)
+
+// minorUnits lists the ISO 4217 minor units that differ from the usual two.
+var minorUnits = map[string]int{
+	"BHD": 3, "CLP": 0, "IQD": 3, "ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0,
+	"KWD": 3, "LYD": 3, "OMR": 3, "PYG": 0, "TND": 3, "UGX": 0, "VND": 0,
+}
+
+func minorUnit(code string) int {
+	if n, ok := minorUnits[code]; ok {
+		return n
+	}
+	return 2
+}
+
+func validRounding(mode string) bool {
+	return mode == RoundHalfEven || mode == RoundHalfUp || mode == RoundDown
+}
+
+// roundAmount rounds x to places decimals. The value is taken at its shortest
+// decimal representation and rounded exactly, so 2.675 really is a tie rather
+// than 2.67499999... as the binary float would suggest.
+func roundAmount(x float64, places int, mode string) float64 {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(x, 'f', -1, 64))
+	if !ok {
+		return x
+	}
+	neg := r.Sign() < 0
+	r.Abs(r)
+
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)
+	r.Mul(r, new(big.Rat).SetInt(scale))
+
+	q, m := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	// compare the remainder with one half: 2*m vs denom
+	cmp := new(big.Int).Mul(m, big.NewInt(2)).Cmp(r.Denom())
+	switch mode {
+	case RoundHalfUp:
+		if cmp >= 0 {
+			q.Add(q, big.NewInt(1))
+		}
+	case RoundHalfEven:
+		if cmp > 0 || (cmp == 0 && q.Bit(0) == 1) {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+
+	res, _ := new(big.Rat).SetFrac(q, scale).Float64()
+	if neg {
+		res = -res
+	}
+	return res
+}
+
+// parseRounding reads the places and rounding params, defaulting to the
+// target currency's minor unit and half-even.
+func parseRounding(placesParam, mode, to string) (int, string, error) {
+	places := minorUnit(to)
+	if placesParam != "" {
+		n, err := strconv.Atoi(placesParam)
+		if err != nil || n < 0 || n > 10 {
+			return 0, "", fmt.Errorf("places must be between 0 and 10")
+		}
+		places = n
+	}
+	if mode == "" {
+		mode = RoundHalfEven
+	}
+	if !validRounding(mode) {
+		return 0, "", fmt.Errorf("rounding must be one of half-even, half-up, down")
+	}
+	return places, mode, nil
+}
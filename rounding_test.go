@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestRoundAmount(t *testing.T) {
+	tests := []struct {
+		x      float64
+		places int
+		mode   string
+		want   float64
+	}{
+		{2.675, 2, RoundHalfEven, 2.68},
+		{2.665, 2, RoundHalfEven, 2.66},
+		{2.665, 2, RoundHalfUp, 2.67},
+		{2.679, 2, RoundDown, 2.67},
+		{-2.675, 2, RoundHalfUp, -2.68},
+		{-2.679, 2, RoundDown, -2.67},
+		// …005 sits on the boundary in decimal but not in binary; it
+		// must round as the decimal the caller wrote.
+		{1.005, 2, RoundHalfEven, 1},
+		{1.015, 2, RoundHalfEven, 1.02},
+		{1.025, 2, RoundHalfEven, 1.02},
+		{1.005, 2, RoundHalfUp, 1.01},
+		{10.005, 2, RoundHalfUp, 10.01},
+		{-1.005, 2, RoundHalfUp, -1.01},
+		{1.005, 2, RoundDown, 1},
+		{0.005, 2, RoundHalfEven, 0},
+		{0.015, 2, RoundHalfEven, 0.02},
+		{1.0015, 3, RoundHalfEven, 1.002},
+		{2.5, 0, RoundHalfEven, 2},
+		{3.5, 0, RoundHalfEven, 4},
+		{2.5, 0, RoundHalfUp, 3},
+		{1234.5678, 3, RoundHalfEven, 1234.568},
+		{0.1, 2, RoundHalfEven, 0.1},
+	}
+	for _, tt := range tests {
+		if got := roundAmount(tt.x, tt.places, tt.mode); got != tt.want {
+			t.Errorf("roundAmount(%v, %d, %s) = %v, want %v", tt.x, tt.places, tt.mode, got, tt.want)
+		}
+	}
+}
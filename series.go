@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// FindRange returns the documents between start and end inclusive, oldest
+// first. Empty bounds leave that side open. Windows that reach before the
+// archive boundary include archived documents.
+func (p *DB) FindRange(start, end string) ([]Rate, error) {
+	span := p.startSpan("FindRange")
+	defer span.End()
+
+	query := p.bySource(live(nil))
+	dates := bson.M{}
+	if start != "" {
+		dates["$gte"] = start
+	}
+	if end != "" {
+		dates["$lte"] = end
+	}
+	if len(dates) > 0 {
+		query["rate_date"] = dates
+	}
+
+	rates := []Rate{}
+	if err := db.C(config.Collection).Find(query).Sort("rate_date").All(&rates); err != nil {
+		return rates, err
+	}
+	if p.crossesArchive(start) {
+		delete(query, "deleted_at")
+		archived, err := p.findArchived(query)
+		if err != nil {
+			return rates, err
+		}
+		rates = mergeArchived(rates, archived)
+	}
+	return rates, nil
+}
+
+// FindWindow returns the newest window documents, or all of them when
+// window is 0, sorted by date ascending.
+func (p *DB) FindWindow(window int) ([]Rate, error) {
+	if window == 0 {
+		return p.FindRange("", "")
+	}
+	rates, err := p.FindRecent(window)
+	// FindRecent returns the newest date first.
+	for i, j := 0, len(rates)-1; i < j; i, j = i+1, j-1 {
+		rates[i], rates[j] = rates[j], rates[i]
+	}
+	return rates, err
+}
+
+// FindNearest returns the stored document closest to date, preferring the
+// earlier one on a tie. Archived documents count as well.
+func (p *DB) FindNearest(date string) (*Rate, error) {
+	span := p.startSpan("FindNearest")
+	defer span.End()
+
+	target, err := time.Parse(DATE_LAYOUT, date)
+	if err != nil {
+		return nil, err
+	}
+
+	before, errBefore := p.findEdge(bson.M{"rate_date": bson.M{"$lte": date}}, "-rate_date", p.crossesArchive(""))
+	after, errAfter := p.findEdge(bson.M{"rate_date": bson.M{"$gt": date}}, "rate_date", p.crossesArchive(date))
+	switch {
+	case errBefore != nil && errAfter != nil:
+		return nil, errBefore
+	case errAfter != nil:
+		return before, nil
+	case errBefore != nil:
+		return after, nil
+	}
+
+	b, _ := time.Parse(DATE_LAYOUT, before.RateDate)
+	a, _ := time.Parse(DATE_LAYOUT, after.RateDate)
+	if a.Sub(target) < target.Sub(b) {
+		return after, nil
+	}
+	return before, nil
+}
+
+// FindAt returns the rates in effect at ts: the latest intraday point at or
+// before ts, unless a daily document of a later date supersedes it. A daily
+// document counts for its whole day; see pickAt.
+func (p *DB) FindAt(ts time.Time) (*Rate, error) {
+	span := p.startSpan("FindAt")
+	defer span.End()
+
+	ts = ts.UTC()
+	date := ts.Format(DATE_LAYOUT)
+	query := p.bySource(bson.M{"rate_date": bson.M{"$lte": date}})
+
+	var daily *Rate
+	rate, err := p.findEdge(query, "-rate_date", p.crossesArchive(""))
+	switch {
+	case err == nil:
+		daily = rate
+	case err != mgo.ErrNotFound:
+		return nil, err
+	}
+
+	intraday, err := p.FindIntradayAt(ts)
+	switch {
+	case err == mgo.ErrNotFound:
+		intraday = nil
+	case err != nil:
+		return nil, err
+	}
+
+	if res := pickAt(daily, intraday); res != nil {
+		return res, nil
+	}
+	return nil, mgo.ErrNotFound
+}
+
+// FindBefore returns the latest non-empty document strictly before date.
+func (p *DB) FindBefore(date string) (*Rate, error) {
+	span := p.startSpan("FindBefore")
+	defer span.End()
+
+	var rate Rate
+	err := db.C(config.Collection).Find(live(bson.M{
+		"rate_date": bson.M{"$lt": date},
+		"rates.0":   bson.M{"$exists": true},
+	})).Sort("-rate_date").One(&rate)
+	return &rate, err
+}
+
+// Basket is a set of currency weights. A single currency is a basket with
+// one component of weight 1.
+type Basket map[string]float64
+
+// parseBasket accepts either a currency code or "USD:0.5,GBP:0.5".
+func parseBasket(s string) (Basket, error) {
+	basket := Basket{}
+	for _, part := range parseList(s) {
+		code, weight := part, 1.0
+		if i := strings.Index(part, ":"); i >= 0 {
+			code = part[:i]
+			w, err := strconv.ParseFloat(part[i+1:], 64)
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf("invalid weight in %q", part)
+			}
+			weight = w
+		}
+		code = strings.ToUpper(code)
+		if !currencyRe.MatchString(code) {
+			return nil, fmt.Errorf("invalid currency code %q", code)
+		}
+		basket[code] = weight
+	}
+	if len(basket) == 0 {
+		return nil, fmt.Errorf("basket is empty")
+	}
+	return basket, nil
+}
+
+// basketReturn is the weighted sum of the component returns between two days.
+// ok is false when a component is missing on either day.
+func basketReturn(basket Basket, prev, cur map[string]float32) (float64, bool) {
+	ret := 0.0
+	total := 0.0
+	for code, w := range basket {
+		pr, ok1 := prev[code]
+		c, ok2 := cur[code]
+		if !ok1 || !ok2 || pr == 0 {
+			return 0, false
+		}
+		ret += w * (float64(c)/float64(pr) - 1)
+		total += w
+	}
+	return ret / total, true
+}
+
+// AlignedReturns holds the daily returns of two baskets on the dates where
+// both could be computed.
+type AlignedReturns struct {
+	Dates []string
+	A     []float64
+	B     []float64
+}
+
+func alignReturns(rates []Rate, a, b Basket) *AlignedReturns {
+	res := &AlignedReturns{}
+	for i := 1; i < len(rates); i++ {
+		prev, cur := rates[i-1].RateMap(), rates[i].RateMap()
+		ra, ok := basketReturn(a, prev, cur)
+		if !ok {
+			continue
+		}
+		rb, ok := basketReturn(b, prev, cur)
+		if !ok {
+			continue
+		}
+		res.Dates = append(res.Dates, rates[i].RateDate)
+		res.A = append(res.A, ra)
+		res.B = append(res.B, rb)
+	}
+	return res
+}
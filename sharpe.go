@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+// TRADING_DAYS_PER_YEAR annualizes daily return statistics.
+const TRADING_DAYS_PER_YEAR = 252
+
+type SharpeRes struct {
+	Currency   string     `json:"currency"`
+	Sharpe     float64    `json:"sharpe"`
+	Mean       float64    `json:"mean"`
+	Stddev     float64    `json:"stddev"`
+	RiskFree   float64    `json:"rf"`
+	SampleSize int        `json:"sample_size"`
+	Range      *DateRange `json:"range"`
+}
+
+// sharpe computes the annualized Sharpe-like ratio of currency's daily
+// returns over rates (sorted by date ascending). rf is an annual risk-free
+// rate as a fraction, spread evenly over TRADING_DAYS_PER_YEAR. Mean and
+// Stddev are the daily figures. Sharpe stays 0 with fewer than
+// MIN_OBSERVATIONS returns or when they have zero variance.
+func sharpe(rates []Rate, currency string, rf float64) *SharpeRes {
+	res := &SharpeRes{Currency: currency, RiskFree: rf, Range: &DateRange{}}
+	returns := []float64{}
+	basket := Basket{currency: 1}
+	for i := 1; i < len(rates); i++ {
+		ret, ok := basketReturn(basket, rates[i-1].RateMap(), rates[i].RateMap())
+		if !ok {
+			continue
+		}
+		if res.Range.Start == "" {
+			res.Range.Start = rates[i-1].RateDate
+		}
+		res.Range.End = rates[i].RateDate
+		returns = append(returns, ret)
+	}
+	res.SampleSize = len(returns)
+	if len(returns) < MIN_OBSERVATIONS {
+		return res
+	}
+
+	res.Mean = mean(returns)
+	res.Stddev = stddev(returns)
+	if res.Stddev == 0 {
+		return res
+	}
+	excess := res.Mean - rf/TRADING_DAYS_PER_YEAR
+	res.Sharpe = excess / res.Stddev * math.Sqrt(TRADING_DAYS_PER_YEAR)
+	return res
+}
+
+func getSharpe(c echo.Context) error {
+	currency := strings.ToUpper(c.QueryParam("currency"))
+	if !currencyRe.MatchString(currency) {
+		return c.JSON(http.StatusBadRequest, "invalid currency")
+	}
+	rf := 0.0
+	if v := c.QueryParam("rf"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return c.JSON(http.StatusBadRequest, "rf must be a number")
+		}
+		rf = f
+	}
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	res := sharpe(rates, currency, rf)
+	if res.SampleSize < MIN_OBSERVATIONS {
+		return c.JSON(http.StatusUnprocessableEntity, "not enough observations")
+	}
+	if res.Stddev == 0 {
+		return c.JSON(http.StatusUnprocessableEntity, "returns have zero variance")
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SoftDelete marks the live document of date as deleted so every read skips
+// it while it can still be restored.
+func (p *DB) SoftDelete(date string) error {
+	span := p.startSpan("SoftDelete")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	return db.C(config.Collection).Update(live(bson.M{"rate_date": date}),
+		bson.M{"$set": bson.M{"deleted_at": time.Now().UTC(), "updated_at": time.Now().UTC()}})
+}
+
+func (p *DB) Restore(date string) error {
+	span := p.startSpan("Restore")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return err
+	}
+
+	return db.C(config.Collection).Update(
+		bson.M{"rate_date": date, "deleted_at": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"deleted_at": ""}, "$set": bson.M{"updated_at": time.Now().UTC()}})
+}
+
+func (p *DB) HardDelete(date string) (int, error) {
+	span := p.startSpan("HardDelete")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	info, err := db.C(config.Collection).RemoveAll(bson.M{"rate_date": date})
+	if err != nil {
+		return 0, err
+	}
+	return info.Removed, nil
+}
+
+func (p *DB) FindDeleted() ([]Rate, error) {
+	span := p.startSpan("FindDeleted")
+	defer span.End()
+
+	rates := []Rate{}
+	err := db.C(config.Collection).Find(bson.M{"deleted_at": bson.M{"$exists": true}}).Sort("rate_date").All(&rates)
+	return rates, err
+}
+
+// Purge permanently removes documents soft-deleted before cutoff.
+func (p *DB) Purge(cutoff time.Time) (int, error) {
+	span := p.startSpan("Purge")
+	defer span.End()
+
+	if err := p.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	info, err := db.C(config.Collection).RemoveAll(bson.M{"deleted_at": bson.M{"$lt": cutoff}})
+	if err != nil {
+		return 0, err
+	}
+	return info.Removed, nil
+}
+
+// deleteRate soft-deletes the date's document, or removes it for good with
+// ?hard=true.
+func deleteRate(c echo.Context) error {
+	date := c.Param("date")
+	if _, err := time.Parse(DATE_LAYOUT, date); err != nil {
+		return c.JSON(http.StatusBadRequest, "date must be YYYY-MM-DD")
+	}
+	hard, _ := strconv.ParseBool(c.QueryParam("hard"))
+
+	store := storeFor(c)
+	if hard {
+		n, err := store.HardDelete(date)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+		if n == 0 {
+			return c.JSON(http.StatusNotFound, "no rates for "+date)
+		}
+	} else {
+		err := store.SoftDelete(date)
+		if err == mgo.ErrNotFound {
+			return c.JSON(http.StatusNotFound, "no rates for "+date)
+		}
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, err.Error())
+		}
+	}
+	cache.Invalidate()
+	return c.NoContent(http.StatusNoContent)
+}
+
+func getDeleted(c echo.Context) error {
+	rates, err := storeFor(c).FindDeleted()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, rates)
+}
+
+func postRestore(c echo.Context) error {
+	date := c.Param("date")
+	err := storeFor(c).Restore(date)
+	if err == mgo.ErrNotFound {
+		return c.JSON(http.StatusNotFound, "no deleted rates for "+date)
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, err.Error())
+	}
+	cache.Invalidate()
+	return c.NoContent(http.StatusNoContent)
+}
+
+// runPurge is the purge command: it removes documents soft-deleted more than
+// days ago.
+func runPurge(w io.Writer, days int) int {
+	if err := p.Open(); err != nil {
+		fmt.Fprintln(w, "purge:", err)
+		return 1
+	}
+	cutoff := time.Now().UTC().AddDate(0, 0, -days)
+	n, err := p.Purge(cutoff)
+	if err != nil {
+		fmt.Fprintln(w, "purge:", err)
+		return 1
+	}
+	fmt.Fprintf(w, "purged %d documents deleted before %s\n", n, cutoff.Format(time.RFC3339))
+	return 0
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo"
+)
+
+type SortedRate struct {
+	Currency string  `json:"currency"`
+	Rate     float32 `json:"rate"`
+}
+
+type SortedRatesRes struct {
+	Base  string        `json:"base"`
+	Date  string        `json:"date"`
+	Dir   string        `json:"dir"`
+	Rates []*SortedRate `json:"rates"`
+}
+
+// sortRates orders rates by value, highest first unless asc. Equal rates
+// keep currency order either way.
+func sortRates(rates map[string]float32, asc bool) []*SortedRate {
+	res := make([]*SortedRate, 0, len(rates))
+	for code, rate := range rates {
+		res = append(res, &SortedRate{Currency: code, Rate: rate})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Rate != res[j].Rate {
+			return (res[i].Rate < res[j].Rate) == asc
+		}
+		return res[i].Currency < res[j].Currency
+	})
+	return res
+}
+
+func getLatestSorted(c echo.Context) error {
+	dir := c.QueryParam("dir")
+	if dir == "" {
+		dir = "desc"
+	}
+	if dir != "asc" && dir != "desc" {
+		return c.JSON(http.StatusBadRequest, "dir must be asc or desc")
+	}
+
+	latest, err := cachedLatest(storeFor(c))
+	if err != nil {
+		log.Println("getLatestSorted, error on GetLatest", err)
+		return c.JSON(http.StatusBadRequest, nil)
+	}
+	setLastModified(c, latest.LastModified)
+	return c.JSON(http.StatusOK, &SortedRatesRes{
+		Base:  latest.Base,
+		Date:  latest.Date,
+		Dir:   dir,
+		Rates: sortRates(latest.Rates, dir == "asc"),
+	})
+}
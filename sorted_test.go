@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestSortRates(t *testing.T) {
+	rates := map[string]float32{"USD": 1.1, "JPY": 120, "GBP": 0.9, "CHF": 1.1}
+	tests := []struct {
+		asc  bool
+		want []string
+	}{
+		{false, []string{"JPY", "CHF", "USD", "GBP"}},
+		{true, []string{"GBP", "CHF", "USD", "JPY"}},
+	}
+	for _, tt := range tests {
+		got := sortRates(rates, tt.asc)
+		if len(got) != len(tt.want) {
+			t.Fatalf("asc=%v: got %d rates, want %d", tt.asc, len(got), len(tt.want))
+		}
+		for i, code := range tt.want {
+			if got[i].Currency != code {
+				t.Errorf("asc=%v: position %d = %s, want %s", tt.asc, i, got[i].Currency, code)
+			}
+		}
+	}
+}
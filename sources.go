@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// knownSources are the values of Rate.Source.
+var knownSources = []string{"ecb", "import", "seed"}
+
+func isKnownSource(source string) bool {
+	for _, s := range knownSources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceRank is the position of source in precedence; sources that are not
+// listed rank after every listed one.
+func sourceRank(source string, precedence []string) int {
+	if source == "" {
+		source = "ecb"
+	}
+	for i, s := range precedence {
+		if s == source {
+			return i
+		}
+	}
+	return len(precedence)
+}
+
+// sourceWins reports whether a document from incoming may replace one
+// stored from stored. A date holds a single document, so precedence is
+// decided here, when writing: a source only gives way to one ranked at
+// least as high, and a source always refreshes its own documents.
+func sourceWins(incoming, stored string, precedence []string) bool {
+	return sourceRank(incoming, precedence) <= sourceRank(stored, precedence)
+}
+
+// WithSource returns a store whose date and history reads only return
+// documents from source.
+func (p *DB) WithSource(source string) *DB {
+	return &DB{ctx: p.ctx, source: source}
+}
+
+// bySource narrows a rates query to the store's source. Documents written
+// before Rate.Source existed count as ecb.
+func (p *DB) bySource(query bson.M) bson.M {
+	switch p.source {
+	case "":
+	case "ecb":
+		query["source"] = bson.M{"$in": []interface{}{"ecb", nil}}
+	default:
+		query["source"] = p.source
+	}
+	return query
+}
+
+// sourceParam validates ?source= and pins storeFor to it.
+func sourceParam() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			source := c.QueryParam("source")
+			if source != "" && !isKnownSource(source) {
+				return c.JSON(http.StatusBadRequest, "source must be one of ecb, import, seed")
+			}
+			c.Set("source", source)
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestSourceWins(t *testing.T) {
+	precedence := []string{"import", "ecb", "seed"}
+	tests := []struct {
+		incoming, stored string
+		want             bool
+	}{
+		{"ecb", "import", false},
+		{"import", "ecb", true},
+		{"ecb", "ecb", true},
+		{"ecb", "", true},
+		{"seed", "ecb", false},
+		{"ecb", "seed", true},
+		{"import", "unknown", true},
+		{"unknown", "seed", false},
+	}
+	for _, tt := range tests {
+		if got := sourceWins(tt.incoming, tt.stored, precedence); got != tt.want {
+			t.Errorf("sourceWins(%q, %q) = %v, want %v", tt.incoming, tt.stored, got, tt.want)
+		}
+	}
+}
+
+func TestSourceRank(t *testing.T) {
+	precedence := []string{"ecb", "import", "seed"}
+	tests := []struct {
+		source string
+		want   int
+	}{
+		{"ecb", 0},
+		{"", 0},
+		{"import", 1},
+		{"seed", 2},
+		{"other", 3},
+	}
+	for _, tt := range tests {
+		if got := sourceRank(tt.source, precedence); got != tt.want {
+			t.Errorf("sourceRank(%q) = %d, want %d", tt.source, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+// getSSE streams an "update" event with the latest DailyRate whenever a
+// refresh ingests a new date, plus comment heartbeats to keep proxies from
+// closing idle connections.
+func getSSE(c echo.Context) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	ch := broadcaster.Subscribe()
+	defer broadcaster.Unsubscribe(ch)
+
+	heartbeat := time.NewTicker(config.SSEHeartbeat)
+	defer heartbeat.Stop()
+
+	ctx := c.Request().Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case rate := <-ch:
+			b, err := json.Marshal(rate)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(res, "event: update\ndata: %s\n\n", b); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
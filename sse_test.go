@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+func TestSSE(t *testing.T) {
+	defer func(prev time.Duration) { config.SSEHeartbeat = prev }(config.SSEHeartbeat)
+	config.SSEHeartbeat = 20 * time.Millisecond
+
+	e := echo.New()
+	e.GET("/rates/sse", getSSE)
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/rates/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	lines, done := make(chan string), make(chan struct{})
+	defer close(done)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+	next := func() string {
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event within 2s")
+			return ""
+		}
+	}
+
+	// The first heartbeat means the handler has subscribed.
+	for next() != ": heartbeat" {
+	}
+	broadcaster.Publish(&DailyRate{Base: "EUR", Date: "2024-01-31", Rates: map[string]float32{"USD": 1.0837}})
+	for {
+		if line := next(); line == "event: update" {
+			break
+		}
+	}
+	if data := next(); !strings.HasPrefix(data, "data: ") || !strings.Contains(data, `"date":"2024-01-31"`) {
+		t.Errorf("update data = %q, want the published rate", data)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// percentChange returns the change from prev to cur in percent.
+func percentChange(prev, cur float32) float64 {
+	if prev == 0 {
+		return 0
+	}
+	return (float64(cur) - float64(prev)) / float64(prev) * 100
+}
+
+const (
+	UnitPct = "pct"
+	UnitBps = "bps"
+)
+
+// inUnit expresses a percent change in unit, rounded to a hundredth of a
+// basis point either way. 1 bp is 0.01%.
+func inUnit(pct float64, unit string) float64 {
+	if unit == UnitBps {
+		return roundAmount(pct*100, 2, RoundHalfEven)
+	}
+	return roundAmount(pct, 4, RoundHalfEven)
+}
+
+// MIN_OBSERVATIONS is the smallest sample the statistical endpoints accept.
+const MIN_OBSERVATIONS = 5
+
+func mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// covariance is the sample covariance of two equally long series.
+func covariance(xs, ys []float64) float64 {
+	if len(xs) < 2 || len(xs) != len(ys) {
+		return 0
+	}
+	mx, my := mean(xs), mean(ys)
+	sum := 0.0
+	for i := range xs {
+		sum += (xs[i] - mx) * (ys[i] - my)
+	}
+	return sum / float64(len(xs)-1)
+}
+
+func variance(xs []float64) float64 {
+	return covariance(xs, xs)
+}
+
+func stddev(xs []float64) float64 {
+	return math.Sqrt(variance(xs))
+}
+
+// correlation is the Pearson correlation of two equally long series, or 0
+// when either has no variance.
+func correlation(xs, ys []float64) float64 {
+	sx, sy := stddev(xs), stddev(ys)
+	if sx == 0 || sy == 0 {
+		return 0
+	}
+	return covariance(xs, ys) / (sx * sy)
+}
+
+// ranks returns the 1-based rank of each value, giving ties the average of
+// the ranks they span.
+func ranks(xs []float64) []float64 {
+	order := make([]int, len(xs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return xs[order[a]] < xs[order[b]] })
+	res := make([]float64, len(xs))
+	for i := 0; i < len(order); {
+		j := i
+		for j+1 < len(order) && xs[order[j+1]] == xs[order[i]] {
+			j++
+		}
+		avg := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			res[order[k]] = avg
+		}
+		i = j + 1
+	}
+	return res
+}
+
+// spearman is the rank correlation of two equally long series: the Pearson
+// correlation of their ranks.
+func spearman(xs, ys []float64) float64 {
+	return correlation(ranks(xs), ranks(ys))
+}
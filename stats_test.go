@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPercentChange(t *testing.T) {
+	tests := []struct {
+		prev, cur float32
+		want      float64
+	}{
+		{1, 1.1, 10},
+		{2, 1, -50},
+		{1, 1, 0},
+		{0, 5, 0},
+	}
+	for _, tt := range tests {
+		if got := percentChange(tt.prev, tt.cur); math.Abs(got-tt.want) > 1e-5 {
+			t.Errorf("percentChange(%v, %v) = %v, want %v", tt.prev, tt.cur, got, tt.want)
+		}
+	}
+}
+
+func TestRanks(t *testing.T) {
+	got := ranks([]float64{3, 1, 2, 2})
+	want := []float64{4, 1, 2.5, 2.5}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ranks = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestSpearman(t *testing.T) {
+	tests := []struct {
+		name   string
+		xs, ys []float64
+		want   float64
+	}{
+		{"monotonic", []float64{1, 2, 3, 4, 5}, []float64{1, 4, 9, 16, 25}, 1},
+		{"reversed", []float64{1, 2, 3, 4, 5}, []float64{50, 40, 30, 20, 10}, -1},
+		{"constant", []float64{1, 2, 3}, []float64{7, 7, 7}, 0},
+		{"mixed", []float64{1, 2, 3, 4, 5}, []float64{2, 1, 4, 3, 5}, 0.8},
+	}
+	for _, tt := range tests {
+		if got := spearman(tt.xs, tt.ys); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: spearman = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInUnit(t *testing.T) {
+	tests := []struct {
+		pct  float64
+		unit string
+		want float64
+	}{
+		{1, UnitBps, 100},
+		{1, UnitPct, 1},
+		{-0.25, UnitBps, -25},
+		{0.123456, UnitPct, 0.1235},
+		{0.123456, UnitBps, 12.35},
+	}
+	for _, tt := range tests {
+		if got := inUnit(tt.pct, tt.unit); got != tt.want {
+			t.Errorf("inUnit(%v, %s) = %v, want %v", tt.pct, tt.unit, got, tt.want)
+		}
+	}
+}
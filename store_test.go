@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"gopkg.in/mgo.v2"
+)
+
+// testStore points the package at a throwaway database on the Mongo at
+// MONGO_TEST_HOST, dropped when the test ends. Without it the test is
+// skipped.
+func testStore(t *testing.T) *DB {
+	host := os.Getenv("MONGO_TEST_HOST")
+	if host == "" {
+		t.Skip("MONGO_TEST_HOST is not set")
+	}
+	session, err := mgo.Dial(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev, prevReady := db, dbReady.Load()
+	db = session.DB(fmt.Sprintf("currencyrate_test_%d", time.Now().UnixNano()))
+	dbReady.Store(true)
+	t.Cleanup(func() {
+		db.DropDatabase()
+		db = prev
+		dbReady.Store(prevReady)
+		session.Close()
+	})
+	return p
+}
+
+// testECB sends every ECB request to a server serving body, through a
+// fresh breaker.
+func testECB(t *testing.T, body string) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	target, _ := url.Parse(srv.URL)
+	prev, prevBreaker := ecbClient, ecbBreaker
+	ecbClient = &http.Client{Transport: rewriteHost{target}}
+	ecbBreaker = NewCircuitBreaker("ecb", 3, time.Minute)
+	t.Cleanup(func() {
+		ecbClient, ecbBreaker = prev, prevBreaker
+		srv.Close()
+	})
+}
+
+type rewriteHost struct {
+	target *url.URL
+}
+
+func (rt rewriteHost) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = rt.target.Scheme, rt.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
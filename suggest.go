@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UnknownCurrencyError reports a from/to code that is malformed or not quoted
+// on the date being converted.
+type UnknownCurrencyError struct {
+	Field string
+	Code  string
+}
+
+func (e *UnknownCurrencyError) Error() string {
+	return fmt.Sprintf("unknown %s currency %q", e.Field, e.Code)
+}
+
+// ErrorEnvelope is the error body for failures that carry extra detail.
+type ErrorEnvelope struct {
+	Error   string      `json:"error"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+type UnknownCurrencyDetails struct {
+	Field      string   `json:"field"`
+	Code       string   `json:"code"`
+	Suggestion string   `json:"suggestion,omitempty"`
+	Valid      []string `json:"valid"`
+}
+
+func validCodes(rate *Rate) []string {
+	codes := []string{"EUR"}
+	for _, item := range rate.Rates {
+		codes = append(codes, item.Currency)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// suggestCurrency picks the closest valid code: a code starting with the
+// input ("US" -> USD), one the input starts with ("EURO" -> EUR), or failing
+// that the nearest by edit distance within two edits.
+func suggestCurrency(code string, valid []string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return ""
+	}
+	for _, v := range valid {
+		if strings.HasPrefix(v, code) || strings.HasPrefix(code, v) {
+			return v
+		}
+	}
+
+	best, bestDist := "", 3
+	for _, v := range valid {
+		if d := editDistance(code, v); d < bestDist {
+			best, bestDist = v, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func unknownCurrencyEnvelope(err *UnknownCurrencyError, rate *Rate) *ErrorEnvelope {
+	valid := validCodes(rate)
+	return &ErrorEnvelope{
+		Error: err.Error(),
+		Details: &UnknownCurrencyDetails{
+			Field:      err.Field,
+			Code:       err.Code,
+			Suggestion: suggestCurrency(err.Code, valid),
+			Valid:      valid,
+		},
+	}
+}
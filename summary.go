@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type SummaryRes struct {
+	Documents     int         `json:"documents"`
+	Currencies    int         `json:"currencies"`
+	Span          *DateRange  `json:"span"`
+	LatestDate    string      `json:"latest_date"`
+	MostVolatile  *AnalyzeRes `json:"most_volatile"`
+	LeastVolatile *AnalyzeRes `json:"least_volatile"`
+}
+
+// FirstDate returns the oldest stored rate_date.
+func (p *DB) FirstDate() (string, error) {
+	span := p.startSpan("FirstDate")
+	defer span.End()
+
+	var rate Rate
+	err := db.C(config.Collection).Find(live(nil)).Select(bson.M{"rate_date": 1}).Sort("rate_date").One(&rate)
+	return rate.RateDate, err
+}
+
+func buildSummary(store *DB) (*SummaryRes, error) {
+	res := &SummaryRes{}
+	var err error
+	if res.Documents, err = store.Count(); err != nil {
+		return nil, err
+	}
+	if res.Documents == 0 {
+		return res, nil
+	}
+
+	first, err := store.FirstDate()
+	if err != nil {
+		return nil, err
+	}
+	latest, err := store.GetLatest()
+	if err != nil && err != mgo.ErrNotFound {
+		return nil, err
+	}
+	res.LatestDate = latest.RateDate
+	res.Span = &DateRange{Start: first, End: latest.RateDate}
+
+	stats, err := store.Analyze()
+	if err != nil {
+		return nil, err
+	}
+	res.Currencies = len(stats)
+	for _, s := range stats {
+		// Stddev needs two observations to mean anything.
+		if s.Count < 2 {
+			continue
+		}
+		if res.MostVolatile == nil || s.Stddev > res.MostVolatile.Stddev {
+			res.MostVolatile = s
+		}
+		if res.LeastVolatile == nil || s.Stddev < res.LeastVolatile.Stddev {
+			res.LeastVolatile = s
+		}
+	}
+	return res, nil
+}
+
+func getSummary(c echo.Context) error {
+	if res := cache.Summary(); res != nil {
+		return c.JSON(http.StatusOK, res)
+	}
+
+	res, err := buildSummary(storeFor(c))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	cache.SetSummary(res)
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type InvoiceLine struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+	Date     string  `json:"date"`
+}
+
+type TotalReq struct {
+	To    string         `json:"to"`
+	Lines []*InvoiceLine `json:"lines"`
+}
+
+type TotalRes struct {
+	To        string        `json:"to"`
+	Total     float64       `json:"total"`
+	Places    int           `json:"places"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Lines     []*ItemResult `json:"lines"`
+}
+
+// postConvertTotal converts each line at its own date's rate and sums the
+// rounded results. Failed lines are reported and left out of the total;
+// with ?strict=true any failure fails the whole request with 422.
+func postConvertTotal(c echo.Context) error {
+	strict := false
+	if v := c.QueryParam("strict"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, "strict must be a boolean")
+		}
+		strict = b
+	}
+	req := &TotalReq{}
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if len(req.Lines) == 0 {
+		return c.JSON(http.StatusBadRequest, "at least one line is required")
+	}
+
+	to := strings.ToUpper(strings.TrimSpace(req.To))
+	if !currencyRe.MatchString(to) {
+		return c.JSON(http.StatusBadRequest, "to must be a currency code")
+	}
+	places, _, _ := parseRounding("", "", to)
+
+	byDate := newRatesByDate(storeFor(c))
+	res := &TotalRes{To: to, Places: places, Lines: []*ItemResult{}}
+	sum := 0.0
+	for i, line := range req.Lines {
+		conv := &ConvertReq{From: line.Currency, To: to, Amount: line.Amount, Date: line.Date}
+		if err := conv.normalize(); err != nil {
+			res.Lines = append(res.Lines, itemError(i, http.StatusBadRequest, err))
+			continue
+		}
+		rate, err := byDate.Get(conv.Date)
+		if err != nil {
+			res.Lines = append(res.Lines, itemError(i, http.StatusNotFound, err))
+			continue
+		}
+		converted, err := convert(rate, conv)
+		if err != nil {
+			res.Lines = append(res.Lines, itemError(i, http.StatusUnprocessableEntity, err))
+			continue
+		}
+		sum += converted.Result
+		res.Lines = append(res.Lines, &ItemResult{Index: i, Status: http.StatusOK, Result: converted})
+	}
+	_, batch := newBatchRes(res.Lines)
+	res.Succeeded, res.Failed = batch.Succeeded, batch.Failed
+
+	if res.Failed > 0 && (strict || res.Succeeded == 0) {
+		return c.JSON(http.StatusUnprocessableEntity, res)
+	}
+	res.Total = roundAmount(sum, places, RoundHalfEven)
+	return c.JSON(http.StatusOK, res)
+}
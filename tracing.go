@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/labstack/echo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const traceIDHeader = "X-Trace-Id"
+
+// logFormat is the access log line; the trace id is copied onto the request
+// by tracingMiddleware so a slow request can be looked up in the tracer.
+const logFormat = `{"time":"${time_rfc3339_nano}","trace_id":"${header:X-Trace-Id}",` +
+	`"remote_ip":"${remote_ip}","method":"${method}","uri":"${uri}","status":${status},` +
+	`"latency_human":"${latency_human}","bytes_out":${bytes_out}}` + "\n"
+
+var tracer = otel.Tracer("currencyrate")
+
+// initTracing installs an OTLP exporter when OTEL_EXPORTER_OTLP_ENDPOINT is
+// set. Without it the global no-op provider stays in place.
+func initTracing() func(context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if config.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.OTLPEndpoint)}
+	if config.OTLPInsecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(tp)
+	tracer = tp.Tracer("currencyrate")
+	return tp.Shutdown
+}
+
+func tracingMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			ctx := otel.GetTextMapPropagator().Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+			ctx, span := tracer.Start(ctx, req.Method+" "+c.Path(), trace.WithSpanKind(trace.SpanKindServer))
+			defer span.End()
+
+			if sc := span.SpanContext(); sc.IsValid() {
+				req.Header.Set(traceIDHeader, sc.TraceID().String())
+				c.Response().Header().Set(traceIDHeader, sc.TraceID().String())
+			}
+			c.SetRequest(req.WithContext(ctx))
+
+			err := next(c)
+			if err != nil {
+				span.RecordError(err)
+			}
+			status := c.Response().Status
+			span.SetAttributes(
+				attribute.String("http.method", req.Method),
+				attribute.String("http.route", c.Path()),
+				attribute.Int("http.status_code", status),
+			)
+			if status >= 500 {
+				span.SetStatus(codes.Error, "")
+			}
+			return err
+		}
+	}
+}
+
+func (p *DB) startSpan(name string) trace.Span {
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, span := tracer.Start(ctx, "DB."+name)
+	return span
+}
+
+// storeFor returns the store for a request, pinned to ?source= on routes
+// behind sourceParam.
+func storeFor(c echo.Context) *DB {
+	store := p.WithContext(c.Request().Context())
+	if source, _ := c.Get("source").(string); source != "" {
+		return store.WithSource(source)
+	}
+	return store
+}
+
+// logCtx is log.Println prefixed with the trace id carried by ctx.
+func logCtx(ctx context.Context, v ...interface{}) {
+	if sc := trace.SpanFromContext(ctx).SpanContext(); sc.IsValid() {
+		v = append([]interface{}{"trace_id=" + sc.TraceID().String()}, v...)
+	}
+	log.Println(v...)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer func(prev trace.Tracer) { tracer = prev }(tracer)
+	tracer = tp.Tracer("currencyrate")
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	e := echo.New()
+	e.Use(tracingMiddleware())
+	e.GET("/rates/:date", func(c echo.Context) error {
+		storeFor(c).startSpan("FindByDate").End()
+		return c.NoContent(http.StatusOK)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/rates/2024-01-31", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(traceIDHeader); got != traceID {
+		t.Errorf("%s = %q, want %q", traceIDHeader, got, traceID)
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	db, server := spans[0], spans[1]
+	if server.Name != "GET /rates/:date" || db.Name != "DB.FindByDate" {
+		t.Fatalf("span names = %q, %q", server.Name, db.Name)
+	}
+	if db.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("DB span is not a child of the request span")
+	}
+	if server.SpanContext.TraceID().String() != traceID {
+		t.Errorf("request span trace id = %s, want the incoming %s", server.SpanContext.TraceID(), traceID)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+)
+
+type TrackingErrorReq struct {
+	Currency string `json:"currency"`
+	Weights  Basket `json:"weights"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+}
+
+type TrackingErrorRes struct {
+	Currency      string     `json:"currency"`
+	Weights       Basket     `json:"weights"`
+	TrackingError float64    `json:"tracking_error"`
+	Annualized    float64    `json:"annualized"`
+	SampleSize    int        `json:"sample_size"`
+	Range         *DateRange `json:"range"`
+}
+
+// trackingError is the sample stddev of the daily return differences
+// between currency and the basket on the dates both have returns.
+func trackingError(rates []Rate, currency string, weights Basket) (float64, *AlignedReturns) {
+	aligned := alignReturns(rates, Basket{currency: 1}, weights)
+	diffs := make([]float64, len(aligned.A))
+	for i := range aligned.A {
+		diffs[i] = aligned.A[i] - aligned.B[i]
+	}
+	return stddev(diffs), aligned
+}
+
+func postTrackingError(c echo.Context) error {
+	var req TrackingErrorReq
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	currency := strings.ToUpper(req.Currency)
+	if !currencyRe.MatchString(currency) {
+		return c.JSON(http.StatusBadRequest, "invalid currency")
+	}
+	basket := &BasketReq{Weights: req.Weights}
+	if err := basket.normalize(); err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	r, err := newDateRange(req.Start, req.End)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	te, aligned := trackingError(rates, currency, basket.Weights)
+	if len(aligned.A) < MIN_OBSERVATIONS {
+		return c.JSON(http.StatusUnprocessableEntity, "not enough overlapping observations")
+	}
+
+	res := &TrackingErrorRes{
+		Currency:      currency,
+		Weights:       basket.Weights,
+		TrackingError: te,
+		Annualized:    te * math.Sqrt(TRADING_DAYS_PER_YEAR),
+		SampleSize:    len(aligned.A),
+		Range: &DateRange{
+			Start: aligned.Dates[0],
+			End:   aligned.Dates[len(aligned.Dates)-1],
+		},
+	}
+	return c.JSON(http.StatusOK, res)
+}
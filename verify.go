@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/labstack/echo"
+)
+
+type VerifyRes struct {
+	Range            *DateRange  `json:"range"`
+	Tolerance        float64     `json:"tolerance"`
+	Compared         int         `json:"compared"`
+	Mismatches       []*DateDiff `json:"mismatches"`
+	MissingStored    []string    `json:"missing_stored"`
+	MissingProvider  []string    `json:"missing_provider"`
+	StoredChecksum   string      `json:"stored_checksum"`
+	ProviderChecksum string      `json:"provider_checksum"`
+	Match            bool        `json:"match"`
+}
+
+// ratesChecksum hashes the documents as sorted "date currency rate" lines so
+// the same data always produces the same sum regardless of item order.
+func ratesChecksum(rates []*Rate) string {
+	lines := []string{}
+	for _, rate := range rates {
+		for code, v := range rate.RateMap() {
+			lines = append(lines, fmt.Sprintf("%s %s %s", rate.RateDate, code, strconv.FormatFloat(float64(v), 'f', -1, 32)))
+		}
+	}
+	sort.Strings(lines)
+	h := sha256.New()
+	for _, l := range lines {
+		fmt.Fprintln(h, l)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// verify compares the stored documents between start and end with the ECB
+// history file using the dry-run diff. Nothing is written.
+func verify(ctx context.Context, store *DB, start, end string, tolerance float64) (*VerifyRes, error) {
+	stored, err := store.FindRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	fetched, err := fetchECBFull(ctx, ECB_HIST_URL)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &VerifyRes{
+		Range:           &DateRange{Start: start, End: end},
+		Tolerance:       tolerance,
+		Mismatches:      []*DateDiff{},
+		MissingStored:   []string{},
+		MissingProvider: []string{},
+	}
+	byDate := map[string]*Rate{}
+	storedDocs := []*Rate{}
+	for i := range stored {
+		byDate[stored[i].RateDate] = &stored[i]
+		storedDocs = append(storedDocs, &stored[i])
+	}
+
+	provider := []*Rate{}
+	seen := map[string]bool{}
+	for _, rate := range fetched {
+		if len(rate.Rates) == 0 || (start != "" && rate.RateDate < start) || (end != "" && rate.RateDate > end) {
+			continue
+		}
+		provider = append(provider, rate)
+		seen[rate.RateDate] = true
+		old, ok := byDate[rate.RateDate]
+		if !ok {
+			res.MissingStored = append(res.MissingStored, rate.RateDate)
+			continue
+		}
+		res.Compared++
+		if d := diffRates(old, rate, tolerance); len(d.Changes) > 0 {
+			res.Mismatches = append(res.Mismatches, d)
+		}
+	}
+	for _, rate := range storedDocs {
+		if !seen[rate.RateDate] && len(rate.Rates) > 0 {
+			res.MissingProvider = append(res.MissingProvider, rate.RateDate)
+		}
+	}
+	sort.Strings(res.MissingStored)
+	sort.Slice(res.Mismatches, func(i, j int) bool { return res.Mismatches[i].Date < res.Mismatches[j].Date })
+
+	res.StoredChecksum = ratesChecksum(storedDocs)
+	res.ProviderChecksum = ratesChecksum(provider)
+	res.Match = res.StoredChecksum == res.ProviderChecksum
+	return res, nil
+}
+
+func postAdminVerify(c echo.Context) error {
+	r, err := newDateRangeMax(c.QueryParam("start"), c.QueryParam("end"), 0)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	tolerance := 0.0
+	if v := c.QueryParam("tolerance"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t < 0 {
+			return c.JSON(http.StatusBadRequest, "tolerance must be a non-negative number")
+		}
+		tolerance = t
+	}
+
+	res, err := verify(c.Request().Context(), storeFor(c), r.Start, r.End, tolerance)
+	if err != nil {
+		return c.JSON(http.StatusBadGateway, err.Error())
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+)
+
+type WeeklyPoint struct {
+	Week string  `json:"week"`
+	Date string  `json:"date"`
+	Rate float32 `json:"rate"`
+}
+
+// weekly resamples currency in rates (sorted by date ascending) to the last
+// available day of each ISO week, labelled like 2019-W33.
+func weekly(rates []Rate, currency string) []*WeeklyPoint {
+	points := []*WeeklyPoint{}
+	for i := range rates {
+		v, ok := rates[i].RateMap()[currency]
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(DATE_LAYOUT, rates[i].RateDate)
+		if err != nil {
+			continue
+		}
+		year, week := t.ISOWeek()
+		point := &WeeklyPoint{Week: fmt.Sprintf("%d-W%02d", year, week), Date: rates[i].RateDate, Rate: v}
+		if n := len(points); n > 0 && points[n-1].Week == point.Week {
+			points[n-1] = point
+			continue
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
+func getWeekly(c echo.Context) error {
+	currency := strings.ToUpper(c.QueryParam("currency"))
+	if !currencyRe.MatchString(currency) {
+		return c.JSON(http.StatusBadRequest, "invalid currency")
+	}
+	r, err := parseDateRange(c)
+	if err != nil {
+		return dateRangeError(c, err)
+	}
+
+	rates, err := storeFor(c).FindRange(r.Start, r.End)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, weekly(rates, currency))
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo"
+)
+
+// ZSCORE_EPSILON is the stddev below which a series is treated as flat and
+// no z-score is reported.
+const ZSCORE_EPSILON = 1e-9
+
+type ZScore struct {
+	Currency     string   `json:"currency"`
+	Date         string   `json:"date,omitempty"`
+	Latest       float64  `json:"latest"`
+	Mean         float64  `json:"mean"`
+	Stddev       float64  `json:"stddev"`
+	ZScore       *float64 `json:"zscore"`
+	Observations int      `json:"observations"`
+	Error        string   `json:"error,omitempty"`
+}
+
+type ZScoreRes struct {
+	Window  int       `json:"window,omitempty"`
+	Results []*ZScore `json:"results"`
+}
+
+// zscore compares the most recent rate of currency in rates (sorted by date
+// ascending) with the mean and stddev of the whole series.
+func zscore(rates []Rate, currency string) *ZScore {
+	res := &ZScore{Currency: currency}
+	values := []float64{}
+	for i := range rates {
+		if v, ok := rates[i].RateMap()[currency]; ok {
+			values = append(values, float64(v))
+			res.Date = rates[i].RateDate
+		}
+	}
+	res.Observations = len(values)
+	if len(values) < MIN_OBSERVATIONS {
+		res.Error = "not enough observations"
+		return res
+	}
+
+	res.Latest = values[len(values)-1]
+	res.Mean = mean(values)
+	res.Stddev = stddev(values)
+	if res.Stddev < ZSCORE_EPSILON {
+		res.Error = "series has near-zero variance"
+		return res
+	}
+	z := (res.Latest - res.Mean) / res.Stddev
+	if !math.IsNaN(z) && !math.IsInf(z, 0) {
+		res.ZScore = &z
+	}
+	return res
+}
+
+func getZScore(c echo.Context) error {
+	symbols, err := parseSymbols(c.QueryParam("symbols"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+	if len(symbols) == 0 {
+		return c.JSON(http.StatusBadRequest, "symbols is required")
+	}
+
+	window := 0
+	if v := c.QueryParam("window"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < MIN_OBSERVATIONS || n > 5000 {
+			return c.JSON(http.StatusBadRequest, "window must be between "+strconv.Itoa(MIN_OBSERVATIONS)+" and 5000")
+		}
+		window = n
+	}
+
+	rates, err := storeFor(c).FindWindow(window)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, err.Error())
+	}
+
+	res := &ZScoreRes{Window: window, Results: []*ZScore{}}
+	for _, sym := range symbols {
+		res.Results = append(res.Results, zscore(rates, sym))
+	}
+	return c.JSON(http.StatusOK, res)
+}